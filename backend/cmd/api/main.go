@@ -1,18 +1,51 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/nleea/fleet-monitoring/backend/internal/api"
 	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
 	"github.com/nleea/fleet-monitoring/backend/internal/config"
 )
 
+// Tiempo que se espera a que terminen las peticiones en curso al apagar.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	cfg := config.Load()
 	app := appcore.New(cfg)
 	r := api.SetupRouter(app)
 
-	log.Printf("🚀 Servidor corriendo en :%s", cfg.Port)
-	r.Run(":" + cfg.Port)
+	// Últimas posiciones de la flota, solo en la API que sirve el mapa
+	if n, err := app.Sensors().WarmPositions(); err != nil {
+		log.Printf("⚠️  No se pudieron cargar las últimas posiciones: %v", err)
+	} else {
+		log.Printf("📍 Últimas posiciones cargadas: %d dispositivos", n)
+	}
+
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("🚀 Servidor corriendo en :%s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("❌ Servidor detenido: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("🛑 Apagando: esperando peticiones en curso y la cola de ingesta")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️  Apagado del servidor incompleto: %v", err)
+	}
+	app.Shutdown()
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
+	"github.com/nleea/fleet-monitoring/backend/internal/config"
+	"github.com/nleea/fleet-monitoring/backend/internal/gateway"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+// Los equipos Teltonika envían registros cada pocos minutos; una conexión sin
+// tráfico durante más tiempo se considera muerta.
+const idleTimeout = 10 * time.Minute
+
+func main() {
+	cfg := config.Load()
+	// El puente MQTT corre en el proceso de la API; aquí no debe duplicar la ingesta.
+	cfg.MQTTBrokerURL = ""
+	// La retención y las particiones se mantienen desde el proceso de la API.
+	cfg.RetentionDays = 0
+	cfg.SensorPartitioning = false
+	cfg.IdempotencyKeyTTL = 0
+	app := appcore.New(cfg)
+
+	srv := gateway.NewServer(repository.NewDeviceRepository(app.DB), app.Sensors(), app.Logger, idleTimeout)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	log.Printf("📡 Gateway Teltonika (Codec 8) escuchando en :%s", cfg.GatewayPort)
+	if err := srv.ListenAndServe(":" + cfg.GatewayPort); err != nil {
+		log.Fatalf("❌ Gateway detenido: %v", err)
+	}
+	// Los registros ya encolados se guardan antes de salir
+	app.Shutdown()
+}
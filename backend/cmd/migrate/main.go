@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/config"
+	"github.com/nleea/fleet-monitoring/backend/internal/migrations"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
+)
+
+const usage = `Uso: migrate <comando> [opciones]
+
+Comandos:
+  up [-to N]       aplica las migraciones pendientes (hasta la versión N)
+  down [-steps N]  revierte las últimas N migraciones (1 por defecto)
+  status           lista las migraciones y si están aplicadas
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	database, err := db.Connect(cfg.DB_DSN, cfg.DBPool())
+	if err != nil {
+		log.Fatalf("❌ No se pudo conectar a la base de datos: %v", err)
+	}
+	migrator := migrations.NewDefault(database)
+
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "up":
+		fs := flag.NewFlagSet("up", flag.ExitOnError)
+		to := fs.Int("to", 0, "versión máxima a aplicar (0 = todas)")
+		fs.Parse(args)
+
+		applied, err := migrator.Up(*to)
+		for _, v := range applied {
+			log.Printf("✅ Migración %d aplicada", v)
+		}
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("✅ El esquema ya estaba al día")
+		}
+
+		// Convertir sensor_data en tabla particionada por mes, si aún no lo está.
+		// La copia bloquea la tabla hasta terminar: la API y el gateway deben
+		// estar detenidos.
+		if cfg.SensorPartitioning {
+			if err := repository.NewSensorPartitions(database).MigrateFromUnpartitioned(time.Now(), cfg.SensorPartitionsAhead); err != nil {
+				log.Fatalf("❌ No se pudo particionar sensor_data: %v", err)
+			}
+			log.Println("✅ sensor_data particionada por mes")
+		}
+
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "cantidad de migraciones a revertir")
+		fs.Parse(args)
+
+		reverted, err := migrator.Down(*steps)
+		for _, v := range reverted {
+			log.Printf("↩️  Migración %d revertida", v)
+		}
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		for _, s := range status {
+			state := "pendiente"
+			if s.AppliedAt != nil {
+				state = "aplicada " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -1 +1,207 @@
-package alerts
\ No newline at end of file
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+const defaultAlertLimit = 100
+
+// Longitud máxima del motivo de resolución.
+const maxResolutionReason = 255
+
+type bulkAckInput struct {
+	IDs []uint `json:"ids"`
+}
+
+type resolveInput struct {
+	Reason string `json:"reason"`
+}
+
+// respondAlertError traduce los errores de acceso a su código HTTP.
+func respondAlertError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAlertNotFound), errors.Is(err, service.ErrDeviceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDeviceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlertResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func parseAlertID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parámetro id inválido"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// alertFilter lee device_id, type, status, from, to, ack, limit y cursor de la
+// query string.
+func alertFilter(c *gin.Context) (service.AlertFilter, error) {
+	f := service.AlertFilter{
+		Type:   domain.AlertType(c.Query("type")),
+		Limit:  defaultAlertLimit,
+		Cursor: c.Query("cursor"),
+	}
+	if raw := c.Query("device_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			return f, errors.New("device_id inválido")
+		}
+		f.DeviceID = uint(id)
+	}
+	for param, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return f, fmt.Errorf("%s debe ser una fecha RFC3339", param)
+		}
+		*dst = ts
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, errors.New("from debe ser anterior a to")
+	}
+	if raw := c.Query("ack"); raw != "" {
+		ack, err := strconv.ParseBool(raw)
+		if err != nil {
+			return f, errors.New("ack debe ser true o false")
+		}
+		f.Ack = &ack
+	}
+	switch status := domain.AlertStatus(c.Query("status")); status {
+	case "", domain.AlertOpen, domain.AlertAcknowledged, domain.AlertResolved:
+		f.Status = status
+	default:
+		return f, errors.New("status debe ser open, acknowledged o resolved")
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > service.MaxAlertPageSize {
+			return f, fmt.Errorf("limit debe estar entre 1 y %d", service.MaxAlertPageSize)
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, app *appcore.App) {
+	registerRuleRoutes(rg, app)
+
+	group := rg.Group("/")
+	group.Use(middleware.RequireRoles("user", "admin"))
+
+	alertService := service.NewAlertService(
+		repository.NewAlertRepository(app.DB),
+		app.Hub,
+		app.Logger,
+		app.DeviceAccess(),
+	)
+
+	// Alertas de los dispositivos visibles, de la más reciente a la más antigua
+	group.GET("/", func(c *gin.Context) {
+		filter, err := alertFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page, err := alertService.List(middleware.CurrentPrincipal(c), filter)
+		if err != nil {
+			respondAlertError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	})
+
+	group.GET("/:id", func(c *gin.Context) {
+		id, ok := parseAlertID(c)
+		if !ok {
+			return
+		}
+		alert, err := alertService.Get(middleware.CurrentPrincipal(c), id)
+		if err != nil {
+			respondAlertError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, alert)
+	})
+
+	group.POST("/:id/ack", func(c *gin.Context) {
+		id, ok := parseAlertID(c)
+		if !ok {
+			return
+		}
+		alert, err := alertService.Acknowledge(middleware.CurrentPrincipal(c), id)
+		if err != nil {
+			respondAlertError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, alert)
+	})
+
+	// Resolución manual; el cuerpo con el motivo es opcional
+	group.POST("/:id/resolve", func(c *gin.Context) {
+		id, ok := parseAlertID(c)
+		if !ok {
+			return
+		}
+		var input resolveInput
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+				return
+			}
+		}
+		reason := strings.TrimSpace(input.Reason)
+		if len(reason) > maxResolutionReason {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("reason admite hasta %d caracteres", maxResolutionReason)})
+			return
+		}
+		alert, err := alertService.Resolve(middleware.CurrentPrincipal(c), id, reason)
+		if err != nil {
+			respondAlertError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, alert)
+	})
+
+	// Reconocimiento masivo: los ids ajenos o inexistentes vuelven en not_found
+	group.POST("/ack", func(c *gin.Context) {
+		var input bulkAckInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+		if len(input.IDs) == 0 || len(input.IDs) > service.MaxAlertAckBatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ids debe tener entre 1 y %d elementos", service.MaxAlertAckBatch)})
+			return
+		}
+		result, err := alertService.AcknowledgeMany(middleware.CurrentPrincipal(c), input.IDs)
+		if err != nil {
+			respondAlertError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
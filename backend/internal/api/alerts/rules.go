@@ -0,0 +1,105 @@
+package alerts
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
+	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+// respondRuleError traduce los errores de validación y de búsqueda de reglas.
+func respondRuleError(c *gin.Context, err error) {
+	if errs, ok := service.AsValidationError(err); ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "regla inválida", "errors": errs})
+		return
+	}
+	if errors.Is(err, service.ErrAlertRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// registerRuleRoutes expone la administración de reglas de alerta (solo admin).
+func registerRuleRoutes(rg *gin.RouterGroup, app *appcore.App) {
+	group := rg.Group("/rules")
+	group.Use(middleware.RequireRoles("admin"))
+
+	ruleService := service.NewAlertRuleService(
+		repository.NewAlertRuleRepository(app.DB),
+		repository.NewAlertRepository(app.DB),
+		app.DeviceAccess(),
+		app.Rules(),
+		app.Hub,
+	)
+
+	group.GET("/", func(c *gin.Context) {
+		rules, err := ruleService.List()
+		if err != nil {
+			respondRuleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"rules": rules})
+	})
+
+	group.POST("/", func(c *gin.Context) {
+		var input service.AlertRuleInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+		rule, err := ruleService.Create(middleware.CurrentPrincipal(c), input)
+		if err != nil {
+			respondRuleError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, rule)
+	})
+
+	group.GET("/:id", func(c *gin.Context) {
+		id, ok := parseAlertID(c)
+		if !ok {
+			return
+		}
+		rule, err := ruleService.Get(id)
+		if err != nil {
+			respondRuleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	})
+
+	group.PUT("/:id", func(c *gin.Context) {
+		id, ok := parseAlertID(c)
+		if !ok {
+			return
+		}
+		var input service.AlertRuleInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+		rule, err := ruleService.Update(id, input)
+		if err != nil {
+			respondRuleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	})
+
+	group.DELETE("/:id", func(c *gin.Context) {
+		id, ok := parseAlertID(c)
+		if !ok {
+			return
+		}
+		if err := ruleService.Delete(id); err != nil {
+			respondRuleError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -1,10 +1,14 @@
 package devices
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
 	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
 	"github.com/nleea/fleet-monitoring/backend/internal/repository"
 	"github.com/nleea/fleet-monitoring/backend/internal/service"
@@ -14,13 +18,69 @@ type createDeviceInput struct {
 	ExternalID string `json:"external_id" binding:"required"`
 }
 
+type issueCredentialInput struct {
+	Name string `json:"name"`
+}
+
+type attributeSchemaInput struct {
+	Attributes []domain.DeviceAttribute `json:"attributes" binding:"required"`
+}
+
+// Límite de ingesta propio; rps nulo vuelve al de la configuración.
+type rateLimitInput struct {
+	RPS   *float64 `json:"rps" binding:"omitempty,gt=0"`
+	Burst *int     `json:"burst" binding:"omitempty,gt=0"`
+}
+
+type shareDeviceInput struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// respondDeviceError traduce los errores de acceso y de compartición a su código HTTP.
+func respondDeviceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrDeviceNotFound), errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDeviceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func parseUintParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parámetro " + name + " inválido"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func credentialResponse(cred *domain.DeviceCredential) gin.H {
+	return gin.H{
+		"id":           cred.ID,
+		"device_id":    cred.DeviceID,
+		"name":         cred.Name,
+		"prefix":       cred.Prefix,
+		"created_by":   cred.CreatedBy,
+		"created_at":   cred.CreatedAt,
+		"last_used_at": cred.LastUsedAt,
+		"revoked_at":   cred.RevokedAt,
+	}
+}
+
 func RegisterRoutes(rg *gin.RouterGroup, app *appcore.App) {
 	group := rg.Group("/")
 
 	group.Use(middleware.JWTAuth([]byte(app.Config.JWTSecret)))
 
-	deviceRepo := repository.NewDeviceRepository(app.DB)
-	deviceService := service.NewDeviceService(deviceRepo)
+	deviceRepo := repository.NewDeviceRepositoryWithReplicas(app.Reads())
+	deviceService := service.NewDeviceService(deviceRepo, repository.NewUserRepository(app.DB), app.DeviceAccess())
+	credentialService := service.NewDeviceCredentialService(repository.NewDeviceCredentialRepository(app.DB), deviceRepo)
+	attributeService := service.NewDeviceAttributeService(repository.NewDeviceAttributeRepository(app.DB), app.DeviceAccess(), app.Sensors())
 
 	group.GET("/all", middleware.RequireRoles("admin","user"), func(c *gin.Context) {
 		devices, err := deviceService.ListAll()
@@ -31,6 +91,23 @@ func RegisterRoutes(rg *gin.RouterGroup, app *appcore.App) {
 		c.JSON(http.StatusOK, devices)
 	})
 
+	// Última posición de cada dispositivo visible, desde la caché en memoria
+	// refrescada desde la BD: el mapa la usa al cargar, antes de recibir
+	// telemetría por WebSocket.
+	group.GET("/positions", middleware.RequireRoles("admin", "user"), func(c *gin.Context) {
+		now := time.Now()
+		positions, err := app.Sensors().Positions(middleware.CurrentPrincipal(c), now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"generated_at":        now.UTC(),
+			"stale_after_seconds": int64(app.Config.PositionStaleAfter.Seconds()),
+			"positions":           positions,
+		})
+	})
+
 	group.POST("/", middleware.RequireRoles("user", "admin"), func(c *gin.Context) {
 		var input createDeviceInput
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -52,4 +129,199 @@ func RegisterRoutes(rg *gin.RouterGroup, app *appcore.App) {
 			"owner_id":    dev.OwnerID,
 		})
 	})
+
+	// Credenciales de ingesta por dispositivo (solo admin)
+	group.POST("/:id/credentials", middleware.RequireRoles("admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var input issueCredentialInput
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+				return
+			}
+		}
+
+		key, cred, err := credentialService.Issue(deviceID, input.Name, c.GetUint("userID"))
+		if errors.Is(err, service.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		resp := credentialResponse(cred)
+		resp["key"] = key
+		c.JSON(http.StatusCreated, resp)
+	})
+
+	group.GET("/:id/credentials", middleware.RequireRoles("admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		creds, err := credentialService.List(deviceID)
+		if errors.Is(err, service.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		out := make([]gin.H, 0, len(creds))
+		for i := range creds {
+			out = append(out, credentialResponse(&creds[i]))
+		}
+		c.JSON(http.StatusOK, out)
+	})
+
+	group.DELETE("/:id/credentials/:credential_id", middleware.RequireRoles("admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+		credentialID, ok := parseUintParam(c, "credential_id")
+		if !ok {
+			return
+		}
+
+		err := credentialService.Revoke(deviceID, credentialID)
+		if errors.Is(err, service.ErrCredentialNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+	})
+
+	// Acceso compartido: el dueño o un admin lo conceden a otros usuarios
+	group.POST("/:id/shares", middleware.RequireRoles("user", "admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var input shareDeviceInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+
+		user, err := deviceService.Share(middleware.CurrentPrincipal(c), deviceID, input.Email)
+		if err != nil {
+			respondDeviceError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"device_id": deviceID, "user_id": user.ID, "email": user.Email})
+	})
+
+	group.GET("/:id/shares", middleware.RequireRoles("user", "admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		shares, err := deviceService.ListShares(middleware.CurrentPrincipal(c), deviceID)
+		if err != nil {
+			respondDeviceError(c, err)
+			return
+		}
+
+		out := make([]gin.H, 0, len(shares))
+		for _, sh := range shares {
+			out = append(out, gin.H{"user_id": sh.UserID, "created_at": sh.CreatedAt})
+		}
+		c.JSON(http.StatusOK, out)
+	})
+
+	group.DELETE("/:id/shares/:user_id", middleware.RequireRoles("user", "admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+		userID, ok := parseUintParam(c, "user_id")
+		if !ok {
+			return
+		}
+
+		if err := deviceService.Unshare(middleware.CurrentPrincipal(c), deviceID, userID); err != nil {
+			respondDeviceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "unshared"})
+	})
+
+	// Esquema de atributos extendidos de telemetría
+	group.GET("/:id/attributes", middleware.RequireRoles("user", "admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		attrs, err := attributeService.Schema(middleware.CurrentPrincipal(c), deviceID)
+		if err != nil {
+			respondDeviceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"attributes": attrs})
+	})
+
+	group.PUT("/:id/attributes", middleware.RequireRoles("user", "admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var input attributeSchemaInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+
+		attrs, err := attributeService.SetSchema(middleware.CurrentPrincipal(c), deviceID, input.Attributes)
+		if errs, ok := service.AsValidationError(err); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "esquema de atributos inválido", "errors": errs})
+			return
+		}
+		if err != nil {
+			respondDeviceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"attributes": attrs})
+	})
+
+	group.PUT("/:id/rate-limit", middleware.RequireRoles("admin"), func(c *gin.Context) {
+		deviceID, ok := parseUintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var input rateLimitInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rps y burst deben ser positivos"})
+			return
+		}
+		if input.RPS == nil {
+			input.Burst = nil
+		}
+
+		if err := app.Sensors().SetDeviceRateLimit(deviceID, input.RPS, input.Burst); err != nil {
+			respondDeviceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"device_id": deviceID, "rps": input.RPS, "burst": input.Burst})
+	})
 }
@@ -0,0 +1,134 @@
+package exports
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+type createExportInput struct {
+	DeviceIDs []uint    `json:"device_ids"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Columns   []string  `json:"columns"`
+	Format    string    `json:"format"`
+}
+
+// respondExportError traduce los errores de acceso y de estado a su código HTTP.
+func respondExportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrExportNotFound), errors.Is(err, service.ErrDeviceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDeviceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrExportExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func parseJobID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parámetro id inválido"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, app *appcore.App) {
+	group := rg.Group("/")
+	group.Use(middleware.RequireRoles("user", "admin"))
+
+	store, err := export.NewStore(app.Config.ExportDir)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	exportService := service.NewExportService(
+		repository.NewExportRepository(app.DB),
+		repository.NewSensorRepositoryWithReplicas(app.Reads()),
+		app.DeviceAccess(),
+		store,
+	)
+	exportService.SetRollupRepository(repository.NewRollupRepositoryWithReplicas(app.Reads()))
+	exportService.SetTTL(app.Config.ExportTTL)
+	if err := exportService.Recover(); err != nil {
+		log.Printf("⚠️  No se pudieron revisar exportaciones pendientes: %v", err)
+	}
+
+	group.GET("/columns", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"columns": export.ColumnNames(), "formats": []string{export.FormatCSV, export.FormatParquet}})
+	})
+
+	group.POST("/", func(c *gin.Context) {
+		var input createExportInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+			return
+		}
+
+		job, err := exportService.Create(middleware.CurrentPrincipal(c), service.ExportRequest{
+			DeviceIDs: input.DeviceIDs,
+			From:      input.From,
+			To:        input.To,
+			Columns:   input.Columns,
+			Format:    input.Format,
+		})
+		if errs, ok := service.AsValidationError(err); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "exportación inválida", "errors": errs})
+			return
+		}
+		if err != nil {
+			respondExportError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	})
+
+	group.GET("/", func(c *gin.Context) {
+		jobs, err := exportService.List(middleware.CurrentPrincipal(c))
+		if err != nil {
+			respondExportError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"exports": jobs})
+	})
+
+	group.GET("/:id", func(c *gin.Context) {
+		id, ok := parseJobID(c)
+		if !ok {
+			return
+		}
+		job, err := exportService.Get(middleware.CurrentPrincipal(c), id)
+		if err != nil {
+			respondExportError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	group.GET("/:id/download", func(c *gin.Context) {
+		id, ok := parseJobID(c)
+		if !ok {
+			return
+		}
+		path, name, err := exportService.File(middleware.CurrentPrincipal(c), id)
+		if err != nil {
+			respondExportError(c, err)
+			return
+		}
+		c.FileAttachment(path, name)
+	})
+}
@@ -4,28 +4,51 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/api/alerts"
 	"github.com/nleea/fleet-monitoring/backend/internal/api/auth"
 	"github.com/nleea/fleet-monitoring/backend/internal/api/devices"
+	"github.com/nleea/fleet-monitoring/backend/internal/api/exports"
 	"github.com/nleea/fleet-monitoring/backend/internal/api/sensors"
 	"github.com/nleea/fleet-monitoring/backend/internal/api/user"
 
 	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
 
 	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/telemetrypb"
 
 	wsapi "github.com/nleea/fleet-monitoring/backend/internal/api/ws"
 )
 
+// replicaHealth resume las réplicas de lectura disponibles.
+func replicaHealth(app *appcore.App) gin.H {
+	healthy, total := app.Reads().Healthy()
+	return gin.H{"healthy": healthy, "total": total}
+}
+
 func SetupRouter(app *appcore.App) *gin.Engine {
 	r := gin.Default()
 	r.Use(middleware.CORSMiddleware())
 
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok", "env": app.Config.Env})
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+			"env":    app.Config.Env,
+			"ingest": app.Sensors().PipelineStats(),
+			// Peticiones rechazadas por límite de ingesta, por ámbito
+			"rate_limit_rejected": app.RateLimits().Stats(),
+			"replicas":            replicaHealth(app),
+		})
 	})
 
 	v1 := r.Group("/api/v1")
 
+	// Esquema .proto para clientes que ingieren en application/x-protobuf
+	v1.GET("/schemas/telemetry.proto", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", telemetrypb.Schema)
+	})
+
 	auth.RegisterRoutes(v1, app)
 
 	protected := v1.Group("/protected")
@@ -45,10 +68,26 @@ func SetupRouter(app *appcore.App) *gin.Engine {
 	devicesgroup.Use(middleware.JWTAuth([]byte(app.Config.JWTSecret)))
 	devices.RegisterRoutes(devicesgroup, app)
 
+	exportsGroup := protected.Group("/exports")
+	exportsGroup.Use(middleware.JWTAuth([]byte(app.Config.JWTSecret)))
+	exports.RegisterRoutes(exportsGroup, app)
+
+	alertsGroup := protected.Group("/alerts")
+	alertsGroup.Use(middleware.JWTAuth([]byte(app.Config.JWTSecret)))
+	alerts.RegisterRoutes(alertsGroup, app)
+
 	usergroup := protected.Group("/user")
 	usergroup.Use(middleware.JWTAuth([]byte(app.Config.JWTSecret)))
 	user.RegisterRoutes(usergroup, app)
 
+	// Ingesta directa de dispositivos con API key propia, sin JWT de usuario
+	ingestGroup := v1.Group("/ingest")
+	ingestGroup.Use(middleware.DeviceKeyAuth(service.NewDeviceCredentialService(
+		repository.NewDeviceCredentialRepository(app.DB),
+		repository.NewDeviceRepository(app.DB),
+	)))
+	sensors.RegisterIngestRoutes(ingestGroup, app)
+
 	wsapi.RegisterRoutes(v1, app, app.Hub)
 
 	return r
@@ -0,0 +1,172 @@
+package sensors
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/telemetrypb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Tamaño máximo de un cuerpo protobuf; un lote de maxBatchSize lecturas con
+// algunos atributos ocupa bastante menos.
+const maxProtobufBodyBytes = 1 << 20
+
+const errInvalidProtobuf = "protobuf inválido"
+
+// isProtobuf indica si el cliente envió protobuf; la respuesta usa el mismo formato.
+func isProtobuf(c *gin.Context) bool {
+	return c.ContentType() == telemetrypb.ContentType
+}
+
+func readProtobufBody(c *gin.Context) ([]byte, error) {
+	return io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxProtobufBodyBytes))
+}
+
+// readingFormat convierte una lectura en bruto, JSON o protobuf, en sensorInput.
+type readingFormat struct {
+	decode  func(raw []byte, pinned uint) (sensorInput, error)
+	invalid string
+}
+
+var (
+	jsonFormat  = readingFormat{decode: jsonReading, invalid: errInvalidJSON}
+	protoFormat = readingFormat{decode: protoReading, invalid: errInvalidProtobuf}
+)
+
+func formatOf(c *gin.Context) readingFormat {
+	if isProtobuf(c) {
+		return protoFormat
+	}
+	return jsonFormat
+}
+
+func jsonReading(raw []byte, pinned uint) (sensorInput, error) {
+	input := sensorInput{DeviceID: pinned}
+	err := json.Unmarshal(raw, &input)
+	return input, err
+}
+
+func protoReading(raw []byte, pinned uint) (sensorInput, error) {
+	r, err := telemetrypb.UnmarshalReading(raw)
+	if err != nil {
+		return sensorInput{}, err
+	}
+
+	attributes, err := telemetrypb.AttributeMap(r)
+	if err != nil {
+		return sensorInput{}, err
+	}
+
+	input := sensorInput{
+		DeviceID:    uint(r.GetDeviceId()),
+		Lat:         r.GetLat(),
+		Lng:         r.GetLng(),
+		Speed:       telemetrypb.Float64(r.GetSpeed()),
+		FuelLevel:   telemetrypb.Float64(r.GetFuelLevel()),
+		Temperature: telemetrypb.Float64(r.GetTemperature()),
+		Attributes:  attributes,
+	}
+	if input.DeviceID == 0 {
+		input.DeviceID = pinned
+	}
+	if r.GetTsUnixMs() != 0 {
+		input.TS = time.UnixMilli(r.GetTsUnixMs()).UTC()
+	}
+	return input, nil
+}
+
+// bindBatch devuelve las lecturas en bruto de un lote JSON (arreglo) o
+// protobuf (ReadingBatch).
+func bindBatch(c *gin.Context) ([][]byte, error) {
+	if isProtobuf(c) {
+		body, err := readProtobufBody(c)
+		if err == nil {
+			var items [][]byte
+			if items, err = telemetrypb.SplitBatch(body); err == nil {
+				return items, nil
+			}
+		}
+		return nil, errors.New("protobuf inválido, se esperaba un ReadingBatch")
+	}
+
+	var raw []json.RawMessage
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		return nil, errors.New("JSON inválido, se esperaba un arreglo de lecturas")
+	}
+	items := make([][]byte, len(raw))
+	for i := range raw {
+		items[i] = raw[i]
+	}
+	return items, nil
+}
+
+// render responde con body en el formato de la petición. En protobuf las
+// claves conocidas de las respuestas de ingesta se copian a IngestResponse.
+func render(c *gin.Context, code int, body gin.H) {
+	if !isProtobuf(c) {
+		c.JSON(code, body)
+		return
+	}
+
+	var resp telemetrypb.IngestResponse
+	for key, value := range body {
+		switch v := value.(type) {
+		case string:
+			switch key {
+			case "status":
+				resp.Status = v
+			case "error":
+				resp.Error = v
+			}
+		case uint:
+			resp.Id = uint64(v)
+		case bool:
+			resp.Duplicate = v
+		case int:
+			switch key {
+			case "accepted":
+				resp.Accepted = uint32(v)
+			case "duplicates":
+				resp.Duplicates = uint32(v)
+			case "rejected":
+				resp.Rejected = uint32(v)
+			}
+		case []service.FieldError:
+			resp.Errors = protoFieldErrors(v)
+		case []batchItemResult:
+			resp.Results = make([]*telemetrypb.ItemResult, len(v))
+			for i, item := range v {
+				resp.Results[i] = &telemetrypb.ItemResult{
+					Index:  uint32(item.Index),
+					Status: item.Status,
+					Id:     uint64(item.ID),
+					Error:  item.Error,
+					Errors: protoFieldErrors(item.Errors),
+				}
+			}
+		}
+	}
+	out, err := proto.Marshal(&resp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(code, telemetrypb.ContentType, out)
+}
+
+func protoFieldErrors(errs []service.FieldError) []*telemetrypb.FieldError {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make([]*telemetrypb.FieldError, len(errs))
+	for i, fe := range errs {
+		out[i] = &telemetrypb.FieldError{Field: fe.Field, Code: fe.Code, Message: fe.Message}
+	}
+	return out
+}
@@ -1,16 +1,50 @@
 package sensors
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
-	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
 	"github.com/nleea/fleet-monitoring/backend/internal/service"
 )
 
+const (
+	// Máximo de lecturas aceptadas en un solo POST /data/batch.
+	maxBatchSize = 1000
+	// Coincide con el tamaño de la columna en domain.IdempotencyKey.
+	maxIdempotencyKeyLength = 128
+	// Segundos sugeridos en Retry-After cuando la cola de ingesta está llena.
+	queueFullRetryAfter = 1
+	// Tamaño de página por defecto y máximo del historial.
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+	// Rango por defecto de la agregación y puntos del recorrido simplificado.
+	defaultAggregateRange = 24 * time.Hour
+	defaultTrackPoints    = 500
+	maxTrackPoints        = 5000
+)
+
+// attributeFilters lee attr[nombre]=valor, attr_gte[nombre]=min y
+// attr_lte[nombre]=max de la query string.
+func attributeFilters(c *gin.Context) []service.AttributeFilter {
+	var filters []service.AttributeFilter
+	for param, op := range map[string]string{"attr": "=", "attr_gte": ">=", "attr_lte": "<="} {
+		for name, value := range c.QueryMap(param) {
+			filters = append(filters, service.AttributeFilter{Name: name, Op: op, Value: value})
+		}
+	}
+	return filters
+}
+
 type sensorInput struct {
 	DeviceID    uint    `json:"device_id" binding:"required"`
 	Lat         float64 `json:"lat"`
@@ -19,57 +53,409 @@ type sensorInput struct {
 	FuelLevel   float64 `json:"fuel_level"`
 	Temperature float64 `json:"temperature"`
 	TS        time.Time `json:"ts" binding:"required"`
+	// Métricas adicionales declaradas en el esquema del dispositivo.
+	Attributes map[string]any `json:"attributes"`
+}
+
+func (in sensorInput) toSensorData() domain.SensorData {
+	return domain.SensorData{
+		DeviceID:    in.DeviceID,
+		TS:          in.TS,
+		Lat:         in.Lat,
+		Lng:         in.Lng,
+		Speed:       in.Speed,
+		FuelLevel:   in.FuelLevel,
+		Temperature: in.Temperature,
+		Attributes:  in.Attributes,
+	}
+}
+
+// newSensorInput prepara la lectura a decodificar. Con una API key de
+// dispositivo el device_id viene fijado por la credencial y puede omitirse.
+func newSensorInput(c *gin.Context) sensorInput {
+	return sensorInput{DeviceID: pinnedDeviceID(c)}
+}
+
+// pinnedDeviceID es el dispositivo de la credencial de ingesta, o 0 con JWT de usuario.
+func pinnedDeviceID(c *gin.Context) uint {
+	return c.GetUint("deviceID")
+}
+
+const errDeviceMismatch = "device_id no coincide con la credencial del dispositivo"
+
+// respondAccessError responde 404 si el dispositivo no existe y 403 si el
+// principal no tiene acceso.
+func respondAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrDeviceNotFound):
+		render(c, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDeviceForbidden):
+		render(c, http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		render(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// respondIngestError traduce ErrQueueFull y el límite del dispositivo a 429
+// para que el cliente reintente.
+func respondIngestError(c *gin.Context, err error) {
+	if rle, ok := service.AsRateLimitError(err); ok {
+		middleware.SetRateLimitHeaders(c, rle.Decision)
+		render(c, http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrQueueFull) {
+		c.Header("Retry-After", strconv.Itoa(queueFullRetryAfter))
+		render(c, http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	render(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+type batchItemResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     uint                 `json:"id,omitempty"`
+	Error  string               `json:"error,omitempty"`
+	Errors []service.FieldError `json:"errors,omitempty"`
 }
 
 func RegisterRoutes(rg *gin.RouterGroup, app *appcore.App) {
 	group := rg.Group("/")
 
-	sensorRepo := repository.NewSensorRepository(app.DB)
-	alertRepo := repository.NewAlertRepository(app.DB)
-	sensorService := service.NewSensorService(sensorRepo, alertRepo, app.Hub, repository.NewDeviceRepository(app.DB))
+	sensorService := app.Sensors()
+	registerIngestRoutes(group, sensorService, app.RateLimits())
 
-	group.POST("/data", func(c *gin.Context) {
-		var input sensorInput
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido"})
+	group.GET("/data/:device_id", func(c *gin.Context) {
+		deviceIDParam := c.Param("device_id")
+		var deviceID uint
+		if _, err := fmt.Sscanf(deviceIDParam, "%d", &deviceID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device_id"})
 			return
 		}
 
-		err := sensorService.IngestData(
-			input.DeviceID,
-			input.Lat,
-			input.Lng,
-			input.Speed,
-			input.FuelLevel,
-			input.Temperature,
-			input.TS,
-		)
+		query, err := historyQuery(c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusAccepted, gin.H{"status": "data received"})
+		page, err := sensorService.History(middleware.CurrentPrincipal(c), deviceID, query)
+		if errs, ok := service.AsValidationError(err); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filtro de atributos inválido", "errors": errs})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			respondAccessError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, page)
 	})
 
-	group.GET("/data/:device_id", func(c *gin.Context) {
-		deviceIDParam := c.Param("device_id")
-		var deviceID uint
-		if _, err := fmt.Sscanf(deviceIDParam, "%d", &deviceID); err != nil {
+	// Historial agregado por intervalo, o con mode=lttb recorrido simplificado
+	// para el mapa. Sin from/to cubre las últimas 24 horas.
+	group.GET("/data/:device_id/aggregate", func(c *gin.Context) {
+		deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 64)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device_id"})
 			return
 		}
+		from, to, err := timeRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if from.IsZero() {
+			from = to.Add(-defaultAggregateRange)
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from debe ser anterior a to"})
+			return
+		}
+		principal := middleware.CurrentPrincipal(c)
 
-		data, err := sensorService.GetSensorDataByDeviceID(deviceID)
+		switch mode := c.DefaultQuery("mode", "buckets"); mode {
+		case "buckets":
+			interval := c.DefaultQuery("interval", "5m")
+			buckets, err := sensorService.Aggregate(principal, uint(deviceID), from, to, interval)
+			if errors.Is(err, service.ErrUnknownInterval) || errors.Is(err, service.ErrRangeTooLarge) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				respondAccessError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"interval": interval, "from": from, "to": to, "buckets": buckets})
+
+		case "lttb":
+			points := defaultTrackPoints
+			if raw := c.Query("points"); raw != "" {
+				n, err := strconv.Atoi(raw)
+				if err != nil || n < 3 || n > maxTrackPoints {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("points debe estar entre 3 y %d", maxTrackPoints)})
+					return
+				}
+				points = n
+			}
+			track, total, err := sensorService.Track(principal, uint(deviceID), from, to, points)
+			if errors.Is(err, service.ErrRangeTooLarge) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				respondAccessError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "total": total, "points": track})
+
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode debe ser buckets o lttb"})
+		}
+	})
+
+	// Recorrido completo en GPX, KML o GeoJSON para abrir en Google Earth o
+	// QGIS. Se envía por bloques mientras se lee; sin from/to cubre las
+	// últimas 24 horas.
+	group.GET("/data/:device_id/track", func(c *gin.Context) {
+		deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device_id"})
+			return
+		}
+		format := c.DefaultQuery("format", export.FormatGeoJSON)
+		contentType, ok := export.TrackFormats[format]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format debe ser gpx, kml o geojson"})
+			return
+		}
+		from, to, err := timeRange(c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if data == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No data found"})
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if from.IsZero() {
+			from = to.Add(-defaultAggregateRange)
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from debe ser anterior a to"})
 			return
 		}
 
-		c.JSON(http.StatusOK, data)
+		err = sensorService.ExportTrack(middleware.CurrentPrincipal(c), uint(deviceID), from, to, func(dev *domain.Device) (export.Writer, error) {
+			name := fmt.Sprintf("%s-%s.%s", dev.ExternalID, from.UTC().Format("20060102"), format)
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+			c.Status(http.StatusOK)
+			return export.NewTrackWriter(format, c.Writer, dev.ExternalID)
+		})
+		switch {
+		case err == nil:
+		case c.Writer.Written():
+			// La respuesta ya empezó: solo queda cortarla
+			log.Printf("❌ Recorrido del dispositivo %d interrumpido: %v", deviceID, err)
+			c.Abort()
+		case errors.Is(err, service.ErrTrackRangeTooLong):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			respondAccessError(c, err)
+		}
 	})
 }
+
+// timeRange lee from y to (RFC3339) de la query string; los ausentes quedan en cero.
+func timeRange(c *gin.Context) (from, to time.Time, err error) {
+	for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return from, to, fmt.Errorf("%s debe ser una fecha RFC3339", param)
+		}
+		*dst = ts
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("from debe ser anterior a to")
+	}
+	return from, to, nil
+}
+
+// historyQuery lee from, to, limit y cursor de la query string.
+func historyQuery(c *gin.Context) (service.HistoryQuery, error) {
+	q := service.HistoryQuery{Limit: defaultHistoryLimit, Cursor: c.Query("cursor"), Attributes: attributeFilters(c)}
+	var err error
+	if q.From, q.To, err = timeRange(c); err != nil {
+		return q, err
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxHistoryLimit {
+			return q, fmt.Errorf("limit debe estar entre 1 y %d", maxHistoryLimit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// RegisterIngestRoutes expone solo los endpoints de ingesta; se monta en el
+// grupo autenticado con API keys de dispositivo.
+func RegisterIngestRoutes(rg *gin.RouterGroup, app *appcore.App) {
+	registerIngestRoutes(rg.Group("/"), app.Sensors(), app.RateLimits())
+}
+
+// deviceAllowance consume un token por cada dispositivo distinto de la
+// petición: un lote o un stream cuenta como una sola petición por dispositivo.
+type deviceAllowance struct {
+	c    *gin.Context
+	svc  *service.SensorService
+	seen map[uint]error
+}
+
+func newDeviceAllowance(c *gin.Context, svc *service.SensorService) *deviceAllowance {
+	return &deviceAllowance{c: c, svc: svc, seen: make(map[uint]error)}
+}
+
+func (a *deviceAllowance) allow(deviceID uint) error {
+	if err, ok := a.seen[deviceID]; ok {
+		return err
+	}
+	d, err := a.svc.AllowDevice(deviceID)
+	if rle, ok := service.AsRateLimitError(err); ok {
+		d = rle.Decision
+	}
+	middleware.SetRateLimitHeaders(a.c, d)
+	a.seen[deviceID] = err
+	return err
+}
+
+func registerIngestRoutes(group *gin.RouterGroup, sensorService *service.SensorService, limits *ratelimit.Registry) {
+	limit := middleware.RateLimit(limits)
+
+	group.POST("/data", limit, func(c *gin.Context) {
+		input, err := bindReading(c)
+		if err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if pinned := pinnedDeviceID(c); pinned != 0 && input.DeviceID != pinned {
+			render(c, http.StatusForbidden, gin.H{"error": errDeviceMismatch})
+			return
+		}
+		if err := sensorService.AuthorizeDevice(middleware.CurrentPrincipal(c), input.DeviceID); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		idempotencyKey := c.GetHeader("Idempotency-Key")
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			render(c, http.StatusBadRequest, gin.H{"error": "Idempotency-Key demasiado largo"})
+			return
+		}
+
+		data := input.toSensorData()
+		result, err := sensorService.Ingest(&data, idempotencyKey)
+		if errs, ok := service.AsValidationError(err); ok {
+			render(c, http.StatusUnprocessableEntity, gin.H{"error": errInvalidReading, "errors": errs})
+			return
+		}
+		if err != nil {
+			respondIngestError(c, err)
+			return
+		}
+		middleware.SetRateLimitHeaders(c, result.RateLimit)
+
+		if result.Duplicate {
+			render(c, http.StatusOK, gin.H{"status": "duplicate", "id": result.ID, "duplicate": true})
+			return
+		}
+		render(c, http.StatusAccepted, gin.H{"status": "data received", "id": result.ID, "duplicate": false})
+	})
+
+	group.POST("/data/batch", limit, func(c *gin.Context) {
+		items, err := bindBatch(c)
+		if err != nil {
+			render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(items) == 0 {
+			render(c, http.StatusBadRequest, gin.H{"error": "lote vacío"})
+			return
+		}
+		if len(items) > maxBatchSize {
+			render(c, http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("el lote supera el máximo de %d lecturas", maxBatchSize),
+			})
+			return
+		}
+
+		results := make([]batchItemResult, len(items))
+		readings := make([]domain.SensorData, 0, len(items))
+		accepted := make([]int, 0, len(items))
+
+		principal := middleware.CurrentPrincipal(c)
+		format := formatOf(c)
+		allowance := newDeviceAllowance(c, sensorService)
+		var limitErr error
+		limited := 0
+		for i, raw := range items {
+			data, msg, errs, err := decodeReading(raw, format, principal, sensorService, false)
+			if err != nil {
+				respondIngestError(c, err)
+				return
+			}
+			if msg != "" {
+				results[i] = batchItemResult{Index: i, Status: "rejected", Error: msg, Errors: errs}
+				continue
+			}
+			if err := allowance.allow(data.DeviceID); err != nil {
+				results[i] = batchItemResult{Index: i, Status: "rejected", Error: err.Error()}
+				limitErr = err
+				limited++
+				continue
+			}
+			readings = append(readings, data)
+			accepted = append(accepted, i)
+		}
+		// Si todo el lote quedó fuera por límite, el cliente debe reintentarlo.
+		if limited == len(items) {
+			respondIngestError(c, limitErr)
+			return
+		}
+
+		ingested, err := sensorService.IngestBatch(readings)
+		if err != nil {
+			respondIngestError(c, err)
+			return
+		}
+
+		duplicates := 0
+		for n, i := range accepted {
+			results[i] = batchItemResult{Index: i, Status: "accepted", ID: ingested[n].ID}
+			if ingested[n].Duplicate {
+				results[i].Status = "duplicate"
+				duplicates++
+			}
+		}
+
+		render(c, http.StatusAccepted, gin.H{
+			"accepted":   len(accepted) - duplicates,
+			"duplicates": duplicates,
+			"rejected":   len(items) - len(accepted),
+			"results":    results,
+		})
+	})
+
+	group.POST("/data/stream", limit, streamHandler(sensorService))
+}
@@ -0,0 +1,166 @@
+package sensors
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+const (
+	// Lecturas acumuladas antes de escribir un bloque en la BD.
+	streamChunkSize = 2000
+	// Tamaño máximo de una línea NDJSON.
+	maxStreamLineBytes = 64 * 1024
+	// Errores por línea devueltos en el resumen; el resto solo se cuenta.
+	maxStreamErrors = 50
+)
+
+type streamLineError struct {
+	Line   int                  `json:"line"`
+	Error  string               `json:"error"`
+	Errors []service.FieldError `json:"errors,omitempty"`
+}
+
+type streamSummary struct {
+	Mode       string            `json:"mode"`
+	Lines      int               `json:"lines"`
+	Ingested   int               `json:"ingested"`
+	Duplicates int               `json:"duplicates"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	DurationMS int64             `json:"duration_ms"`
+	Errors     []streamLineError `json:"errors,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+func (s *streamSummary) addError(line int, msg string, errs ...service.FieldError) {
+	if len(s.Errors) < maxStreamErrors {
+		s.Errors = append(s.Errors, streamLineError{Line: line, Error: msg, Errors: errs})
+	}
+}
+
+// streamHandler recibe lecturas en NDJSON (una por línea, opcionalmente con
+// Content-Encoding: gzip) y las escribe en bloques sin cargar el cuerpo completo
+// en memoria. Por defecto funciona en modo backfill; con ?mode=live se emite
+// telemetría y se evalúan alertas como en la ingesta normal.
+func streamHandler(sensorService *service.SensorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode := c.DefaultQuery("mode", "backfill")
+		if mode != "backfill" && mode != "live" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode debe ser backfill o live"})
+			return
+		}
+
+		var body io.Reader = c.Request.Body
+		if strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cuerpo gzip inválido"})
+				return
+			}
+			defer gz.Close()
+			body = gz
+		}
+
+		principal := middleware.CurrentPrincipal(c)
+		allowance := newDeviceAllowance(c, sensorService)
+		start := time.Now()
+		summary := streamSummary{Mode: mode}
+		chunk := make([]domain.SensorData, 0, streamChunkSize)
+
+		flush := func() {
+			if len(chunk) == 0 {
+				return
+			}
+			inserted, err := sensorService.IngestChunk(chunk, mode == "backfill")
+			if err != nil {
+				summary.Failed += len(chunk)
+				summary.addError(summary.Lines, "no se pudo guardar el bloque: "+err.Error())
+			} else {
+				summary.Ingested += inserted
+				summary.Duplicates += len(chunk) - inserted
+			}
+			chunk = chunk[:0]
+		}
+
+		reader := bufio.NewReaderSize(body, maxStreamLineBytes)
+		var readErr error
+		for {
+			line, tooLong, err := readLine(reader)
+			if len(line) > 0 || tooLong || err == nil {
+				summary.Lines++
+			}
+
+			switch {
+			case tooLong:
+				summary.Skipped++
+				summary.addError(summary.Lines, "línea demasiado larga")
+			case len(bytes.TrimSpace(line)) > 0:
+				reading, msg, errs, err := decodeReading(line, jsonFormat, principal, sensorService, mode == "backfill")
+				if err != nil {
+					summary.Failed++
+					summary.addError(summary.Lines, "no se pudo validar la lectura: "+err.Error())
+					break
+				}
+				if msg != "" {
+					summary.Skipped++
+					summary.addError(summary.Lines, msg, errs...)
+					break
+				}
+				if err := allowance.allow(reading.DeviceID); err != nil {
+					summary.Skipped++
+					summary.addError(summary.Lines, err.Error())
+					break
+				}
+				chunk = append(chunk, reading)
+				if len(chunk) >= streamChunkSize {
+					flush()
+				}
+			}
+
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr = err
+				}
+				break
+			}
+		}
+		flush()
+
+		summary.DurationMS = time.Since(start).Milliseconds()
+		if readErr != nil {
+			summary.Error = "lectura del cuerpo interrumpida: " + readErr.Error()
+			c.JSON(http.StatusBadRequest, summary)
+			return
+		}
+
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+// readLine devuelve la siguiente línea sin el salto final. Las líneas que
+// exceden maxStreamLineBytes se descartan completas y se marcan con tooLong.
+func readLine(r *bufio.Reader) (line []byte, tooLong bool, err error) {
+	line, err = r.ReadSlice('\n')
+	if !errors.Is(err, bufio.ErrBufferFull) {
+		return bytes.TrimRight(line, "\r\n"), false, err
+	}
+
+	for errors.Is(err, bufio.ErrBufferFull) {
+		_, err = r.ReadSlice('\n')
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return nil, true, err
+}
@@ -0,0 +1,120 @@
+package sensors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+const (
+	errInvalidJSON    = "JSON inválido"
+	errInvalidReading = "lectura inválida"
+)
+
+// Nombres JSON de los campos de sensorInput que tienen reglas de binding.
+var sensorInputFields = map[string]string{
+	"DeviceID": "device_id",
+	"TS":       "ts",
+}
+
+// bindingFieldErrors traduce los errores de decodificación y de binding a
+// errores por campo. Un JSON mal formado no tiene campo asociado y devuelve nil.
+func bindingFieldErrors(err error) []service.FieldError {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		out := make([]service.FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			field := sensorInputFields[fe.Field()]
+			if field == "" {
+				field = fe.Field()
+			}
+			out = append(out, service.FieldError{Field: field, Code: service.CodeRequired, Message: "campo requerido"})
+		}
+		return out
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []service.FieldError{{
+			Field:   typeErr.Field,
+			Code:    service.CodeInvalidType,
+			Message: "se esperaba " + typeErr.Type.String(),
+		}}
+	}
+
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		return []service.FieldError{{Field: "ts", Code: service.CodeInvalidFormat, Message: "se esperaba fecha RFC3339"}}
+	}
+
+	return nil
+}
+
+// respondBindError responde 400 con el detalle por campo cuando lo hay.
+func respondBindError(c *gin.Context, err error) {
+	body := gin.H{"error": formatOf(c).invalid}
+	if errs := bindingFieldErrors(err); len(errs) > 0 {
+		body["errors"] = errs
+	}
+	render(c, http.StatusBadRequest, body)
+}
+
+// bindReading decodifica el cuerpo de POST /data en JSON o protobuf y aplica
+// las reglas de binding de sensorInput.
+func bindReading(c *gin.Context) (sensorInput, error) {
+	if !isProtobuf(c) {
+		input := newSensorInput(c)
+		err := c.ShouldBindJSON(&input)
+		return input, err
+	}
+
+	body, err := readProtobufBody(c)
+	if err != nil {
+		return sensorInput{}, err
+	}
+	input, err := protoReading(body, pinnedDeviceID(c))
+	if err != nil {
+		return sensorInput{}, err
+	}
+	return input, binding.Validator.ValidateStruct(&input)
+}
+
+// decodeReading decodifica, autoriza y valida una lectura individual de un lote
+// o stream. Devuelve un mensaje de error y, si aplica, el detalle por campo. Un
+// fallo de la BD vuelve como error: la lectura no es inválida y debe reintentarse.
+func decodeReading(raw []byte, format readingFormat, principal service.Principal, sensorService *service.SensorService, backfill bool) (domain.SensorData, string, []service.FieldError, error) {
+	pinned := principal.DeviceID
+	input, err := format.decode(raw, pinned)
+	if err != nil {
+		return domain.SensorData{}, format.invalid, bindingFieldErrors(err), nil
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		return domain.SensorData{}, format.invalid, bindingFieldErrors(err), nil
+	}
+	if pinned != 0 && input.DeviceID != pinned {
+		return domain.SensorData{}, errDeviceMismatch, nil, nil
+	}
+	if err := sensorService.AuthorizeDevice(principal, input.DeviceID); err != nil {
+		if errors.Is(err, service.ErrDeviceNotFound) || errors.Is(err, service.ErrDeviceForbidden) {
+			return domain.SensorData{}, err.Error(), nil, nil
+		}
+		return domain.SensorData{}, "", nil, err
+	}
+
+	data := input.toSensorData()
+	errs, err := sensorService.ValidateReading(&data, backfill)
+	if err != nil {
+		return domain.SensorData{}, "", nil, err
+	}
+	if len(errs) > 0 {
+		return domain.SensorData{}, errInvalidReading, errs, nil
+	}
+	return data, "", nil, nil
+}
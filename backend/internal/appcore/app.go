@@ -1,7 +1,14 @@
 package appcore
 
 import (
+	"sync"
+
 	"github.com/nleea/fleet-monitoring/backend/internal/config"
+	"github.com/nleea/fleet-monitoring/backend/internal/migrations"
+	"github.com/nleea/fleet-monitoring/backend/internal/mqttbridge"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
 	"github.com/nleea/fleet-monitoring/backend/internal/utils"
 	"github.com/nleea/fleet-monitoring/backend/internal/ws"
 	"github.com/nleea/fleet-monitoring/backend/pkg/db"
@@ -13,6 +20,24 @@ type App struct {
 	DB     *gorm.DB
 	Logger *utils.Logger
 	Hub    *ws.Hub
+	MQTT   *mqttbridge.Subscriber
+	// Job de retención y particiones; nil si no hay ninguna de las dos.
+	Retention *service.RetentionService
+
+	sensorsOnce sync.Once
+	sensors     *service.SensorService
+
+	rateLimitsOnce sync.Once
+	rateLimits     *ratelimit.Registry
+
+	readsOnce sync.Once
+	reads     *db.ReadRouter
+
+	rulesOnce sync.Once
+	rules     *service.RuleEngine
+
+	accessOnce sync.Once
+	access     *service.DeviceAccess
 }
 
 func New(cfg *config.Config) *App {
@@ -20,9 +45,14 @@ func New(cfg *config.Config) *App {
 	logger := utils.NewLogger(cfg.Env)
 
 	// BD
-	database, err := db.ConnectPostgres(cfg.DB_DSN)
+	database, err := db.Connect(cfg.DB_DSN, cfg.DBPool())
 	if err != nil {
-		logger.Fatal("❌ No se pudo conectar a la base de datos", err)
+		logger.Fatal("❌ No se pudo conectar a la base de datos: %v", err)
+	}
+
+	// Con migraciones pendientes no se sirve: el código espera el esquema nuevo
+	if err := migrations.NewDefault(database).Check(); err != nil {
+		logger.Fatal("❌ %v; ejecute ./migrate up", err)
 	}
 
 	hub := ws.NewHub()
@@ -35,6 +65,159 @@ func New(cfg *config.Config) *App {
 		Hub:    hub,
 	}
 
+	// Réplicas de lectura
+	if len(cfg.DBReplicaDSNs) > 0 {
+		app.reads = db.ConnectReplicas(database, cfg.DBReplicaDSNs, cfg.DBPool())
+		app.reads.StartHealthChecks(cfg.DBReplicaCheckInterval)
+		healthy, total := app.reads.Healthy()
+		logger.Info("📚 Réplicas de lectura: %d de %d conectadas", healthy, total)
+	}
+
+	// Reglas de alerta; se recargan cuando otro proceso las cambia
+	if err := app.Rules().Reload(); err != nil {
+		logger.Warn("⚠️  No se pudieron cargar las reglas de alerta: %v", err)
+	}
+	app.Rules().StartReloads(cfg.AlertRulesReload)
+
+	// MQTT
+	if cfg.MQTTBrokerURL != "" {
+		sub, err := mqttbridge.New(mqttbridge.Config{
+			BrokerURL: cfg.MQTTBrokerURL,
+			ClientID:  cfg.MQTTClientID,
+			Username:  cfg.MQTTUsername,
+			Password:  cfg.MQTTPassword,
+			Topic:     cfg.MQTTTopic,
+			QoS:       byte(cfg.MQTTQoS),
+		}, repository.NewDeviceRepository(database), app.Sensors(), logger)
+		if err != nil {
+			logger.Fatal("❌ Configuración MQTT inválida: %v", err)
+		}
+		sub.Start()
+		app.MQTT = sub
+	}
+
+	// Retención, mantenimiento de particiones y limpieza de claves de idempotencia
+	var partitions repository.SensorPartitions
+	if cfg.SensorPartitioning {
+		partitions = repository.NewSensorPartitions(database)
+		if enabled, err := partitions.Enabled(); err != nil || !enabled {
+			logger.Warn("⚠️  SENSOR_PARTITIONING activo pero sensor_data no está particionada; ejecute migrate up para convertirla (%v)", err)
+			partitions = nil
+		}
+	}
+	if cfg.RetentionDays > 0 || partitions != nil || cfg.IdempotencyKeyTTL > 0 {
+		app.Retention = service.NewRetentionService(repository.NewRollupRepository(database), service.RetentionConfig{
+			Days:      cfg.RetentionDays,
+			BatchSize: cfg.RetentionBatchSize,
+			Interval:  cfg.RetentionInterval,
+		})
+		if partitions != nil {
+			app.Retention.SetPartitions(partitions, cfg.SensorPartitionsAhead)
+		}
+		app.Retention.SetIdempotencyKeys(repository.NewIdempotencyKeyRepository(database), cfg.IdempotencyKeyTTL)
+		app.Retention.Start()
+		logger.Info("🧹 Retención de lecturas: %d días (particionado: %t)", cfg.RetentionDays, partitions != nil)
+	}
+
 	logger.Info("✅ App inicializada correctamente")
 	return app
 }
+
+// Shutdown deja de recibir telemetría por MQTT, persiste lo que quedó en la
+// cola de ingesta, espera a que termine el lote de retención en curso y deja
+// de verificar cambios en las reglas de alerta. Se llama al terminar el
+// proceso, tras cerrar el servidor.
+func (a *App) Shutdown() {
+	if a.MQTT != nil {
+		a.MQTT.Stop()
+	}
+	if a.sensors != nil {
+		a.sensors.StopPipeline()
+	}
+	if a.Retention != nil {
+		a.Retention.Stop()
+	}
+	if a.rules != nil {
+		a.rules.Stop()
+	}
+}
+
+// Sensors devuelve el SensorService compartido por la API HTTP y el puente MQTT,
+// de modo que el estado de alertas por dispositivo sea uno solo.
+func (a *App) Sensors() *service.SensorService {
+	a.sensorsOnce.Do(func() {
+		a.sensors = service.NewSensorService(
+			repository.NewSensorRepositoryWithReplicas(a.Reads()),
+			repository.NewAlertRepository(a.DB),
+			a.Hub,
+			repository.NewDeviceRepositoryWithReplicas(a.Reads()),
+		)
+		a.sensors.SetDeviceAccess(a.DeviceAccess())
+		a.sensors.SetAttributeRepository(repository.NewDeviceAttributeRepository(a.DB))
+		a.sensors.SetRollupRepository(repository.NewRollupRepositoryWithReplicas(a.Reads()))
+		a.sensors.SetRuleEngine(a.Rules())
+		if a.Config != nil {
+			limits := service.DefaultTelemetryLimits
+			limits.MaxFuture = a.Config.IngestMaxFuture
+			limits.MaxAge = a.Config.IngestMaxAge
+			a.sensors.SetValidationLimits(limits)
+
+			a.sensors.StartPipeline(service.PipelineConfig{
+				Workers:   a.Config.IngestWorkers,
+				QueueSize: a.Config.IngestQueueSize,
+			})
+
+			a.sensors.SetRateLimits(a.RateLimits())
+			a.sensors.SetPositionStaleAfter(a.Config.PositionStaleAfter)
+			a.sensors.SetPositionRefresh(a.Config.PositionRefresh)
+		}
+	})
+	return a.sensors
+}
+
+// Rules devuelve el motor de reglas de alerta que evalúa SensorService y que
+// la API recarga al modificar las reglas.
+func (a *App) Rules() *service.RuleEngine {
+	a.rulesOnce.Do(func() {
+		a.rules = service.NewRuleEngine(repository.NewAlertRuleRepository(a.DB))
+	})
+	return a.rules
+}
+
+// DeviceAccess devuelve la caché de dispositivos y permisos que comparten la
+// ingesta y la API. Lee de la primaria: tras un Forget no debe volver a
+// cargar de una réplica atrasada el acceso que se acaba de revocar.
+func (a *App) DeviceAccess() *service.DeviceAccess {
+	a.accessOnce.Do(func() {
+		a.access = service.NewDeviceAccess(repository.NewDeviceRepository(a.DB))
+	})
+	return a.access
+}
+
+// Reads devuelve el router de lecturas: las réplicas configuradas o, sin
+// ellas, la primaria.
+func (a *App) Reads() *db.ReadRouter {
+	a.readsOnce.Do(func() {
+		if a.reads == nil {
+			a.reads = db.NewReadRouter(a.DB)
+		}
+	})
+	return a.reads
+}
+
+// RateLimits devuelve los limitadores de ingesta compartidos por el middleware
+// HTTP y SensorService.
+func (a *App) RateLimits() *ratelimit.Registry {
+	a.rateLimitsOnce.Do(func() {
+		var policy ratelimit.Policy
+		if a.Config != nil {
+			policy = ratelimit.Policy{
+				Global: ratelimit.Limit{Rate: a.Config.RateLimitGlobalRPS, Burst: a.Config.RateLimitGlobalBurst},
+				User:   ratelimit.Limit{Rate: a.Config.RateLimitUserRPS, Burst: a.Config.RateLimitUserBurst},
+				Device: ratelimit.Limit{Rate: a.Config.RateLimitDeviceRPS, Burst: a.Config.RateLimitDeviceBurst},
+			}
+		}
+		a.rateLimits = ratelimit.NewRegistry(policy)
+	})
+	return a.rateLimits
+}
@@ -4,8 +4,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
 )
 
 type Config struct {
@@ -13,6 +18,74 @@ type Config struct {
 	DB_DSN    string
 	JWTSecret string
 	Env       string
+
+	// Pool de conexiones a la BD; el driver se elige por el esquema de DB_DSN
+	// (postgres://, sqlite:// o file:).
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	// Réplicas de solo lectura para historial, agregación y exportaciones;
+	// vacío usa la primaria para todo.
+	DBReplicaDSNs          []string
+	DBReplicaCheckInterval time.Duration
+
+	// Validación de ingesta: tolerancia al futuro y antigüedad máxima de ts.
+	IngestMaxFuture time.Duration
+	IngestMaxAge    time.Duration
+
+	// Pipeline de ingesta: workers y lecturas en cola antes de responder 429.
+	IngestWorkers   int
+	IngestQueueSize int
+
+	// Límites de ingesta (peticiones/s y ráfaga); una tasa en 0 lo desactiva.
+	// El de dispositivo puede reemplazarse en cada domain.Device.
+	RateLimitGlobalRPS   float64
+	RateLimitGlobalBurst int
+	RateLimitUserRPS     float64
+	RateLimitUserBurst   int
+	RateLimitDeviceRPS   float64
+	RateLimitDeviceBurst int
+
+	// Retención de lecturas crudas en días (0 la deshabilita); las más
+	// antiguas se resumen por hora y se borran en lotes.
+	RetentionDays      int
+	RetentionBatchSize int
+	RetentionInterval  time.Duration
+
+	// Tiempo que se recuerdan las claves Idempotency-Key (0 las conserva
+	// siempre); el job de retención borra las más antiguas.
+	IdempotencyKeyTTL time.Duration
+
+	// Particionado mensual de sensor_data en PostgreSQL (opcional). El seed
+	// convierte la tabla existente; la retención crea los meses futuros.
+	SensorPartitioning    bool
+	SensorPartitionsAhead int
+
+	// Directorio donde se guardan los archivos de exportación y tiempo que se
+	// conservan; con ExportTTL en 0 no vencen.
+	ExportDir string
+	ExportTTL time.Duration
+
+	// Antigüedad a partir de la cual la última posición de un dispositivo se
+	// reporta como desactualizada.
+	PositionStaleAfter time.Duration
+	// Cada cuánto la API relee de la BD las últimas posiciones, para ver las
+	// lecturas que guardan el gateway y otros procesos.
+	PositionRefresh time.Duration
+
+	// Cada cuánto se verifica si cambiaron las reglas de alerta en la BD.
+	AlertRulesReload time.Duration
+
+	// Puente MQTT; deshabilitado si MQTTBrokerURL está vacío.
+	MQTTBrokerURL string
+	MQTTClientID  string
+	MQTTUsername  string
+	MQTTPassword  string
+	MQTTTopic     string
+	MQTTQoS       int
+
+	// Gateway TCP para rastreadores (cmd/gateway).
+	GatewayPort string
 }
 
 func Load() *Config {
@@ -32,6 +105,60 @@ func Load() *Config {
 		DB_DSN:    getEnv("DB_DSN", ""),
 		JWTSecret: getEnv("JWT_SECRET", ""),
 		Env:       getEnv("ENV", "development"),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+
+		DBReplicaDSNs:          getEnvList("DB_REPLICA_DSNS"),
+		DBReplicaCheckInterval: getEnvDuration("DB_REPLICA_CHECK_INTERVAL", 15*time.Second),
+
+		IngestMaxFuture: getEnvDuration("INGEST_MAX_FUTURE", 5*time.Minute),
+		IngestMaxAge:    getEnvDuration("INGEST_MAX_AGE", 30*24*time.Hour),
+		IngestWorkers:   getEnvInt("INGEST_WORKERS", 8),
+		IngestQueueSize: getEnvInt("INGEST_QUEUE_SIZE", 10000),
+
+		RateLimitGlobalRPS:   getEnvFloat("RATE_LIMIT_GLOBAL_RPS", 0),
+		RateLimitGlobalBurst: getEnvInt("RATE_LIMIT_GLOBAL_BURST", 0),
+		RateLimitUserRPS:     getEnvFloat("RATE_LIMIT_USER_RPS", 50),
+		RateLimitUserBurst:   getEnvInt("RATE_LIMIT_USER_BURST", 100),
+		RateLimitDeviceRPS:   getEnvFloat("RATE_LIMIT_DEVICE_RPS", 5),
+		RateLimitDeviceBurst: getEnvInt("RATE_LIMIT_DEVICE_BURST", 20),
+
+		RetentionDays:      getEnvInt("RETENTION_DAYS", 0),
+		RetentionBatchSize: getEnvInt("RETENTION_BATCH_SIZE", 5000),
+		RetentionInterval:  getEnvDuration("RETENTION_INTERVAL", time.Hour),
+
+		IdempotencyKeyTTL: getEnvDuration("IDEMPOTENCY_KEY_TTL", 72*time.Hour),
+
+		SensorPartitioning:    getEnv("SENSOR_PARTITIONING", "false") == "true",
+		SensorPartitionsAhead: getEnvInt("SENSOR_PARTITIONS_AHEAD", 3),
+
+		ExportDir: getEnv("EXPORT_DIR", "exports"),
+		ExportTTL: getEnvDuration("EXPORT_TTL", 7*24*time.Hour),
+
+		PositionStaleAfter: getEnvDuration("POSITION_STALE_AFTER", 10*time.Minute),
+		PositionRefresh:    getEnvDuration("POSITION_REFRESH", 5*time.Second),
+
+		AlertRulesReload: getEnvDuration("ALERT_RULES_RELOAD", 30*time.Second),
+
+		MQTTBrokerURL: getEnv("MQTT_BROKER_URL", ""),
+		MQTTClientID:  getEnv("MQTT_CLIENT_ID", "fleet-backend"),
+		MQTTUsername:  getEnv("MQTT_USERNAME", ""),
+		MQTTPassword:  getEnv("MQTT_PASSWORD", ""),
+		MQTTTopic:     getEnv("MQTT_TOPIC", "fleet/{external_id}/telemetry"),
+		MQTTQoS:       getEnvInt("MQTT_QOS", 1),
+
+		GatewayPort: getEnv("GATEWAY_PORT", "5027"),
+	}
+}
+
+// DBPool devuelve la configuración del pool para db.Connect.
+func (c *Config) DBPool() db.PoolConfig {
+	return db.PoolConfig{
+		MaxOpenConns:    c.DBMaxOpenConns,
+		MaxIdleConns:    c.DBMaxIdleConns,
+		ConnMaxLifetime: c.DBConnMaxLifetime,
 	}
 }
 
@@ -41,3 +168,53 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvList separa por comas, descartando los elementos vacíos.
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, fallback int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("⚠️  %s=%q no es un entero, usando %d", key, val, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		log.Printf("⚠️  %s=%q no es un número, usando %g", key, val, fallback)
+		return fallback
+	}
+	return f
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.Printf("⚠️  %s=%q no es una duración válida, usando %s", key, val, fallback)
+		return fallback
+	}
+	return d
+}
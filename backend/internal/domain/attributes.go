@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Attributes son las métricas adicionales de una lectura (odómetro, RPM,
+// voltaje, ignición...), declaradas por dispositivo en DeviceAttribute.
+// Se guardan como JSONB en PostgreSQL y como JSON en texto en SQLite.
+type Attributes map[string]any
+
+func (a Attributes) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (a *Attributes) Scan(value any) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("attributes: tipo no soportado %T", value)
+	}
+	if len(raw) == 0 {
+		*a = nil
+		return nil
+	}
+	return json.Unmarshal(raw, a)
+}
+
+func (Attributes) GormDataType() string {
+	return "json"
+}
+
+func (Attributes) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
+
+// Tipos admitidos para un atributo de telemetría.
+const (
+	AttributeNumber  = "number"
+	AttributeInteger = "integer"
+	AttributeBoolean = "boolean"
+	AttributeString  = "string"
+)
+
+// DeviceAttribute declara un atributo que el dispositivo puede reportar.
+type DeviceAttribute struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	DeviceID  uint      `gorm:"not null;uniqueIndex:idx_device_attribute_name" json:"-"`
+	Device    Device    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Name      string    `gorm:"size:64;not null;uniqueIndex:idx_device_attribute_name" json:"name"`
+	Unit      string    `gorm:"size:16" json:"unit"`
+	Type      string    `gorm:"size:16;not null" json:"type"`
+	CreatedAt time.Time `json:"-"`
+}
@@ -29,21 +29,83 @@ type Device struct {
 	OwnerID    uint           `gorm:"index;not null"`
 	Owner      User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	MaskedID   string         `gorm:"size:64;index"`
+	// Límite de ingesta propio (lecturas/s y ráfaga); nil usa el de la config.
+	RateLimitRPS   *float64 `gorm:"column:rate_limit_rps"`
+	RateLimitBurst *int
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	DeletedAt  gorm.DeletedAt `gorm:"index"`
 }
 
+// DeviceShare concede a un usuario distinto del dueño acceso a un dispositivo.
+type DeviceShare struct {
+	DeviceID  uint   `gorm:"primaryKey;autoIncrement:false"`
+	Device    Device `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID    uint   `gorm:"primaryKey;autoIncrement:false;index"`
+	User      User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	CreatedAt time.Time
+}
+
+// DeviceCredential es una API key de ingesta ligada a un único dispositivo.
+// Solo se guarda el hash; la clave en claro se entrega una vez al emitirla.
+type DeviceCredential struct {
+	ID         uint       `gorm:"primaryKey"`
+	DeviceID   uint       `gorm:"index;not null"`
+	Device     Device     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Name       string     `gorm:"size:100"`
+	Prefix     string     `gorm:"uniqueIndex;size:16;not null"`
+	KeyHash    string     `gorm:"size:64;not null" json:"-"`
+	CreatedBy  uint
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
+
 type SensorData struct {
 	Channel   string    `gorm:"-:all"`
 	ID          uint      `gorm:"primaryKey"`
-	DeviceID    uint      `gorm:"index;not null"`
-	TS          time.Time `gorm:"index;not null"`
+	DeviceID    uint      `gorm:"index;not null;uniqueIndex:idx_sensor_device_ts"`
+	TS          time.Time `gorm:"index;not null;uniqueIndex:idx_sensor_device_ts"`
 	Lat         float64
 	Lng         float64
 	Speed       float64
 	FuelLevel   float64
 	Temperature float64
+	Attributes  Attributes
+}
+
+// SensorRollup resume una hora de lecturas de un dispositivo. La retención lo
+// genera antes de borrar las lecturas crudas; DistanceKM suma los tramos entre
+// lecturas consecutivas dentro de la hora.
+type SensorRollup struct {
+	ID       uint      `gorm:"primaryKey"`
+	DeviceID uint      `gorm:"not null;uniqueIndex:idx_rollup_device_hour"`
+	Hour     time.Time `gorm:"not null;uniqueIndex:idx_rollup_device_hour"`
+	Count    int64
+
+	DistanceKM     float64
+	SpeedAvg       float64
+	SpeedMax       float64
+	FuelMin        float64
+	FuelMax        float64
+	FuelAvg        float64
+	TemperatureMin float64
+	TemperatureMax float64
+	TemperatureAvg float64
+
+	// Última lectura de la hora.
+	LastTS  time.Time
+	LastLat float64
+	LastLng float64
+}
+
+// IdempotencyKey recuerda la lectura producida por un Idempotency-Key ya
+// procesado, para que los reintentos del dispositivo no creen filas nuevas.
+type IdempotencyKey struct {
+	DeviceID     uint      `gorm:"primaryKey;autoIncrement:false"`
+	Key          string    `gorm:"primaryKey;size:128"`
+	SensorDataID uint      `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"index"`
 }
 
 type AlertType string
@@ -52,6 +114,16 @@ const (
 	AlertFuelLow AlertType = "fuel_low_autonomy"
 )
 
+// AlertStatus es el estado de una alerta: abierta, reconocida por un usuario
+// o resuelta (a mano o automáticamente al normalizarse la condición).
+type AlertStatus string
+
+const (
+	AlertOpen         AlertStatus = "open"
+	AlertAcknowledged AlertStatus = "acknowledged"
+	AlertResolved     AlertStatus = "resolved"
+)
+
 type Alert struct {
 	Channel   string    `gorm:"-:all"`
 	ID        uint      `gorm:"primaryKey"`
@@ -61,4 +133,76 @@ type Alert struct {
 	Payload   []byte    `gorm:"type:jsonb"`
 	Ack       bool      `gorm:"default:false;index"`
 	CreatedAt time.Time
+
+	// Regla que la generó; nil en la alerta de combustible.
+	RuleID           *uint       `gorm:"index"`
+	// Severidad de la regla al disparar; critical en la de combustible.
+	Severity         string      `gorm:"size:16;index"`
+	Status           AlertStatus `gorm:"size:16;not null;default:'open';index"`
+	AcknowledgedBy   *uint
+	AcknowledgedAt   *time.Time
+	ResolvedAt       *time.Time
+	ResolutionReason string      `gorm:"size:255"`
+}
+
+// Severidad de una regla de alerta.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// AlertRule genera una alerta de tipo AlertType cuando Metric cumple Operator
+// Threshold durante al menos DurationSeconds. La alerta queda activa hasta que
+// el valor vuelve a la normalidad con un margen de Hysteresis; con AutoResolve
+// se marca resuelta en ese momento. Sin DeviceIDs aplica a toda la flota; con
+// ellos, solo a ese grupo de dispositivos.
+type AlertRule struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Name            string    `gorm:"size:100;not null" json:"name"`
+	AlertType       AlertType `gorm:"size:64;not null" json:"alert_type"`
+	Metric          string    `gorm:"size:80;not null" json:"metric"`
+	Operator        string    `gorm:"size:8;not null" json:"operator"`
+	Threshold       float64   `gorm:"not null" json:"threshold"`
+	DurationSeconds int       `gorm:"not null;default:0" json:"duration_seconds"`
+	Hysteresis      float64   `gorm:"not null;default:0" json:"hysteresis"`
+	DeviceIDs       []uint    `gorm:"serializer:json;type:text" json:"device_ids"`
+	Severity        string    `gorm:"size:16;not null" json:"severity"`
+	Enabled         bool      `gorm:"not null;index" json:"enabled"`
+	AutoResolve     bool      `gorm:"not null;default:false" json:"auto_resolve"`
+	CreatedBy       uint      `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportRunning ExportStatus = "running"
+	ExportDone    ExportStatus = "done"
+	ExportFailed  ExportStatus = "failed"
+	// El archivo se borró al vencer EXPORT_TTL.
+	ExportExpired ExportStatus = "expired"
+)
+
+// ExportJob es una exportación asíncrona de lecturas a CSV o Parquet. El
+// archivo queda en el almacén local bajo FileName hasta que vence.
+type ExportJob struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	UserID     uint         `gorm:"index;not null" json:"user_id"`
+	User       User         `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	DeviceIDs  []uint       `gorm:"serializer:json;type:text;not null" json:"device_ids"`
+	From       time.Time    `gorm:"not null" json:"from"`
+	To         time.Time    `gorm:"not null" json:"to"`
+	Columns    []string     `gorm:"serializer:json;type:text;not null" json:"columns"`
+	Format     string       `gorm:"size:16;not null" json:"format"`
+	Status     ExportStatus `gorm:"size:16;index;not null" json:"status"`
+	Rows       int64        `json:"rows"`
+	SizeBytes  int64        `json:"size_bytes"`
+	FileName   string       `gorm:"size:255" json:"-"`
+	Error      string       `gorm:"size:500" json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
 }
@@ -0,0 +1,108 @@
+// Package export escribe lecturas de telemetría en CSV o Parquet y recorridos
+// en GPX, KML o GeoJSON, y guarda los archivos generados en un almacén local.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Column es una columna exportable de domain.SensorData.
+type Column struct {
+	Name    string
+	node    parquet.Node
+	value   func(*domain.SensorData) any
+	csvText func(*domain.SensorData) string
+}
+
+func floatColumn(name string, get func(*domain.SensorData) float64) Column {
+	return Column{
+		Name:    name,
+		node:    parquet.Leaf(parquet.DoubleType),
+		value:   func(d *domain.SensorData) any { return get(d) },
+		csvText: func(d *domain.SensorData) string { return strconv.FormatFloat(get(d), 'f', -1, 64) },
+	}
+}
+
+// Columns son todas las columnas disponibles, en el orden por defecto.
+var Columns = []Column{
+	{
+		Name:    "device_id",
+		node:    parquet.Int(64),
+		value:   func(d *domain.SensorData) any { return int64(d.DeviceID) },
+		csvText: func(d *domain.SensorData) string { return strconv.FormatUint(uint64(d.DeviceID), 10) },
+	},
+	{
+		Name:    "ts",
+		node:    parquet.Timestamp(parquet.Millisecond),
+		value:   func(d *domain.SensorData) any { return d.TS.UnixMilli() },
+		csvText: func(d *domain.SensorData) string { return d.TS.UTC().Format(time.RFC3339Nano) },
+	},
+	floatColumn("lat", func(d *domain.SensorData) float64 { return d.Lat }),
+	floatColumn("lng", func(d *domain.SensorData) float64 { return d.Lng }),
+	floatColumn("speed", func(d *domain.SensorData) float64 { return d.Speed }),
+	floatColumn("fuel_level", func(d *domain.SensorData) float64 { return d.FuelLevel }),
+	floatColumn("temperature", func(d *domain.SensorData) float64 { return d.Temperature }),
+	{
+		// Atributos extendidos como objeto JSON; vacío si la lectura no tiene.
+		Name:    "attributes",
+		node:    parquet.String(),
+		value:   func(d *domain.SensorData) any { return attributesJSON(d) },
+		csvText: attributesJSON,
+	},
+}
+
+func attributesJSON(d *domain.SensorData) string {
+	if len(d.Attributes) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(d.Attributes)
+	return string(b)
+}
+
+// ColumnNames devuelve los nombres de Columns.
+func ColumnNames() []string {
+	names := make([]string, len(Columns))
+	for i, c := range Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// SelectColumns resuelve los nombres pedidos en su orden; sin nombres devuelve
+// todas las columnas.
+func SelectColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		return Columns, nil
+	}
+	byName := make(map[string]Column, len(Columns))
+	for _, c := range Columns {
+		byName[c.Name] = c
+	}
+
+	cols := make([]Column, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		c, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("columna desconocida: %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("columna repetida: %q", name)
+		}
+		seen[name] = true
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Store guarda los archivos exportados en un directorio local.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("no se pudo crear el directorio de exportaciones: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save escribe el archivo name con fill. Se escribe en un temporal y se
+// renombra al terminar, así nunca se sirve un archivo a medias. Devuelve el
+// tamaño final.
+func (s *Store) Save(name string, fill func(io.Writer) error) (int64, error) {
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := fill(tmp); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmp.Name(), s.Path(name))
+}
+
+// Path es la ruta del archivo name dentro del almacén.
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, filepath.Base(name))
+}
+
+// Remove borra el archivo name; que ya no exista no es un error.
+func (s *Store) Remove(name string) error {
+	if err := os.Remove(s.Path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+const (
+	FormatGPX     = "gpx"
+	FormatKML     = "kml"
+	FormatGeoJSON = "geojson"
+)
+
+// TrackFormats asocia cada formato de recorrido con su Content-Type.
+var TrackFormats = map[string]string{
+	FormatGPX:     "application/gpx+xml",
+	FormatKML:     "application/vnd.google-earth.kml+xml",
+	FormatGeoJSON: "application/geo+json",
+}
+
+// flusher lo implementan los ResponseWriter que admiten envío parcial.
+type flusher interface {
+	Flush()
+}
+
+// NewTrackWriter crea el escritor del recorrido de un dispositivo en el formato
+// pedido. El encabezado se escribe de inmediato; cada bloque se vuelca al
+// destino al terminar, así una respuesta HTTP se envía mientras se genera.
+func NewTrackWriter(format string, w io.Writer, name string) (Writer, error) {
+	var tw Writer
+	base := trackBase{dst: w, bw: bufio.NewWriter(w)}
+	switch format {
+	case FormatGPX:
+		tw = &gpxWriter{trackBase: base}
+		fmt.Fprintf(base.bw, `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="fleet-monitoring" xmlns="http://www.topografix.com/GPX/1/1">
+<trk><name>%s</name><trkseg>
+`, escapeXML(name))
+	case FormatKML:
+		tw = &kmlWriter{trackBase: base}
+		fmt.Fprintf(base.bw, `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document><name>%s</name>
+`, escapeXML(name))
+	case FormatGeoJSON:
+		tw = &geoJSONWriter{trackBase: base}
+		fmt.Fprintf(base.bw, `{"type":"FeatureCollection","name":%s,"features":[`, jsonString(name))
+	default:
+		return nil, fmt.Errorf("formato no soportado: %q", format)
+	}
+	return tw, base.bw.Flush()
+}
+
+type trackBase struct {
+	dst io.Writer
+	bw  *bufio.Writer
+}
+
+func (b *trackBase) flush() error {
+	if err := b.bw.Flush(); err != nil {
+		return err
+	}
+	if f, ok := b.dst.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// gpxWriter escribe un único segmento de track con la posición y hora de cada lectura.
+type gpxWriter struct {
+	trackBase
+}
+
+func (g *gpxWriter) Write(chunk []domain.SensorData) error {
+	for _, d := range chunk {
+		fmt.Fprintf(g.bw, "<trkpt lat=\"%s\" lon=\"%s\"><time>%s</time></trkpt>\n",
+			formatCoord(d.Lat), formatCoord(d.Lng), d.TS.UTC().Format(time.RFC3339))
+	}
+	return g.flush()
+}
+
+func (g *gpxWriter) Close() error {
+	g.bw.WriteString("</trkseg></trk>\n</gpx>\n")
+	return g.flush()
+}
+
+// kmlWriter escribe un Placemark con LineString por bloque, con el TimeSpan de
+// sus lecturas. Cada tramo empieza en el último punto del anterior para que el
+// recorrido quede continuo.
+type kmlWriter struct {
+	trackBase
+	last *domain.SensorData
+}
+
+func (k *kmlWriter) Write(chunk []domain.SensorData) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	points := chunk
+	if k.last != nil {
+		points = append([]domain.SensorData{*k.last}, chunk...)
+	}
+	last := chunk[len(chunk)-1]
+	k.last = &last
+
+	// Un LineString necesita al menos dos puntos
+	if len(points) < 2 {
+		return nil
+	}
+	fmt.Fprintf(k.bw, "<Placemark><TimeSpan><begin>%s</begin><end>%s</end></TimeSpan><LineString><tessellate>1</tessellate><coordinates>\n",
+		points[0].TS.UTC().Format(time.RFC3339), last.TS.UTC().Format(time.RFC3339))
+	for _, d := range points {
+		fmt.Fprintf(k.bw, "%s,%s,0\n", formatCoord(d.Lng), formatCoord(d.Lat))
+	}
+	k.bw.WriteString("</coordinates></LineString></Placemark>\n")
+	return k.flush()
+}
+
+func (k *kmlWriter) Close() error {
+	k.bw.WriteString("</Document>\n</kml>\n")
+	return k.flush()
+}
+
+// geoJSONWriter escribe una Feature Point por lectura con sus métricas como propiedades.
+type geoJSONWriter struct {
+	trackBase
+	count int
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONPoint      `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	DeviceID    uint      `json:"device_id"`
+	TS          time.Time `json:"ts"`
+	Speed       float64   `json:"speed"`
+	FuelLevel   float64   `json:"fuel_level"`
+	Temperature float64   `json:"temperature"`
+}
+
+func (g *geoJSONWriter) Write(chunk []domain.SensorData) error {
+	for _, d := range chunk {
+		b, err := json.Marshal(geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONPoint{Type: "Point", Coordinates: [2]float64{d.Lng, d.Lat}},
+			Properties: geoJSONProperties{
+				DeviceID:    d.DeviceID,
+				TS:          d.TS.UTC(),
+				Speed:       d.Speed,
+				FuelLevel:   d.FuelLevel,
+				Temperature: d.Temperature,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if g.count > 0 {
+			g.bw.WriteByte(',')
+		}
+		g.bw.WriteByte('\n')
+		g.bw.Write(b)
+		g.count++
+	}
+	return g.flush()
+}
+
+func (g *geoJSONWriter) Close() error {
+	g.bw.WriteString("\n]}\n")
+	return g.flush()
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,185 @@
+// Package gateway recibe rastreadores GPS que hablan protocolos TCP binarios
+// y entrega sus registros al mismo flujo de ingesta que la API HTTP.
+package gateway
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/gateway/teltonika"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+// Ingester es el punto de entrada de la telemetría; lo implementa SensorService.
+// IngestTracker no consume el límite del dispositivo: se cobra una vez por
+// paquete con AllowDevice.
+type Ingester interface {
+	AllowDevice(deviceID uint) (ratelimit.Decision, error)
+	IngestTracker(deviceID uint, r service.TrackerReading) error
+}
+
+// Server atiende conexiones Teltonika Codec 8. El IMEI del equipo se mapea a
+// domain.Device.ExternalID; los IMEI no registrados se rechazan en el login.
+type Server struct {
+	devices     repository.DeviceRepository
+	ingester    Ingester
+	logger      *utils.Logger
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+}
+
+func NewServer(devices repository.DeviceRepository, ingester Ingester, logger *utils.Logger, idleTimeout time.Duration) *Server {
+	return &Server{
+		devices:     devices,
+		ingester:    ingester,
+		logger:      logger,
+		idleTimeout: idleTimeout,
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.HandleConn(conn)
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// HandleConn ejecuta la sesión completa de un equipo: login por IMEI y luego
+// paquetes AVL, respondiendo a cada uno con el número de registros aceptados.
+func (s *Server) HandleConn(conn net.Conn) {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+
+	s.touch(conn)
+	imei, err := teltonika.ReadIMEI(reader)
+	if err != nil {
+		s.logger.Warn("Gateway %s: login inválido: %v", remote, err)
+		return
+	}
+
+	device, err := s.devices.GetByExternalID(imei)
+	if err != nil {
+		s.logger.Warn("Gateway %s: IMEI %s no registrado", remote, imei)
+		conn.Write([]byte{0x00})
+		return
+	}
+	if _, err := conn.Write([]byte{0x01}); err != nil {
+		return
+	}
+	s.logger.Info("📶 Gateway: equipo %s conectado desde %s (device %d)", imei, remote, device.ID)
+
+	for {
+		s.touch(conn)
+		records, err := teltonika.ReadPacket(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				s.logger.Info("Gateway: equipo %s desconectado", imei)
+				return
+			}
+			if errors.Is(err, teltonika.ErrBadCRC) || errors.Is(err, teltonika.ErrCountDiffer) {
+				// El equipo reenvía el paquete si no se confirma ningún registro.
+				s.logger.Warn("Gateway %s: paquete descartado: %v", imei, err)
+				if _, err := conn.Write(teltonika.EncodeAck(0)); err != nil {
+					return
+				}
+				continue
+			}
+			s.logger.Warn("Gateway %s: cerrando conexión: %v", imei, err)
+			return
+		}
+
+		// Un paquete cuenta como una sola petición: cobrar por registro haría que
+		// uno con más registros que la ráfaga no se confirmara nunca.
+		if _, err := s.ingester.AllowDevice(device.ID); err != nil {
+			if _, limited := service.AsRateLimitError(err); limited {
+				// Sin confirmar, el equipo reenvía el paquete más tarde.
+				s.logger.Warn("Gateway %s: %v", imei, err)
+			} else {
+				s.logger.Error("Gateway %s: no se pudo verificar el límite de ingesta: %v", imei, err)
+			}
+			if _, err := conn.Write(teltonika.EncodeAck(0)); err != nil {
+				return
+			}
+			continue
+		}
+
+		accepted := len(records)
+		for _, rec := range records {
+			err := s.ingester.IngestTracker(device.ID, trackerReading(rec))
+			if _, invalid := service.AsValidationError(err); invalid {
+				// Reenviarlo no lo haría válido: se confirma y se descarta.
+				s.logger.Warn("Gateway %s: registro descartado: %v", imei, err)
+				continue
+			}
+			if err != nil {
+				s.logger.Error("Gateway %s: no se pudo ingerir registro %s: %v", imei, rec.Timestamp.Format(time.RFC3339), err)
+				accepted = 0
+				break
+			}
+		}
+
+		if _, err := conn.Write(teltonika.EncodeAck(accepted)); err != nil {
+			return
+		}
+	}
+}
+
+// trackerReading convierte el registro AVL; los elementos IO ausentes quedan sin valor.
+func trackerReading(rec teltonika.Record) service.TrackerReading {
+	r := service.TrackerReading{TS: rec.Timestamp, Lat: rec.Lat, Lng: rec.Lng, Speed: float64(rec.Speed)}
+	if fuel, ok := rec.FuelLevel(); ok {
+		r.FuelLevel = &fuel
+	}
+	if temp, ok := rec.Temperature(); ok {
+		r.Temperature = &temp
+	}
+	return r
+}
+
+func (s *Server) touch(conn net.Conn) {
+	if s.idleTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.idleTimeout))
+	}
+}
@@ -0,0 +1,241 @@
+// Package teltonika implementa el protocolo TCP de los rastreadores Teltonika
+// (FMB/FMC/FMM) con Codec 8: handshake por IMEI, paquetes AVL y CRC-16/IBM.
+package teltonika
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	Codec8 byte = 0x08
+
+	// IDs de elementos IO usados por la plataforma.
+	IOFuelLevel   uint16 = 89 // nivel de combustible en %
+	IODallasTemp1 uint16 = 72 // sensor Dallas 1, en décimas de °C (con signo)
+
+	maxIMEILength = 17
+	// Límite de seguridad para el campo Data Length de un paquete.
+	maxPacketLength = 64 * 1024
+)
+
+var (
+	ErrBadPreamble = errors.New("teltonika: preámbulo inválido")
+	ErrBadCRC      = errors.New("teltonika: CRC inválido")
+	ErrBadCodec    = errors.New("teltonika: codec no soportado")
+	ErrBadIMEI     = errors.New("teltonika: IMEI inválido")
+	ErrBadLength   = errors.New("teltonika: longitud de paquete inválida")
+	ErrCountDiffer = errors.New("teltonika: número de registros inconsistente")
+)
+
+// Record es un registro AVL decodificado.
+type Record struct {
+	Timestamp  time.Time
+	Priority   byte
+	Lat        float64
+	Lng        float64
+	Altitude   int16
+	Angle      uint16
+	Satellites byte
+	Speed      uint16 // km/h
+	EventIO    uint16
+	// Valores IO por ID, sin escalar ni signo.
+	IO map[uint16]uint64
+	// Ancho en bytes con que el equipo envió cada elemento IO.
+	ioWidth map[uint16]int
+}
+
+// FuelLevel devuelve el nivel de combustible (%) si el equipo lo reporta.
+func (r Record) FuelLevel() (float64, bool) {
+	v, ok := r.IO[IOFuelLevel]
+	return float64(v), ok
+}
+
+// Temperature devuelve la temperatura del sensor Dallas 1 en °C si el equipo la reporta.
+func (r Record) Temperature() (float64, bool) {
+	v, ok := r.SignedIO(IODallasTemp1)
+	return float64(v) / 10.0, ok
+}
+
+// SignedIO interpreta el elemento IO id como entero con signo, extendiendo el
+// signo desde el ancho con que se recibió (1, 2, 4 u 8 bytes).
+func (r Record) SignedIO(id uint16) (int64, bool) {
+	v, ok := r.IO[id]
+	if !ok {
+		return 0, false
+	}
+	switch r.ioWidth[id] {
+	case 1:
+		return int64(int8(v)), true
+	case 2:
+		return int64(int16(v)), true
+	case 4:
+		return int64(int32(v)), true
+	default:
+		return int64(v), true
+	}
+}
+
+// ReadIMEI lee el paquete de login: 2 bytes de longitud seguidos del IMEI en ASCII.
+func ReadIMEI(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	if n == 0 || n > maxIMEILength {
+		return "", ErrBadIMEI
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	for _, b := range buf {
+		if b < '0' || b > '9' {
+			return "", ErrBadIMEI
+		}
+	}
+	return string(buf), nil
+}
+
+// ReadPacket lee un paquete AVL completo y valida preámbulo, CRC y conteos.
+func ReadPacket(r *bufio.Reader) ([]Record, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header[:4]) != 0 {
+		return nil, ErrBadPreamble
+	}
+
+	length := binary.BigEndian.Uint32(header[4:])
+	if length < 3 || length > maxPacketLength {
+		return nil, ErrBadLength
+	}
+
+	body := make([]byte, length+4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	data, crcField := body[:length], body[length:]
+	if uint32(CRC16(data)) != binary.BigEndian.Uint32(crcField) {
+		return nil, ErrBadCRC
+	}
+
+	return DecodeData(data)
+}
+
+// DecodeData decodifica el campo de datos (desde el Codec ID hasta Number of Data 2).
+func DecodeData(data []byte) ([]Record, error) {
+	d := decoder{buf: data}
+
+	if codec := d.u8(); codec != Codec8 {
+		return nil, fmt.Errorf("%w: 0x%02X", ErrBadCodec, codec)
+	}
+
+	count := int(d.u8())
+	records := make([]Record, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		records = append(records, d.record())
+	}
+
+	count2 := int(d.u8())
+	if d.err != nil {
+		return nil, d.err
+	}
+	if count != count2 || d.off != len(data) {
+		return nil, ErrCountDiffer
+	}
+	return records, nil
+}
+
+// EncodeAck es la respuesta del servidor: número de registros aceptados (4 bytes).
+func EncodeAck(accepted int) []byte {
+	ack := make([]byte, 4)
+	binary.BigEndian.PutUint32(ack, uint32(accepted))
+	return ack
+}
+
+// CRC16 calcula CRC-16/IBM (polinomio 0xA001 reflejado, valor inicial 0).
+func CRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+type decoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if d.off+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) u8() byte       { return d.take(1)[0] }
+func (d *decoder) u16() uint16    { return binary.BigEndian.Uint16(d.take(2)) }
+func (d *decoder) u32() uint32    { return binary.BigEndian.Uint32(d.take(4)) }
+func (d *decoder) u64() uint64    { return binary.BigEndian.Uint64(d.take(8)) }
+func (d *decoder) coord() float64 { return float64(int32(d.u32())) / 1e7 }
+
+// uintN lee un entero sin signo big-endian de width bytes.
+func (d *decoder) uintN(width int) uint64 {
+	var v uint64
+	for _, b := range d.take(width) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (d *decoder) record() Record {
+	rec := Record{
+		Timestamp: time.UnixMilli(int64(d.u64())).UTC(),
+		Priority:  d.u8(),
+		IO:        make(map[uint16]uint64),
+		ioWidth:   make(map[uint16]int),
+	}
+
+	rec.Lng = d.coord()
+	rec.Lat = d.coord()
+	rec.Altitude = int16(d.u16())
+	rec.Angle = d.u16()
+	rec.Satellites = d.u8()
+	rec.Speed = d.u16()
+
+	rec.EventIO = uint16(d.u8())
+	d.u8() // total de elementos IO, redundante con los conteos por tamaño
+
+	for _, width := range []int{1, 2, 4, 8} {
+		for n := d.u8(); n > 0 && d.err == nil; n-- {
+			id := uint16(d.u8())
+			rec.IO[id] = d.uintN(width)
+			rec.ioWidth[id] = width
+		}
+	}
+
+	return rec
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+type DeviceKeyAuthenticator interface {
+	Authenticate(key string) (*domain.DeviceCredential, error)
+}
+
+// DeviceKeyAuth autentica dispositivos por API key (header X-Device-Key o
+// "Authorization: ApiKey <clave>") y fija en el contexto el dispositivo de la
+// credencial; los handlers de ingesta rechazan cualquier otro device_id.
+func DeviceKeyAuth(auth DeviceKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Device-Key")
+		if key == "" {
+			if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "ApiKey ") {
+				key = strings.TrimPrefix(authHeader, "ApiKey ")
+			}
+		}
+
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key de dispositivo faltante"})
+			return
+		}
+
+		cred, err := auth.Authenticate(key)
+		if errors.Is(err, service.ErrInvalidDeviceKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			// Un fallo de la BD no es una clave inválida: el dispositivo debe reintentar
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "no se pudo validar la API key"})
+			return
+		}
+
+		c.Set("deviceID", cred.DeviceID)
+		c.Set("credentialID", cred.ID)
+		c.Set("role", "device")
+
+		c.Next()
+	}
+}
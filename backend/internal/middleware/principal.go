@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+// CurrentPrincipal arma el principal a partir de lo que dejaron JWTAuth o
+// DeviceKeyAuth en el contexto.
+func CurrentPrincipal(c *gin.Context) service.Principal {
+	return service.Principal{
+		UserID:   c.GetUint("userID"),
+		Role:     c.GetString("role"),
+		DeviceID: c.GetUint("deviceID"),
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
+)
+
+const rateLimitKey = "rateLimit"
+
+// RateLimit aplica los límites global y por usuario antes de la ingesta. El
+// límite por dispositivo lo aplica SensorService, que conoce el device_id.
+func RateLimit(limits *ratelimit.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := limits.AllowRequest(c.GetUint("userID"))
+		SetRateLimitHeaders(c, d)
+		if !d.Allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "límite de peticiones excedido"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// SetRateLimitHeaders escribe RateLimit-Limit, RateLimit-Remaining y
+// RateLimit-Reset con la decisión más restrictiva vista en la petición, y
+// Retry-After si fue rechazada.
+func SetRateLimitHeaders(c *gin.Context, d ratelimit.Decision) {
+	if prev, ok := c.Get(rateLimitKey); ok {
+		d = ratelimit.Tighter(prev.(ratelimit.Decision), d)
+	}
+	c.Set(rateLimitKey, d)
+	if d.Limit == 0 {
+		return
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(d.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	c.Header("RateLimit-Reset", d.ResetSeconds())
+	if !d.Allowed {
+		c.Header("Retry-After", d.RetryAfterSeconds())
+	}
+}
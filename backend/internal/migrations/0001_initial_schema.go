@@ -0,0 +1,220 @@
+package migrations
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// initialSchema crea las tablas que antes creaba el AutoMigrate del seed. Es
+// idempotente: en una base creada por el seed solo registra la versión.
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		if err := dedupeSensorData(tx); err != nil {
+			return err
+		}
+		return tx.AutoMigrate(initialModels...)
+	},
+	Down: func(tx *gorm.DB) error {
+		for i := len(initialModels) - 1; i >= 0; i-- {
+			if err := tx.Migrator().DropTable(initialModels[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// En orden de dependencia: Down los borra al revés.
+var initialModels = []any{
+	&v1User{},
+	&v1Device{},
+	&v1SensorData{},
+	&v1Alert{},
+	&v1DeviceShare{},
+	&v1DeviceAttribute{},
+	&v1DeviceCredential{},
+	&v1IdempotencyKey{},
+	&v1SensorRollup{},
+	&v1ExportJob{},
+}
+
+// Copias de los modelos de domain tal como eran en la versión 1. No deben
+// cambiar aunque cambie domain: los cambios de esquema van en otra migración.
+
+type v1User struct {
+	ID           uint   `gorm:"primaryKey"`
+	Email        string `gorm:"uniqueIndex;size:180;not null"`
+	PasswordHash string `gorm:"not null"`
+	Role         string `gorm:"type:VARCHAR(10);not null;default:'user'"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Device struct {
+	ID             uint     `gorm:"primaryKey"`
+	ExternalID     string   `gorm:"uniqueIndex;size:64;not null"`
+	OwnerID        uint     `gorm:"index;not null"`
+	Owner          v1User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	MaskedID       string   `gorm:"size:64;index"`
+	RateLimitRPS   *float64 `gorm:"column:rate_limit_rps"`
+	RateLimitBurst *int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Device) TableName() string { return "devices" }
+
+type v1DeviceShare struct {
+	DeviceID  uint     `gorm:"primaryKey;autoIncrement:false"`
+	Device    v1Device `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID    uint     `gorm:"primaryKey;autoIncrement:false;index"`
+	User      v1User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CreatedAt time.Time
+}
+
+func (v1DeviceShare) TableName() string { return "device_shares" }
+
+type v1DeviceCredential struct {
+	ID         uint     `gorm:"primaryKey"`
+	DeviceID   uint     `gorm:"index;not null"`
+	Device     v1Device `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Name       string   `gorm:"size:100"`
+	Prefix     string   `gorm:"uniqueIndex;size:16;not null"`
+	KeyHash    string   `gorm:"size:64;not null"`
+	CreatedBy  uint
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
+
+func (v1DeviceCredential) TableName() string { return "device_credentials" }
+
+type v1DeviceAttribute struct {
+	ID        uint     `gorm:"primaryKey"`
+	DeviceID  uint     `gorm:"not null;uniqueIndex:idx_device_attribute_name"`
+	Device    v1Device `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Name      string   `gorm:"size:64;not null;uniqueIndex:idx_device_attribute_name"`
+	Unit      string   `gorm:"size:16"`
+	Type      string   `gorm:"size:16;not null"`
+	CreatedAt time.Time
+}
+
+func (v1DeviceAttribute) TableName() string { return "device_attributes" }
+
+type v1SensorData struct {
+	ID          uint      `gorm:"primaryKey"`
+	DeviceID    uint      `gorm:"index;not null;uniqueIndex:idx_sensor_device_ts"`
+	TS          time.Time `gorm:"index;not null;uniqueIndex:idx_sensor_device_ts"`
+	Lat         float64
+	Lng         float64
+	Speed       float64
+	FuelLevel   float64
+	Temperature float64
+	Attributes  v1JSON
+}
+
+func (v1SensorData) TableName() string { return "sensor_data" }
+
+// v1JSON es la columna de atributos: jsonb en PostgreSQL y text en el resto.
+type v1JSON []byte
+
+func (v1JSON) GormDataType() string { return "json" }
+
+func (v1JSON) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
+
+type v1SensorRollup struct {
+	ID       uint      `gorm:"primaryKey"`
+	DeviceID uint      `gorm:"not null;uniqueIndex:idx_rollup_device_hour"`
+	Hour     time.Time `gorm:"not null;uniqueIndex:idx_rollup_device_hour"`
+	Count    int64
+
+	DistanceKM     float64
+	SpeedAvg       float64
+	SpeedMax       float64
+	FuelMin        float64
+	FuelMax        float64
+	FuelAvg        float64
+	TemperatureMin float64
+	TemperatureMax float64
+	TemperatureAvg float64
+
+	LastTS  time.Time
+	LastLat float64
+	LastLng float64
+}
+
+func (v1SensorRollup) TableName() string { return "sensor_rollups" }
+
+type v1IdempotencyKey struct {
+	DeviceID     uint      `gorm:"primaryKey;autoIncrement:false"`
+	Key          string    `gorm:"primaryKey;size:128"`
+	SensorDataID uint      `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+func (v1IdempotencyKey) TableName() string { return "idempotency_keys" }
+
+type v1Alert struct {
+	ID        uint      `gorm:"primaryKey"`
+	DeviceID  uint      `gorm:"index;not null"`
+	TS        time.Time `gorm:"index;not null"`
+	Type      string    `gorm:"size:64;index;not null"`
+	Payload   []byte    `gorm:"type:jsonb"`
+	Ack       bool      `gorm:"default:false;index"`
+	CreatedAt time.Time
+}
+
+func (v1Alert) TableName() string { return "alerts" }
+
+type v1ExportJob struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"index;not null"`
+	User       v1User    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	DeviceIDs  []uint    `gorm:"serializer:json;type:text;not null"`
+	From       time.Time `gorm:"not null"`
+	To         time.Time `gorm:"not null"`
+	Columns    []string  `gorm:"serializer:json;type:text;not null"`
+	Format     string    `gorm:"size:16;not null"`
+	Status     string    `gorm:"size:16;index;not null"`
+	Rows       int64
+	SizeBytes  int64
+	FileName   string `gorm:"size:255"`
+	Error      string `gorm:"size:500"`
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+func (v1ExportJob) TableName() string { return "export_jobs" }
+
+// dedupeSensorData elimina lecturas duplicadas (mismo device_id y ts) antes de
+// crear el índice único, en bases anteriores a él.
+func dedupeSensorData(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&v1SensorData{}) || tx.Migrator().HasIndex(&v1SensorData{}, "idx_sensor_device_ts") {
+		return nil
+	}
+	res := tx.Exec(`DELETE FROM sensor_data WHERE id NOT IN (
+		SELECT MIN(id) FROM sensor_data GROUP BY device_id, ts
+	)`)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🧹 %d lecturas duplicadas eliminadas", res.RowsAffected)
+	}
+	return nil
+}
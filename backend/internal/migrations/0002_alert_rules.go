@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// alertRules crea la tabla de reglas de alerta configurables.
+var alertRules = Migration{
+	Version: 2,
+	Name:    "alert_rules",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v2AlertRule{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&v2AlertRule{})
+	},
+}
+
+// v2AlertRule es domain.AlertRule tal como era en la versión 2.
+type v2AlertRule struct {
+	ID              uint    `gorm:"primaryKey"`
+	Name            string  `gorm:"size:100;not null"`
+	AlertType       string  `gorm:"size:64;not null"`
+	Metric          string  `gorm:"size:80;not null"`
+	Operator        string  `gorm:"size:8;not null"`
+	Threshold       float64 `gorm:"not null"`
+	DurationSeconds int     `gorm:"not null;default:0"`
+	Hysteresis      float64 `gorm:"not null;default:0"`
+	DeviceIDs       []uint  `gorm:"serializer:json;type:text"`
+	Severity        string  `gorm:"size:16;not null"`
+	Enabled         bool    `gorm:"not null;index"`
+	CreatedBy       uint
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (v2AlertRule) TableName() string { return "alert_rules" }
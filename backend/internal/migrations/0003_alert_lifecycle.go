@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// alertLifecycle agrega a las alertas el estado, quién y cuándo las reconoció
+// y su resolución, y a las reglas la opción de resolver automáticamente. Las
+// alertas ya reconocidas pasan a estado acknowledged.
+var alertLifecycle = Migration{
+	Version: 3,
+	Name:    "alert_lifecycle",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&v3Alert{}, &v3AlertRule{}); err != nil {
+			return err
+		}
+		return tx.Model(&v3Alert{}).
+			Where("ack = ? AND status = ?", true, "open").
+			Update("status", "acknowledged").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		m := tx.Migrator()
+		for _, col := range []string{"RuleID", "Status", "AcknowledgedBy", "AcknowledgedAt", "ResolvedAt", "ResolutionReason"} {
+			if m.HasColumn(&v3Alert{}, col) {
+				if err := m.DropColumn(&v3Alert{}, col); err != nil {
+					return err
+				}
+			}
+		}
+		if m.HasColumn(&v3AlertRule{}, "AutoResolve") {
+			return m.DropColumn(&v3AlertRule{}, "AutoResolve")
+		}
+		return nil
+	},
+}
+
+// v3Alert y v3AlertRule son los modelos de domain tal como eran en la versión 3.
+type v3Alert struct {
+	ID        uint      `gorm:"primaryKey"`
+	DeviceID  uint      `gorm:"index;not null"`
+	TS        time.Time `gorm:"index;not null"`
+	Type      string    `gorm:"size:64;index;not null"`
+	Payload   []byte    `gorm:"type:jsonb"`
+	Ack       bool      `gorm:"default:false;index"`
+	CreatedAt time.Time
+
+	RuleID           *uint  `gorm:"index"`
+	Status           string `gorm:"size:16;not null;default:'open';index"`
+	AcknowledgedBy   *uint
+	AcknowledgedAt   *time.Time
+	ResolvedAt       *time.Time
+	ResolutionReason string `gorm:"size:255"`
+}
+
+func (v3Alert) TableName() string { return "alerts" }
+
+type v3AlertRule struct {
+	v2AlertRule
+	AutoResolve bool `gorm:"not null;default:false"`
+}
+
+func (v3AlertRule) TableName() string { return "alert_rules" }
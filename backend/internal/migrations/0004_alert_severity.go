@@ -0,0 +1,43 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// alertSeverity guarda en cada alerta la severidad con la que se generó, para
+// que no dependa de la regla, que puede cambiar o borrarse. Las alertas
+// existentes toman la severidad actual de su regla; las de combustible,
+// critical.
+var alertSeverity = Migration{
+	Version: 4,
+	Name:    "alert_severity",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&v4Alert{}); err != nil {
+			return err
+		}
+		err := tx.Exec(`UPDATE alerts SET severity = (
+			SELECT severity FROM alert_rules WHERE alert_rules.id = alerts.rule_id
+		) WHERE rule_id IS NOT NULL`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE alerts SET severity = 'critical' WHERE type = 'fuel_low_autonomy'`).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		m := tx.Migrator()
+		if m.HasIndex(&v4Alert{}, "Severity") {
+			if err := m.DropIndex(&v4Alert{}, "Severity"); err != nil {
+				return err
+			}
+		}
+		if m.HasColumn(&v4Alert{}, "Severity") {
+			return m.DropColumn(&v4Alert{}, "Severity")
+		}
+		return nil
+	},
+}
+
+// v4Alert son las columnas que la versión 4 agrega a alerts.
+type v4Alert struct {
+	Severity string `gorm:"size:16;index"`
+}
+
+func (v4Alert) TableName() string { return "alerts" }
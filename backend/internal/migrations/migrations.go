@@ -0,0 +1,24 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// All son las migraciones del esquema en orden de versión. Una migración ya
+// publicada no se modifica: los cambios van en una nueva con la versión
+// siguiente. Por eso ninguna usa los modelos de domain, que siguen cambiando,
+// sino copias propias o SQL explícito.
+var All = []Migration{
+	initialSchema,
+	alertRules,
+	alertLifecycle,
+	alertSeverity,
+}
+
+// NewDefault crea un Migrator con All.
+func NewDefault(db *gorm.DB) *Migrator {
+	m, err := New(db, All)
+	if err != nil {
+		// All es estático: un error aquí es un bug de programación
+		panic(err)
+	}
+	return m
+}
@@ -0,0 +1,341 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+// Marcador del nivel del tópico que contiene el ExternalID del dispositivo.
+const externalIDPlaceholder = "{external_id}"
+
+// Tiempo que se recuerda la resolución ExternalID -> ID de dispositivo.
+const deviceCacheTTL = 5 * time.Minute
+
+// Valores por defecto de la cola de reintentos.
+const (
+	DefaultRetryQueueSize = 1000
+	DefaultRetryBackoff   = time.Second
+	maxRetryBackoff       = 30 * time.Second
+	maxRetryAttempts      = 10
+)
+
+// ErrInvalidMessage marca los mensajes que no pueden ingerirse nunca: se
+// descartan en lugar de reintentarse.
+var ErrInvalidMessage = errors.New("mensaje MQTT inválido")
+
+// Ingester es el punto de entrada de la telemetría; lo implementa SensorService.
+type Ingester interface {
+	IngestData(deviceID uint, lat, lng, speed, fuel, temp float64, ts ...time.Time) error
+}
+
+type Config struct {
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+	// Patrón del tópico, p. ej. "fleet/{external_id}/telemetry".
+	Topic string
+	QoS   byte
+	// Cola de reintentos de las lecturas que fallaron por límite de ingesta o
+	// error de la BD; cero usa los valores por defecto.
+	RetryQueueSize int
+	RetryBackoff   time.Duration
+}
+
+// Payload publicado por el dispositivo. Si no envía ts se usa la hora de llegada.
+type telemetryMessage struct {
+	Lat         float64    `json:"lat"`
+	Lng         float64    `json:"lng"`
+	Speed       float64    `json:"speed"`
+	FuelLevel   float64    `json:"fuel_level"`
+	Temperature float64    `json:"temperature"`
+	TS          *time.Time `json:"ts"`
+}
+
+// retryItem es una lectura ya confirmada al broker que se reintenta.
+type retryItem struct {
+	topic    string
+	payload  []byte
+	attempts int
+	next     time.Time
+}
+
+type cachedDevice struct {
+	id      uint
+	expires time.Time
+}
+
+// Subscriber escucha el broker MQTT y reenvía cada lectura a SensorService.
+type Subscriber struct {
+	cfg      Config
+	devices  repository.DeviceRepository
+	ingester Ingester
+	logger   *utils.Logger
+	client   paho.Client
+
+	levels  []string
+	idLevel int
+	filter  string
+
+	mu    sync.Mutex
+	cache map[string]cachedDevice
+
+	retries   chan retryItem
+	retryOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	retryDone chan struct{}
+}
+
+func New(cfg Config, devices repository.DeviceRepository, ingester Ingester, logger *utils.Logger) (*Subscriber, error) {
+	if cfg.BrokerURL == "" {
+		return nil, errors.New("MQTT: broker URL requerido")
+	}
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("MQTT: QoS inválido %d", cfg.QoS)
+	}
+
+	levels := strings.Split(cfg.Topic, "/")
+	idLevel := -1
+	for i, level := range levels {
+		if level == externalIDPlaceholder {
+			if idLevel != -1 {
+				return nil, fmt.Errorf("MQTT: el tópico %q repite %s", cfg.Topic, externalIDPlaceholder)
+			}
+			idLevel = i
+		}
+	}
+	if idLevel == -1 {
+		return nil, fmt.Errorf("MQTT: el tópico %q debe contener el nivel %s", cfg.Topic, externalIDPlaceholder)
+	}
+
+	if cfg.RetryQueueSize <= 0 {
+		cfg.RetryQueueSize = DefaultRetryQueueSize
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultRetryBackoff
+	}
+
+	filterLevels := append([]string(nil), levels...)
+	filterLevels[idLevel] = "+"
+
+	return &Subscriber{
+		cfg:      cfg,
+		devices:  devices,
+		ingester: ingester,
+		logger:   logger,
+		levels:   levels,
+		idLevel:  idLevel,
+		filter:   strings.Join(filterLevels, "/"),
+		cache:    make(map[string]cachedDevice),
+		retries:  make(chan retryItem, cfg.RetryQueueSize),
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Filter devuelve el filtro de suscripción derivado del patrón ("fleet/+/telemetry").
+func (s *Subscriber) Filter() string {
+	return s.filter
+}
+
+// Start conecta en segundo plano; si el broker no está disponible se reintenta
+// sin bloquear el arranque. La suscripción se renueva en cada reconexión.
+// Cada mensaje se confirma al recibirse: uno sin confirmar no se reentrega
+// en la sesión activa y ocupa la ventana de mensajes en vuelo del broker.
+func (s *Subscriber) Start() {
+	opts := paho.NewClientOptions().
+		AddBroker(s.cfg.BrokerURL).
+		SetClientID(s.cfg.ClientID).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOnConnectHandler(func(c paho.Client) {
+			token := c.Subscribe(s.filter, s.cfg.QoS, func(_ paho.Client, msg paho.Message) {
+				s.Deliver(msg.Topic(), msg.Payload())
+				msg.Ack()
+			})
+			if token.Wait() && token.Error() != nil {
+				s.logger.Error("MQTT: no se pudo suscribir a %s: %v", s.filter, token.Error())
+				return
+			}
+			s.logger.Info("📡 MQTT suscrito a %s (QoS %d)", s.filter, s.cfg.QoS)
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			s.logger.Warn("MQTT: conexión perdida: %v", err)
+		})
+
+	s.client = paho.NewClient(opts)
+	s.client.Connect()
+}
+
+// Stop desconecta del broker y detiene los reintentos; las lecturas que
+// seguían en la cola se pierden.
+func (s *Subscriber) Stop() {
+	if s.client != nil {
+		s.client.Disconnect(250)
+	}
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.retryOnce.Do(func() {})
+	if s.retryDone != nil {
+		<-s.retryDone
+	}
+}
+
+// Deliver procesa un mensaje recibido. Si falla por un error reintentable se
+// encola para reintentarlo con espera creciente; con la cola llena se descarta.
+func (s *Subscriber) Deliver(topic string, payload []byte) {
+	err := s.HandleMessage(topic, payload)
+	if Retryable(err) {
+		s.logger.Warn("MQTT: lectura en %s se reintentará: %v", topic, err)
+		s.retry(retryItem{topic: topic, payload: payload})
+		return
+	}
+	if err != nil {
+		s.logger.Warn("MQTT: mensaje descartado en %s: %v", topic, err)
+	}
+}
+
+func (s *Subscriber) retry(item retryItem) {
+	item.attempts++
+	if item.attempts > maxRetryAttempts {
+		s.logger.Error("MQTT: lectura en %s descartada tras %d intentos", item.topic, maxRetryAttempts)
+		return
+	}
+	backoff := s.cfg.RetryBackoff << (item.attempts - 1)
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	item.next = time.Now().Add(backoff)
+
+	s.retryOnce.Do(func() {
+		s.retryDone = make(chan struct{})
+		go s.retryLoop()
+	})
+	select {
+	case s.retries <- item:
+	default:
+		s.logger.Error("MQTT: cola de reintentos llena, lectura en %s descartada", item.topic)
+	}
+}
+
+func (s *Subscriber) retryLoop() {
+	defer close(s.retryDone)
+	for {
+		var item retryItem
+		select {
+		case <-s.stop:
+			return
+		case item = <-s.retries:
+		}
+
+		if wait := time.Until(item.next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		err := s.HandleMessage(item.topic, item.payload)
+		if Retryable(err) {
+			s.retry(item)
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("MQTT: mensaje descartado en %s: %v", item.topic, err)
+		}
+	}
+}
+
+// Retryable indica si la lectura debe reintentarse: el dispositivo superó su
+// límite o la BD falló. Los mensajes inválidos y las lecturas rechazadas por
+// validación no mejoran al reintentarse.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, ErrInvalidMessage) {
+		return false
+	}
+	_, invalid := service.AsValidationError(err)
+	return !invalid
+}
+
+// HandleMessage resuelve el dispositivo a partir del tópico y entrega la lectura.
+// Los mensajes que nunca podrán ingerirse devuelven un error con ErrInvalidMessage.
+func (s *Subscriber) HandleMessage(topic string, payload []byte) error {
+	externalID, ok := s.ExternalIDFromTopic(topic)
+	if !ok {
+		return fmt.Errorf("%w: tópico %q no coincide con %q", ErrInvalidMessage, topic, s.cfg.Topic)
+	}
+
+	var msg telemetryMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("%w: JSON inválido: %w", ErrInvalidMessage, err)
+	}
+
+	deviceID, err := s.resolveDevice(externalID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: dispositivo %q desconocido", ErrInvalidMessage, externalID)
+	}
+	if err != nil {
+		return fmt.Errorf("no se pudo resolver el dispositivo %q: %w", externalID, err)
+	}
+
+	ts := time.Now().UTC()
+	if msg.TS != nil {
+		ts = *msg.TS
+	}
+
+	return s.ingester.IngestData(deviceID, msg.Lat, msg.Lng, msg.Speed, msg.FuelLevel, msg.Temperature, ts)
+}
+
+// ExternalIDFromTopic extrae el ExternalID de un tópico concreto según el patrón.
+func (s *Subscriber) ExternalIDFromTopic(topic string) (string, bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != len(s.levels) {
+		return "", false
+	}
+	for i, level := range s.levels {
+		if i != s.idLevel && level != "+" && level != parts[i] {
+			return "", false
+		}
+	}
+	if parts[s.idLevel] == "" {
+		return "", false
+	}
+	return parts[s.idLevel], true
+}
+
+func (s *Subscriber) resolveDevice(externalID string) (uint, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[externalID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.id, nil
+	}
+
+	device, err := s.devices.GetByExternalID(externalID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.cache[externalID] = cachedDevice{id: device.ID, expires: time.Now().Add(deviceCacheTTL)}
+	s.mu.Unlock()
+	return device.ID, nil
+}
@@ -0,0 +1,143 @@
+// Package ratelimit implementa token buckets por clave para limitar la ingesta
+// globalmente, por usuario y por dispositivo.
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cada cuánto se descartan los buckets llenos (inactivos).
+const sweepInterval = time.Minute
+
+// Limit es una tasa sostenida (tokens por segundo) con ráfagas de hasta Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled es falso para un límite en cero, que significa "sin límite".
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Decision es el resultado de consumir un token; sus campos alimentan las
+// cabeceras RateLimit-*.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // hasta que el bucket vuelva a estar lleno
+	RetryAfter time.Duration // hasta que haya un token, si se rechazó
+}
+
+// ResetSeconds redondea Reset hacia arriba, como piden las cabeceras.
+func (d Decision) ResetSeconds() string {
+	return strconv.Itoa(int(math.Ceil(d.Reset.Seconds())))
+}
+
+// RetryAfterSeconds devuelve al menos 1.
+func (d Decision) RetryAfterSeconds() string {
+	s := int(math.Ceil(d.RetryAfter.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return strconv.Itoa(s)
+}
+
+// Tighter devuelve la decisión más restrictiva de las dos.
+func Tighter(a, b Decision) Decision {
+	if a.Allowed != b.Allowed {
+		if !a.Allowed {
+			return a
+		}
+		return b
+	}
+	if b.Limit > 0 && (a.Limit == 0 || b.Remaining < a.Remaining) {
+		return b
+	}
+	return a
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// Limiter guarda un bucket por clave. Es seguro para uso concurrente.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+
+	rejected atomic.Uint64
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow consume un token del bucket de key. Con un límite deshabilitado
+// siempre permite y devuelve una decisión vacía.
+func (l *Limiter) Allow(key string, limit Limit) Decision {
+	if !limit.Enabled() {
+		return Decision{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	// Un cambio de límite (p. ej. el propio del dispositivo) empieza lleno.
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	burst := float64(limit.Burst)
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	d := Decision{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+		l.rejected.Add(1)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((burst - b.tokens) / limit.Rate)
+	return d
+}
+
+// Rejected es el total de peticiones rechazadas por este limitador.
+func (l *Limiter) Rejected() uint64 {
+	return l.rejected.Load()
+}
+
+// sweep elimina los buckets que ya se habrían rellenado por completo: volver a
+// crearlos da el mismo resultado. Se llama con mu tomado.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > seconds(float64(b.limit.Burst)/b.limit.Rate) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import "strconv"
+
+// Policy son los límites por defecto de cada ámbito; un Limit en cero lo desactiva.
+type Policy struct {
+	Global Limit
+	User   Limit
+	Device Limit
+}
+
+// Stats son los rechazos acumulados por ámbito, para monitoreo.
+type Stats struct {
+	Global uint64 `json:"global"`
+	User   uint64 `json:"user"`
+	Device uint64 `json:"device"`
+}
+
+// Registry agrupa los limitadores de la ingesta.
+type Registry struct {
+	policy Policy
+	global *Limiter
+	user   *Limiter
+	device *Limiter
+}
+
+func NewRegistry(policy Policy) *Registry {
+	return &Registry{
+		policy: policy,
+		global: NewLimiter(),
+		user:   NewLimiter(),
+		device: NewLimiter(),
+	}
+}
+
+// AllowRequest aplica el límite global y, si hay usuario, el suyo. Devuelve
+// la decisión más restrictiva.
+func (r *Registry) AllowRequest(userID uint) Decision {
+	d := r.global.Allow("global", r.policy.Global)
+	if !d.Allowed {
+		return d
+	}
+	if userID != 0 {
+		d = Tighter(d, r.user.Allow(strconv.FormatUint(uint64(userID), 10), r.policy.User))
+	}
+	return d
+}
+
+// AllowDevice aplica el límite del dispositivo; override, si no es nil, viene
+// del registro Device y reemplaza al de la política.
+func (r *Registry) AllowDevice(deviceID uint, override *Limit) Decision {
+	limit := r.policy.Device
+	if override != nil {
+		limit = *override
+	}
+	return r.device.Allow(strconv.FormatUint(uint64(deviceID), 10), limit)
+}
+
+func (r *Registry) Stats() Stats {
+	return Stats{
+		Global: r.global.Rejected(),
+		User:   r.user.Rejected(),
+		Device: r.device.Rejected(),
+	}
+}
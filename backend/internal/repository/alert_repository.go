@@ -12,7 +12,31 @@ type AlertRepository interface {
 	GetRecentByDevice(deviceID uint, since time.Time) ([]domain.Alert, error)
 	ExistsSimilar(deviceID uint, alertType domain.AlertType, window time.Duration) (bool, error)
 	GetUnacknowledged(limit int) ([]domain.Alert, error)
-	Acknowledge(alertID uint) error
+	Acknowledge(alertID, userID uint, at time.Time) error
+	GetByID(id uint) (*domain.Alert, error)
+	GetByIDs(ids []uint) ([]domain.Alert, error)
+	AcknowledgeMany(ids []uint, userID uint, at time.Time) ([]uint, error)
+	Resolve(alertID uint, reason string, at time.Time) (bool, error)
+	ResolveByRule(ruleID uint, reason string, at time.Time) ([]domain.Alert, error)
+	HasOpen(deviceID uint, alertType domain.AlertType, ruleID *uint) (bool, error)
+	ResolveOpen(deviceID uint, alertType domain.AlertType, ruleID *uint, reason string, at time.Time) ([]domain.Alert, error)
+	List(q AlertQuery) ([]domain.Alert, error)
+}
+
+// AlertQuery filtra el listado de alertas, de la más reciente a la más antigua.
+// VisibleTo distinto de cero limita a los dispositivos propios o compartidos
+// con ese usuario; BeforeTS/BeforeID continúan desde la última alerta entregada.
+type AlertQuery struct {
+	DeviceID  uint
+	Type      domain.AlertType
+	From      time.Time
+	To        time.Time
+	Ack       *bool
+	Status    domain.AlertStatus
+	VisibleTo uint
+	BeforeTS  time.Time
+	BeforeID  uint
+	Limit     int
 }
 
 type alertRepository struct {
@@ -56,8 +80,156 @@ func (r *alertRepository) GetUnacknowledged(limit int) ([]domain.Alert, error) {
 	return alerts, err
 }
 
-func (r *alertRepository) Acknowledge(alertID uint) error {
-	return r.db.Model(&domain.Alert{}).
-		Where("id = ?", alertID).
-		Update("ack", true).Error
+// Acknowledge pasa una alerta abierta a reconocida; una ya reconocida o
+// resuelta no cambia.
+func (r *alertRepository) Acknowledge(alertID, userID uint, at time.Time) error {
+	_, err := r.AcknowledgeMany([]uint{alertID}, userID, at)
+	return err
+}
+
+func (r *alertRepository) GetByID(id uint) (*domain.Alert, error) {
+	var alert domain.Alert
+	if err := r.db.First(&alert, id).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *alertRepository) GetByIDs(ids []uint) ([]domain.Alert, error) {
+	var alerts []domain.Alert
+	if len(ids) == 0 {
+		return alerts, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&alerts).Error
+	return alerts, err
+}
+
+// AcknowledgeMany pasa a reconocidas las alertas abiertas entre ids y devuelve
+// los ids que cambiaron.
+func (r *alertRepository) AcknowledgeMany(ids []uint, userID uint, at time.Time) ([]uint, error) {
+	var changed []uint
+	if len(ids) == 0 {
+		return changed, nil
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Alert{}).
+			Where("id IN ? AND status = ?", ids, domain.AlertOpen).
+			Pluck("id", &changed).Error; err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		return tx.Model(&domain.Alert{}).
+			Where("id IN ? AND status = ?", changed, domain.AlertOpen).
+			Updates(map[string]any{
+				"ack":             true,
+				"status":          domain.AlertAcknowledged,
+				"acknowledged_by": userID,
+				"acknowledged_at": at,
+			}).Error
+	})
+	return changed, err
+}
+
+// Resolve marca la alerta como resuelta y devuelve false si ya lo estaba.
+func (r *alertRepository) Resolve(alertID uint, reason string, at time.Time) (bool, error) {
+	res := r.db.Model(&domain.Alert{}).
+		Where("id = ? AND status <> ?", alertID, domain.AlertResolved).
+		Updates(map[string]any{
+			"status":            domain.AlertResolved,
+			"resolved_at":       at,
+			"resolution_reason": reason,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// HasOpen indica si el dispositivo tiene alertas sin resolver con ese tipo y
+// regla (ruleID nil: las que no provienen de una regla).
+func (r *alertRepository) HasOpen(deviceID uint, alertType domain.AlertType, ruleID *uint) (bool, error) {
+	var count int64
+	err := openAlerts(r.db.Model(&domain.Alert{}), deviceID, alertType, ruleID).Count(&count).Error
+	return count > 0, err
+}
+
+// ResolveOpen resuelve las alertas sin resolver del dispositivo con ese tipo y
+// regla (ruleID nil: las que no provienen de una regla) y las devuelve ya
+// actualizadas.
+func (r *alertRepository) ResolveOpen(deviceID uint, alertType domain.AlertType, ruleID *uint, reason string, at time.Time) ([]domain.Alert, error) {
+	return r.resolve(func(tx *gorm.DB) *gorm.DB {
+		return openAlerts(tx, deviceID, alertType, ruleID)
+	}, reason, at)
+}
+
+// ResolveByRule resuelve las alertas sin resolver de la regla en todos los
+// dispositivos y las devuelve ya actualizadas.
+func (r *alertRepository) ResolveByRule(ruleID uint, reason string, at time.Time) ([]domain.Alert, error) {
+	return r.resolve(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("rule_id = ? AND status <> ?", ruleID, domain.AlertResolved)
+	}, reason, at)
+}
+
+func (r *alertRepository) resolve(scope func(*gorm.DB) *gorm.DB, reason string, at time.Time) ([]domain.Alert, error) {
+	var alerts []domain.Alert
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := scope(tx).Order("id").Find(&alerts).Error; err != nil || len(alerts) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(alerts))
+		for i := range alerts {
+			ids[i] = alerts[i].ID
+			alerts[i].Status = domain.AlertResolved
+			alerts[i].ResolvedAt = &at
+			alerts[i].ResolutionReason = reason
+		}
+		return tx.Model(&domain.Alert{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":            domain.AlertResolved,
+			"resolved_at":       at,
+			"resolution_reason": reason,
+		}).Error
+	})
+	return alerts, err
+}
+
+func openAlerts(tx *gorm.DB, deviceID uint, alertType domain.AlertType, ruleID *uint) *gorm.DB {
+	tx = tx.Where("device_id = ? AND type = ? AND status <> ?", deviceID, alertType, domain.AlertResolved)
+	if ruleID != nil {
+		return tx.Where("rule_id = ?", *ruleID)
+	}
+	return tx.Where("rule_id IS NULL")
+}
+
+func (r *alertRepository) List(q AlertQuery) ([]domain.Alert, error) {
+	tx := r.db.Model(&domain.Alert{})
+	if q.VisibleTo != 0 {
+		owned := r.db.Model(&domain.Device{}).Select("id").Where("owner_id = ?", q.VisibleTo)
+		shared := r.db.Model(&domain.DeviceShare{}).Select("device_id").Where("user_id = ?", q.VisibleTo)
+		tx = tx.Where("device_id IN (?) OR device_id IN (?)", owned, shared)
+	}
+	if q.DeviceID != 0 {
+		tx = tx.Where("device_id = ?", q.DeviceID)
+	}
+	if q.Type != "" {
+		tx = tx.Where("type = ?", q.Type)
+	}
+	if !q.From.IsZero() {
+		tx = tx.Where("ts >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		tx = tx.Where("ts < ?", q.To)
+	}
+	if q.Ack != nil {
+		tx = tx.Where("ack = ?", *q.Ack)
+	}
+	if q.Status != "" {
+		tx = tx.Where("status = ?", q.Status)
+	}
+	if !q.BeforeTS.IsZero() {
+		tx = tx.Where("ts < ? OR (ts = ? AND id < ?)", q.BeforeTS, q.BeforeTS, q.BeforeID)
+	}
+
+	var alerts []domain.Alert
+	err := tx.Order("ts desc, id desc").Limit(q.Limit).Find(&alerts).Error
+	return alerts, err
 }
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"gorm.io/gorm"
+)
+
+// AlertRuleVersion cambia cada vez que se crea, modifica o elimina una regla.
+// UpdatedAt se lee como texto para no depender de cómo cada motor devuelve
+// MAX sobre una fecha; solo se compara.
+type AlertRuleVersion struct {
+	Count     int64
+	UpdatedAt sql.NullString
+}
+
+// OpenRuleAlert es un dispositivo con una alerta de la regla sin resolver.
+type OpenRuleAlert struct {
+	RuleID   uint
+	DeviceID uint
+}
+
+type AlertRuleRepository interface {
+	Create(rule *domain.AlertRule) error
+	Update(rule *domain.AlertRule) error
+	Delete(id uint) error
+	GetByID(id uint) (*domain.AlertRule, error)
+	List() ([]domain.AlertRule, error)
+	ListEnabled() ([]domain.AlertRule, error)
+	Version() (AlertRuleVersion, error)
+	ListOpenAlerts() ([]OpenRuleAlert, error)
+}
+
+type alertRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRuleRepository(db *gorm.DB) AlertRuleRepository {
+	return &alertRuleRepository{db: db}
+}
+
+func (r *alertRuleRepository) Create(rule *domain.AlertRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *alertRuleRepository) Update(rule *domain.AlertRule) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *alertRuleRepository) Delete(id uint) error {
+	res := r.db.Delete(&domain.AlertRule{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *alertRuleRepository) GetByID(id uint) (*domain.AlertRule, error) {
+	var rule domain.AlertRule
+	if err := r.db.First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *alertRuleRepository) List() ([]domain.AlertRule, error) {
+	rules := []domain.AlertRule{}
+	err := r.db.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *alertRuleRepository) ListEnabled() ([]domain.AlertRule, error) {
+	var rules []domain.AlertRule
+	err := r.db.Where("enabled = ?", true).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *alertRuleRepository) Version() (AlertRuleVersion, error) {
+	var v AlertRuleVersion
+	err := r.db.Model(&domain.AlertRule{}).
+		Select("COUNT(*) AS count, MAX(updated_at) AS updated_at").
+		Scan(&v).Error
+	return v, err
+}
+
+func (r *alertRuleRepository) ListOpenAlerts() ([]OpenRuleAlert, error) {
+	var open []OpenRuleAlert
+	err := r.db.Model(&domain.Alert{}).
+		Distinct("rule_id", "device_id").
+		Where("rule_id IS NOT NULL AND status <> ?", domain.AlertResolved).
+		Scan(&open).Error
+	return open, err
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DeviceAttributeRepository interface {
+	ListByDevice(deviceID uint) ([]domain.DeviceAttribute, error)
+	Replace(deviceID uint, attrs []domain.DeviceAttribute) error
+}
+
+type deviceAttributeRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceAttributeRepository(db *gorm.DB) DeviceAttributeRepository {
+	return &deviceAttributeRepository{db: db}
+}
+
+func (r *deviceAttributeRepository) ListByDevice(deviceID uint) ([]domain.DeviceAttribute, error) {
+	var attrs []domain.DeviceAttribute
+	err := r.db.Where("device_id = ?", deviceID).Order("name").Find(&attrs).Error
+	return attrs, err
+}
+
+// Replace sustituye el esquema completo del dispositivo en una transacción.
+func (r *deviceAttributeRepository) Replace(deviceID uint, attrs []domain.DeviceAttribute) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("device_id = ?", deviceID).Delete(&domain.DeviceAttribute{}).Error; err != nil {
+			return err
+		}
+		if len(attrs) == 0 {
+			return nil
+		}
+		for i := range attrs {
+			attrs[i].ID = 0
+			attrs[i].DeviceID = deviceID
+		}
+		return tx.Create(&attrs).Error
+	})
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DeviceCredentialRepository interface {
+	Create(cred *domain.DeviceCredential) error
+	ListByDevice(deviceID uint) ([]domain.DeviceCredential, error)
+	GetByPrefix(prefix string) (*domain.DeviceCredential, error)
+	Revoke(deviceID, credentialID uint) (bool, error)
+	TouchLastUsed(credentialID uint, at time.Time) error
+}
+
+type deviceCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceCredentialRepository(db *gorm.DB) DeviceCredentialRepository {
+	return &deviceCredentialRepository{db: db}
+}
+
+func (r *deviceCredentialRepository) Create(cred *domain.DeviceCredential) error {
+	return r.db.Create(cred).Error
+}
+
+func (r *deviceCredentialRepository) ListByDevice(deviceID uint) ([]domain.DeviceCredential, error) {
+	var creds []domain.DeviceCredential
+	err := r.db.Where("device_id = ?", deviceID).Order("created_at desc").Find(&creds).Error
+	return creds, err
+}
+
+func (r *deviceCredentialRepository) GetByPrefix(prefix string) (*domain.DeviceCredential, error) {
+	var cred domain.DeviceCredential
+	if err := r.db.Where("prefix = ?", prefix).First(&cred).Error; err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (r *deviceCredentialRepository) Revoke(deviceID, credentialID uint) (bool, error) {
+	res := r.db.Model(&domain.DeviceCredential{}).
+		Where("id = ? AND device_id = ? AND revoked_at IS NULL", credentialID, deviceID).
+		Update("revoked_at", time.Now().UTC())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *deviceCredentialRepository) TouchLastUsed(credentialID uint, at time.Time) error {
+	return r.db.Model(&domain.DeviceCredential{}).
+		Where("id = ?", credentialID).
+		Update("last_used_at", at).Error
+}
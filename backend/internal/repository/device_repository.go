@@ -2,39 +2,70 @@ package repository
 
 import (
 	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DeviceRepository interface {
 	Create(device *domain.Device) error
 	GetAll() ([]domain.Device, error)
 	GetByOwner(userID uint) ([]domain.Device, error)
+	GetVisibleTo(userID uint) ([]domain.Device, error)
 	GetByExternalID(extID string) (*domain.Device, error)
 	GetByDeviceIdID(deviceId uint) (*domain.Device, error)
+	SetRateLimit(deviceID uint, rps *float64, burst *int) (bool, error)
 
+	IsSharedWith(deviceID, userID uint) (bool, error)
+	Share(deviceID, userID uint) error
+	Unshare(deviceID, userID uint) (bool, error)
+	ListShares(deviceID uint) ([]domain.DeviceShare, error)
 }
 
 type deviceRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	reads *db.ReadRouter
 }
 
 func NewDeviceRepository(db *gorm.DB) DeviceRepository {
 	return &deviceRepository{db: db}
 }
 
+// NewDeviceRepositoryWithReplicas lee los listados de dispositivos de las
+// réplicas del router. Las búsquedas por id siguen en la primaria: la
+// autorización debe ver un dispositivo recién creado.
+func NewDeviceRepositoryWithReplicas(reads *db.ReadRouter) DeviceRepository {
+	return &deviceRepository{db: reads.Primary(), reads: reads}
+}
+
 func (r *deviceRepository) Create(device *domain.Device) error {
 	return r.db.Create(device).Error
 }
 
 func (r *deviceRepository) GetAll() ([]domain.Device, error) {
 	var devices []domain.Device
-	err := r.db.Preload("Owner").Order("created_at desc").Find(&devices).Error
+	err := readOnly(r.reads, r.db, func(tx *gorm.DB) error {
+		return tx.Preload("Owner").Order("created_at desc").Find(&devices).Error
+	})
 	return devices, err
 }
 
 func (r *deviceRepository) GetByOwner(userID uint) ([]domain.Device, error) {
 	var devices []domain.Device
-	err := r.db.Where("owner_id = ?", userID).Preload("Owner").Find(&devices).Error
+	err := readOnly(r.reads, r.db, func(tx *gorm.DB) error {
+		return tx.Where("owner_id = ?", userID).Preload("Owner").Find(&devices).Error
+	})
+	return devices, err
+}
+
+// GetVisibleTo devuelve los dispositivos propios del usuario y los que le
+// fueron compartidos.
+func (r *deviceRepository) GetVisibleTo(userID uint) ([]domain.Device, error) {
+	var devices []domain.Device
+	err := readOnly(r.reads, r.db, func(tx *gorm.DB) error {
+		shared := tx.Model(&domain.DeviceShare{}).Select("device_id").Where("user_id = ?", userID)
+		return tx.Where("owner_id = ? OR id IN (?)", userID, shared).Order("id").Find(&devices).Error
+	})
 	return devices, err
 }
 
@@ -54,4 +85,39 @@ func (r *deviceRepository) GetByDeviceIdID(deviceId uint) (*domain.Device, error
 		return nil, err
 	}
 	return &device, nil
-}
\ No newline at end of file
+}
+
+func (r *deviceRepository) IsSharedWith(deviceID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.DeviceShare{}).
+		Where("device_id = ? AND user_id = ?", deviceID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Share es idempotente: compartir dos veces con el mismo usuario no falla.
+func (r *deviceRepository) Share(deviceID, userID uint) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.DeviceShare{DeviceID: deviceID, UserID: userID}).Error
+}
+
+func (r *deviceRepository) Unshare(deviceID, userID uint) (bool, error) {
+	res := r.db.Where("device_id = ? AND user_id = ?", deviceID, userID).Delete(&domain.DeviceShare{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *deviceRepository) ListShares(deviceID uint) ([]domain.DeviceShare, error) {
+	var shares []domain.DeviceShare
+	err := r.db.Where("device_id = ?", deviceID).Order("created_at").Find(&shares).Error
+	return shares, err
+}
+
+// SetRateLimit guarda el límite de ingesta propio del dispositivo; con nil se
+// vuelve al de la configuración. Devuelve false si el dispositivo no existe.
+func (r *deviceRepository) SetRateLimit(deviceID uint, rps *float64, burst *int) (bool, error) {
+	res := r.db.Model(&domain.Device{}).Where("id = ?", deviceID).Updates(map[string]any{
+		"rate_limit_rps":   rps,
+		"rate_limit_burst": burst,
+	})
+	return res.RowsAffected > 0, res.Error
+}
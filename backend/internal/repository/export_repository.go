@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ExportRepository interface {
+	Create(job *domain.ExportJob) error
+	Update(job *domain.ExportJob) error
+	GetByID(id uint) (*domain.ExportJob, error)
+	ListByUser(userID uint, limit int) ([]domain.ExportJob, error)
+	FailInterrupted(reason string) (int64, error)
+	ListDoneBefore(cutoff time.Time, limit int) ([]domain.ExportJob, error)
+}
+
+type exportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) ExportRepository {
+	return &exportRepository{db: db}
+}
+
+func (r *exportRepository) Create(job *domain.ExportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *exportRepository) Update(job *domain.ExportJob) error {
+	return r.db.Save(job).Error
+}
+
+func (r *exportRepository) GetByID(id uint) (*domain.ExportJob, error) {
+	var job domain.ExportJob
+	if err := r.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *exportRepository) ListByUser(userID uint, limit int) ([]domain.ExportJob, error) {
+	jobs := []domain.ExportJob{}
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// FailInterrupted marca como fallidos los trabajos que quedaron a medias al
+// detenerse el proceso.
+func (r *exportRepository) FailInterrupted(reason string) (int64, error) {
+	res := r.db.Model(&domain.ExportJob{}).
+		Where("status IN ?", []domain.ExportStatus{domain.ExportPending, domain.ExportRunning}).
+		Updates(map[string]any{"status": domain.ExportFailed, "error": reason, "finished_at": time.Now()})
+	return res.RowsAffected, res.Error
+}
+
+// ListDoneBefore devuelve los trabajos terminados antes de cutoff, los más
+// antiguos primero.
+func (r *exportRepository) ListDoneBefore(cutoff time.Time, limit int) ([]domain.ExportJob, error) {
+	jobs := []domain.ExportJob{}
+	err := r.db.Where("status = ? AND finished_at < ?", domain.ExportDone, cutoff).
+		Order("finished_at").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IdempotencyKeyRepository interface {
+	PurgeBefore(cutoff time.Time, batchSize int) (int64, error)
+}
+
+type idempotencyKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{db: db}
+}
+
+// PurgeBefore borra hasta batchSize claves creadas antes de cutoff, las más
+// antiguas primero por el índice de created_at. Devuelve cuántas borró.
+func (r *idempotencyKeyRepository) PurgeBefore(cutoff time.Time, batchSize int) (int64, error) {
+	res := r.db.Exec(`DELETE FROM idempotency_keys WHERE (device_id, key) IN (
+		SELECT device_id, key FROM idempotency_keys WHERE created_at < ? ORDER BY created_at LIMIT ?)`,
+		cutoff.UTC(), batchSize)
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
+)
+
+// readOnly ejecuta una consulta de solo lectura en una réplica cuando el
+// repositorio se creó con un ReadRouter, o en primary si no. Las lecturas que
+// deben ver lo recién escrito usan primary directamente.
+func readOnly(reads *db.ReadRouter, primary *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if reads == nil {
+		return fn(primary)
+	}
+	return reads.Read(fn)
+}
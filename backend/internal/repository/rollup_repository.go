@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
+	"gorm.io/gorm"
+)
+
+// RollupKey identifica el resumen de una hora de un dispositivo.
+type RollupKey struct {
+	DeviceID uint
+	Hour     time.Time
+}
+
+// RollupFunc combina los resúmenes ya guardados con un bloque de lecturas y
+// devuelve los resúmenes a guardar.
+type RollupFunc func(prev map[RollupKey]domain.SensorRollup, readings []domain.SensorData) []domain.SensorRollup
+
+type RollupRepository interface {
+	PurgeBatch(cutoff time.Time, batchSize int, rollup RollupFunc) (int64, error)
+	GetRange(deviceID uint, q RangeQuery) ([]domain.SensorRollup, error)
+}
+
+type rollupRepository struct {
+	db    *gorm.DB
+	reads *db.ReadRouter
+}
+
+func NewRollupRepository(db *gorm.DB) RollupRepository {
+	return &rollupRepository{db: db}
+}
+
+// NewRollupRepositoryWithReplicas lee el historial resumido de las réplicas del router.
+func NewRollupRepositoryWithReplicas(reads *db.ReadRouter) RollupRepository {
+	return &rollupRepository{db: reads.Primary(), reads: reads}
+}
+
+// PurgeBatch resume y borra hasta batchSize lecturas anteriores a cutoff en una
+// sola transacción, de modo que una interrupción no deja horas a medio contar.
+// Devuelve cuántas lecturas borró; 0 indica que no quedan.
+func (r *rollupRepository) PurgeBatch(cutoff time.Time, batchSize int, rollup RollupFunc) (int64, error) {
+	var deleted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var readings []domain.SensorData
+		err := tx.Where("ts < ?", cutoff).Order("device_id, ts").Limit(batchSize).Find(&readings).Error
+		if err != nil || len(readings) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(readings))
+		for i, d := range readings {
+			ids[i] = d.ID
+		}
+		if err := saveRollups(tx, readings, rollup); err != nil {
+			return err
+		}
+
+		if err := tx.Where("sensor_data_id IN ?", ids).Delete(&domain.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id IN ?", ids).Delete(&domain.SensorData{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return deleted, err
+}
+
+// GetRange devuelve los resúmenes de la hora más reciente a la más antigua con
+// la misma semántica que SensorRepository.GetRange, aplicada a la hora. Limit
+// en cero devuelve todos los del rango.
+func (r *rollupRepository) GetRange(deviceID uint, q RangeQuery) ([]domain.SensorRollup, error) {
+	rollups := []domain.SensorRollup{}
+	err := readOnly(r.reads, r.db, func(tx *gorm.DB) error {
+		tx = tx.Where("device_id = ?", deviceID)
+		if !q.From.IsZero() {
+			tx = tx.Where("hour >= ?", q.From.Truncate(time.Hour))
+		}
+		if !q.To.IsZero() {
+			tx = tx.Where("hour < ?", q.To)
+		}
+		if !q.Before.IsZero() {
+			tx = tx.Where("hour < ?", q.Before)
+		}
+		if q.Limit > 0 {
+			tx = tx.Limit(q.Limit)
+		}
+		return tx.Order("hour desc").Find(&rollups).Error
+	})
+	return rollups, err
+}
+
+// saveRollups carga los resúmenes de las horas del bloque, los combina con
+// rollup y los guarda dentro de tx.
+func saveRollups(tx *gorm.DB, readings []domain.SensorData, rollup RollupFunc) error {
+	prev := make(map[RollupKey]domain.SensorRollup)
+	for _, d := range readings {
+		key := RollupKey{DeviceID: d.DeviceID, Hour: d.TS.UTC().Truncate(time.Hour)}
+		if _, ok := prev[key]; ok {
+			continue
+		}
+		var existing domain.SensorRollup
+		err := tx.Where("device_id = ? AND hour = ?", key.DeviceID, key.Hour).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		prev[key] = existing
+	}
+
+	// Save actualiza los que ya tenían ID y crea el resto.
+	for _, r := range rollup(prev, readings) {
+		if err := tx.Save(&r).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
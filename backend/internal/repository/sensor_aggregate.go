@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+// AggregateRow resume las lecturas de un intervalo. Bucket es el inicio del
+// intervalo en segundos Unix; los Last* son los de la lectura más reciente.
+type AggregateRow struct {
+	Bucket int64
+	Count  int64
+
+	MinSpeed, MaxSpeed, AvgSpeed, LastSpeed                         float64
+	MinFuelLevel, MaxFuelLevel, AvgFuelLevel, LastFuelLevel         float64
+	MinTemperature, MaxTemperature, AvgTemperature, LastTemperature float64
+
+	LastLat, LastLng float64
+}
+
+// bucketExpr agrupa ts en intervalos de interval segundos alineados a la época.
+func bucketExpr(dialect string) string {
+	if dialect == "postgres" {
+		return "(floor(extract(epoch from ts) / @interval) * @interval)::bigint"
+	}
+	return "((CAST(strftime('%s', ts) AS INTEGER) / @interval) * @interval)"
+}
+
+// Aggregate agrupa las lecturas de [from, to) en intervalos y calcula min, max,
+// promedio y último valor de cada métrica. La última lectura de cada intervalo
+// se obtiene uniendo por MAX(ts), que funciona igual en ambos motores.
+func (r *sensorRepository) Aggregate(deviceID uint, from, to time.Time, interval time.Duration) ([]AggregateRow, error) {
+	const sql = `
+WITH b AS (
+	SELECT %s AS bucket, ts, lat, lng, speed, fuel_level, temperature
+	FROM sensor_data
+	WHERE device_id = @device AND ts >= @from AND ts < @to
+)
+SELECT agg.bucket, agg.count,
+	agg.min_speed, agg.max_speed, agg.avg_speed, last.speed AS last_speed,
+	agg.min_fuel_level, agg.max_fuel_level, agg.avg_fuel_level, last.fuel_level AS last_fuel_level,
+	agg.min_temperature, agg.max_temperature, agg.avg_temperature, last.temperature AS last_temperature,
+	last.lat AS last_lat, last.lng AS last_lng
+FROM (
+	SELECT bucket, COUNT(*) AS count, MAX(ts) AS last_ts,
+		MIN(speed) AS min_speed, MAX(speed) AS max_speed, AVG(speed) AS avg_speed,
+		MIN(fuel_level) AS min_fuel_level, MAX(fuel_level) AS max_fuel_level, AVG(fuel_level) AS avg_fuel_level,
+		MIN(temperature) AS min_temperature, MAX(temperature) AS max_temperature, AVG(temperature) AS avg_temperature
+	FROM b GROUP BY bucket
+) agg
+JOIN b last ON last.bucket = agg.bucket AND last.ts = agg.last_ts
+ORDER BY agg.bucket`
+
+	rows := []AggregateRow{}
+	err := r.read(func(tx *gorm.DB) error {
+		return tx.Raw(fmt.Sprintf(sql, bucketExpr(tx.Dialector.Name())), map[string]any{
+			"device":   deviceID,
+			"from":     from,
+			"to":       to,
+			"interval": int64(interval / time.Second),
+		}).Scan(&rows).Error
+	})
+	return rows, err
+}
+
+// GetTrack devuelve hasta limit posiciones de [from, to) en orden cronológico,
+// solo con las columnas necesarias para dibujar el recorrido.
+func (r *sensorRepository) GetTrack(deviceID uint, from, to time.Time, limit int) ([]domain.SensorData, error) {
+	records := []domain.SensorData{}
+	err := r.read(func(tx *gorm.DB) error {
+		return tx.Select("id", "device_id", "ts", "lat", "lng", "speed").
+			Where("device_id = ? AND ts >= ? AND ts < ?", deviceID, from, to).
+			Order("ts").Limit(limit).Find(&records).Error
+	})
+	return records, err
+}
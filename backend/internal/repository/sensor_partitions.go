@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"gorm.io/gorm"
+)
+
+// defaultPartition recibe las lecturas de meses sin partición propia, por
+// ejemplo un ts atrasado más allá del mes anterior o más allá de ahead meses.
+const defaultPartition = "sensor_data_default"
+
+// SensorPartition es una partición mensual de sensor_data: [From, To).
+type SensorPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// SensorPartitions administra el particionado mensual por ts de sensor_data.
+// Solo existe en PostgreSQL y es opcional: con la tabla sin particionar,
+// Enabled devuelve false y el resto de operaciones no aplica.
+type SensorPartitions interface {
+	Enabled() (bool, error)
+	MigrateFromUnpartitioned(now time.Time, ahead int) error
+	Ensure(now time.Time, ahead int) ([]string, error)
+	Expired(cutoff time.Time) ([]SensorPartition, error)
+	RollupAndDrop(p SensorPartition, batchSize int, rollup RollupFunc) (int64, error)
+}
+
+type sensorPartitions struct {
+	db *gorm.DB
+}
+
+func NewSensorPartitions(db *gorm.DB) SensorPartitions {
+	return &sensorPartitions{db: db}
+}
+
+// MonthlyPartition devuelve la partición del mes que contiene t.
+func MonthlyPartition(t time.Time) SensorPartition {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return SensorPartition{
+		Name: fmt.Sprintf("sensor_data_y%04dm%02d", from.Year(), int(from.Month())),
+		From: from,
+		To:   from.AddDate(0, 1, 0),
+	}
+}
+
+func (r *sensorPartitions) Enabled() (bool, error) {
+	if r.db.Dialector.Name() != "postgres" {
+		return false, nil
+	}
+	var partitioned bool
+	err := r.db.Raw(`SELECT EXISTS (
+		SELECT 1 FROM pg_partitioned_table pt
+		JOIN pg_class c ON c.oid = pt.partrelid
+		WHERE c.relname = 'sensor_data' AND c.relnamespace = current_schema()::regnamespace
+	)`).Scan(&partitioned).Error
+	return partitioned, err
+}
+
+// MigrateFromUnpartitioned convierte la sensor_data creada por AutoMigrate en
+// una tabla particionada por mes, con particiones desde la lectura más antigua
+// hasta ahead meses en el futuro, y copia las filas. Todo ocurre en una
+// transacción; con la tabla ya particionada no hace nada.
+//
+// Es una operación con corte de servicio: sensor_data queda bloqueada en modo
+// ACCESS EXCLUSIVE desde el inicio hasta el COMMIT, así que la ingesta y las
+// consultas esperan mientras se copia la tabla completa, un tiempo que crece
+// con su tamaño. Debe ejecutarse en una ventana de mantenimiento, con la API y
+// el gateway detenidos para que las esperas no agoten los pools de conexiones.
+//
+// La clave primaria pasa a ser (id, ts), como exige PostgreSQL; la secuencia
+// de id y los índices conservan sus nombres.
+func (r *sensorPartitions) MigrateFromUnpartitioned(now time.Time, ahead int) error {
+	if r.db.Dialector.Name() != "postgres" {
+		return fmt.Errorf("el particionado de sensor_data requiere PostgreSQL")
+	}
+	if enabled, err := r.Enabled(); err != nil || enabled {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var oldest *time.Time
+		if err := tx.Raw(`SELECT MIN(ts) FROM sensor_data`).Scan(&oldest).Error; err != nil {
+			return err
+		}
+
+		stmts := []string{
+			`LOCK TABLE sensor_data IN ACCESS EXCLUSIVE MODE`,
+			`ALTER TABLE sensor_data RENAME TO sensor_data_unpartitioned`,
+			`ALTER TABLE sensor_data_unpartitioned RENAME CONSTRAINT sensor_data_pkey TO sensor_data_unpartitioned_pkey`,
+			`ALTER INDEX IF EXISTS idx_sensor_device_ts RENAME TO idx_sensor_device_ts_unpartitioned`,
+			`ALTER INDEX IF EXISTS idx_sensor_data_device_id RENAME TO idx_sensor_data_device_id_unpartitioned`,
+			`ALTER INDEX IF EXISTS idx_sensor_data_ts RENAME TO idx_sensor_data_ts_unpartitioned`,
+			`CREATE TABLE sensor_data (LIKE sensor_data_unpartitioned INCLUDING DEFAULTS INCLUDING STORAGE) PARTITION BY RANGE (ts)`,
+			`ALTER TABLE sensor_data ADD CONSTRAINT sensor_data_pkey PRIMARY KEY (id, ts)`,
+			`CREATE UNIQUE INDEX idx_sensor_device_ts ON sensor_data (device_id, ts)`,
+			`CREATE INDEX idx_sensor_data_device_id ON sensor_data (device_id)`,
+			`CREATE INDEX idx_sensor_data_ts ON sensor_data (ts)`,
+			`ALTER SEQUENCE IF EXISTS sensor_data_id_seq OWNED BY sensor_data.id`,
+		}
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%s: %w", stmt, err)
+			}
+		}
+
+		start := now
+		if oldest != nil && oldest.Before(start) {
+			start = *oldest
+		}
+		if _, err := createPartitions(tx, start, now, ahead); err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`INSERT INTO sensor_data SELECT * FROM sensor_data_unpartitioned`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`DROP TABLE sensor_data_unpartitioned`).Error
+	})
+}
+
+// Ensure crea las particiones del mes anterior, el actual y ahead meses más.
+// El mes anterior cubre lecturas atrasadas dentro de la antigüedad admitida;
+// las que caen fuera van a la partición DEFAULT.
+func (r *sensorPartitions) Ensure(now time.Time, ahead int) ([]string, error) {
+	return createPartitions(r.db, now.UTC().AddDate(0, -1, 0), now, ahead)
+}
+
+// createPartitions crea la partición DEFAULT si falta y las mensuales de start
+// a ahead meses después de now. Las lecturas que la DEFAULT ya tenga del mes
+// pasan a su partición antes de adjuntarla, porque PostgreSQL rechaza crear
+// una partición cuyo rango tenga filas en la DEFAULT.
+func createPartitions(tx *gorm.DB, start, now time.Time, ahead int) ([]string, error) {
+	err := tx.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF sensor_data DEFAULT`, defaultPartition)).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	last := MonthlyPartition(now.UTC().AddDate(0, ahead, 0))
+	for p := MonthlyPartition(start); !p.From.After(last.From); p = MonthlyPartition(p.To) {
+		var exists bool
+		if err := tx.Raw(`SELECT to_regclass(?) IS NOT NULL`, p.Name).Scan(&exists).Error; err != nil {
+			return created, err
+		}
+		if exists {
+			continue
+		}
+		if err := attachPartition(tx, p); err != nil {
+			return created, err
+		}
+		created = append(created, p.Name)
+	}
+	return created, nil
+}
+
+func attachPartition(tx *gorm.DB, p SensorPartition) error {
+	// Los nombres y límites los genera MonthlyPartition, no el usuario.
+	from, to := p.From.Format(time.RFC3339), p.To.Format(time.RFC3339)
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE %s (LIKE sensor_data INCLUDING DEFAULTS INCLUDING STORAGE)`, p.Name),
+		fmt.Sprintf(`WITH moved AS (DELETE FROM %s WHERE ts >= '%s' AND ts < '%s' RETURNING *) INSERT INTO %s SELECT * FROM moved`,
+			defaultPartition, from, to, p.Name),
+		fmt.Sprintf(`ALTER TABLE sensor_data ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`, p.Name, from, to),
+	}
+	return tx.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%s: %w", stmt, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Expired lista las particiones mensuales que terminan antes de cutoff. La
+// DEFAULT nunca vence entera: sus lecturas viejas se borran fila a fila.
+func (r *sensorPartitions) Expired(cutoff time.Time) ([]SensorPartition, error) {
+	var names []string
+	err := r.db.Raw(`SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'sensor_data' AND p.relnamespace = current_schema()::regnamespace
+		ORDER BY c.relname`).Scan(&names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []SensorPartition
+	for _, name := range names {
+		var year, month int
+		if _, err := fmt.Sscanf(name, "sensor_data_y%04dm%02d", &year, &month); err != nil {
+			continue // particiones creadas a mano: no se tocan
+		}
+		p := MonthlyPartition(time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC))
+		if p.Name == name && !p.To.After(cutoff) {
+			expired = append(expired, p)
+		}
+	}
+	return expired, nil
+}
+
+// RollupAndDrop resume todas las lecturas de la partición en sensor_rollups y
+// luego la separa y la borra, en una sola transacción: sin borrados fila a fila.
+// Devuelve cuántas lecturas contenía.
+func (r *sensorPartitions) RollupAndDrop(p SensorPartition, batchSize int, rollup RollupFunc) (int64, error) {
+	var total int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var started bool
+		var lastDevice uint
+		var lastTS time.Time
+		for {
+			var readings []domain.SensorData
+			q := tx.Table(p.Name).Order("device_id, ts").Limit(batchSize)
+			if started {
+				q = q.Where("(device_id, ts) > (?, ?)", lastDevice, lastTS)
+			}
+			if err := q.Find(&readings).Error; err != nil {
+				return err
+			}
+			if len(readings) == 0 {
+				break
+			}
+			if err := saveRollups(tx, readings, rollup); err != nil {
+				return err
+			}
+			total += int64(len(readings))
+			last := readings[len(readings)-1]
+			started, lastDevice, lastTS = true, last.DeviceID, last.TS
+			if len(readings) < batchSize {
+				break
+			}
+		}
+
+		err := tx.Exec(fmt.Sprintf(`DELETE FROM idempotency_keys WHERE sensor_data_id IN (SELECT id FROM %s)`, p.Name)).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE sensor_data DETACH PARTITION %s`, p.Name)).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf(`DROP TABLE %s`, p.Name)).Error
+	})
+	return total, err
+}
@@ -1,29 +1,329 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SensorRepository interface {
-	Create(data *domain.SensorData) error
+	Create(data *domain.SensorData, idempotencyKey string) (bool, error)
+	CreateBatch(data []domain.SensorData) ([]bool, error)
+	CreateInBatches(data []domain.SensorData, batchSize int) (int64, error)
 	GetRecentByDevice(deviceID uint, limit int) ([]domain.SensorData, error)
+	GetRecentByDevicePrimary(deviceID uint, limit int) ([]domain.SensorData, error)
+	GetRange(deviceID uint, q RangeQuery) ([]domain.SensorData, error)
+	Aggregate(deviceID uint, from, to time.Time, interval time.Duration) ([]AggregateRow, error)
+	GetTrack(deviceID uint, from, to time.Time, limit int) ([]domain.SensorData, error)
+	ForEachChunk(deviceID uint, from, to time.Time, size int, fn func([]domain.SensorData) error) error
+	LatestForDevices(deviceIDs []uint) ([]domain.SensorData, error)
+}
+
+// RangeQuery acota una consulta del historial de un dispositivo. From es
+// inclusivo y To exclusivo; en cero no acotan. Before, si no es cero, continúa
+// una página anterior: solo lecturas con ts estrictamente menor. Attributes
+// exige además que se cumplan todas las condiciones sobre los atributos.
+type RangeQuery struct {
+	From       time.Time
+	To         time.Time
+	Before     time.Time
+	Limit      int
+	Attributes []AttributeCondition
+}
+
+// AttributeCondition filtra lecturas por el valor de un atributo. Value debe
+// tener el tipo Go del atributo declarado: float64, int64, bool o string.
+type AttributeCondition struct {
+	Name  string
+	Op    string // "=", ">=" o "<="
+	Value any
 }
 
 type sensorRepository struct {
 	db *gorm.DB
+	// Réplicas para las consultas de historial; nil las hace en db.
+	reads *db.ReadRouter
 }
 
 func NewSensorRepository(db *gorm.DB) SensorRepository {
 	return &sensorRepository{db: db}
 }
 
-func (r *sensorRepository) Create(data *domain.SensorData) error {
-	return r.db.Create(data).Error
+// NewSensorRepositoryWithReplicas escribe en la primaria del router y envía el
+// historial, la agregación y las exportaciones a sus réplicas.
+func NewSensorRepositoryWithReplicas(reads *db.ReadRouter) SensorRepository {
+	return &sensorRepository{db: reads.Primary(), reads: reads}
+}
+
+func (r *sensorRepository) read(fn func(tx *gorm.DB) error) error {
+	return readOnly(r.reads, r.db, fn)
+}
+
+// Una lectura repetida (mismo device_id y ts) no es un error: se ignora.
+var onDuplicateReading = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "device_id"}, {Name: "ts"}},
+	DoNothing: true,
+}
+
+// Create inserta la lectura si no existe otra con el mismo (device_id, ts) ni
+// se procesó antes el mismo Idempotency-Key. Devuelve false si era un duplicado;
+// en ese caso data queda con la fila ya guardada.
+func (r *sensorRepository) Create(data *domain.SensorData, idempotencyKey string) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if idempotencyKey != "" {
+			found, err := loadByIdempotencyKey(tx, data.DeviceID, idempotencyKey, data)
+			if err != nil || found {
+				return err
+			}
+		}
+
+		var err error
+		created, err = createOrLoad(tx, data)
+		if err != nil || idempotencyKey == "" {
+			return err
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.IdempotencyKey{
+			DeviceID:     data.DeviceID,
+			Key:          idempotencyKey,
+			SensorDataID: data.ID,
+		})
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+
+		// Otra petición con la misma clave se adelantó: vale la lectura que
+		// guardó ella y la nuestra se descarta.
+		var stored domain.SensorData
+		found, err := loadByIdempotencyKey(tx, data.DeviceID, idempotencyKey, &stored)
+		if err != nil {
+			return err
+		}
+		if !found {
+			// La clave quedó huérfana entre medias; pasa a ser nuestra
+			return tx.Create(&domain.IdempotencyKey{
+				DeviceID:     data.DeviceID,
+				Key:          idempotencyKey,
+				SensorDataID: data.ID,
+			}).Error
+		}
+		if stored.ID == data.ID {
+			return nil
+		}
+		if created {
+			if err := tx.Delete(&domain.SensorData{}, data.ID).Error; err != nil {
+				return err
+			}
+		}
+		*data = stored
+		created = false
+		return nil
+	})
+	return created, err
+}
+
+// loadByIdempotencyKey carga en data la lectura asociada a la clave. Una clave
+// cuya lectura ya se purgó por retención se borra y cuenta como inexistente.
+func loadByIdempotencyKey(tx *gorm.DB, deviceID uint, key string, data *domain.SensorData) (bool, error) {
+	var prev domain.IdempotencyKey
+	err := tx.Where("device_id = ? AND key = ?", deviceID, key).First(&prev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Where("id = ?", prev.SensorDataID).First(data).Error
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	err = tx.Where("device_id = ? AND key = ?", deviceID, key).Delete(&domain.IdempotencyKey{}).Error
+	return false, err
+}
+
+// CreateBatch inserta todas las lecturas en una sola transacción: o se guardan todas o ninguna.
+// El resultado indica, por posición, si la lectura era nueva.
+func (r *sensorRepository) CreateBatch(data []domain.SensorData) ([]bool, error) {
+	created := make([]bool, len(data))
+	if len(data) == 0 {
+		return created, nil
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range data {
+			ok, err := createOrLoad(tx, &data[i])
+			if err != nil {
+				return err
+			}
+			created[i] = ok
+		}
+		return nil
+	})
+	return created, err
+}
+
+// CreateInBatches parte el bloque en INSERTs de batchSize filas dentro de una
+// transacción y devuelve cuántas filas eran nuevas.
+func (r *sensorRepository) CreateInBatches(data []domain.SensorData, batchSize int) (int64, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	var inserted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(onDuplicateReading).CreateInBatches(&data, batchSize)
+		inserted = res.RowsAffected
+		return res.Error
+	})
+	return inserted, err
 }
 
 func (r *sensorRepository) GetRecentByDevice(deviceID uint, limit int) ([]domain.SensorData, error) {
+	var records []domain.SensorData
+	err := r.read(func(tx *gorm.DB) error {
+		return tx.Where("device_id = ?", deviceID).Order("ts desc").Limit(limit).Find(&records).Error
+	})
+	return records, err
+}
+
+// GetRecentByDevicePrimary es GetRecentByDevice leído siempre de la primaria,
+// para evaluar alertas sobre la lectura que se acaba de guardar.
+func (r *sensorRepository) GetRecentByDevicePrimary(deviceID uint, limit int) ([]domain.SensorData, error) {
 	var records []domain.SensorData
 	err := r.db.Where("device_id = ?", deviceID).Order("ts desc").Limit(limit).Find(&records).Error
 	return records, err
 }
+
+// LatestForDevices devuelve la lectura más reciente de cada dispositivo de la
+// lista. En PostgreSQL cada dispositivo es una búsqueda en el índice
+// (device_id, ts), sin recorrer sensor_data. Se lee de la primaria: refresca
+// la caché de posiciones y una réplica atrasada la dejaría con lecturas viejas.
+func (r *sensorRepository) LatestForDevices(deviceIDs []uint) ([]domain.SensorData, error) {
+	records := []domain.SensorData{}
+	if len(deviceIDs) == 0 {
+		return records, nil
+	}
+	if r.db.Dialector.Name() == "postgres" {
+		err := r.db.Raw(`SELECT s.* FROM devices d CROSS JOIN LATERAL (
+			SELECT * FROM sensor_data WHERE device_id = d.id ORDER BY ts DESC LIMIT 1
+		) s WHERE d.id IN ?`, deviceIDs).Scan(&records).Error
+		return records, err
+	}
+
+	latest := r.db.Table("sensor_data AS m").Select("MAX(m.ts)").Where("m.device_id = s.device_id")
+	err := r.db.Table("sensor_data AS s").
+		Where("s.device_id IN ? AND s.ts = (?)", deviceIDs, latest).
+		Find(&records).Error
+	return records, err
+}
+
+// GetRange devuelve las lecturas del rango de la más reciente a la más
+// antigua. Al ser (device_id, ts) único, ts basta como cursor y la consulta
+// recorre solo el índice idx_sensor_device_ts.
+func (r *sensorRepository) GetRange(deviceID uint, q RangeQuery) ([]domain.SensorData, error) {
+	records := []domain.SensorData{}
+	err := r.read(func(tx *gorm.DB) error {
+		tx = tx.Where("device_id = ?", deviceID)
+		if !q.From.IsZero() {
+			tx = tx.Where("ts >= ?", q.From)
+		}
+		if !q.To.IsZero() {
+			tx = tx.Where("ts < ?", q.To)
+		}
+		if !q.Before.IsZero() {
+			tx = tx.Where("ts < ?", q.Before)
+		}
+		for _, cond := range q.Attributes {
+			expr, args, err := attributeExpr(tx.Dialector.Name(), cond)
+			if err != nil {
+				return err
+			}
+			tx = tx.Where(expr, args...)
+		}
+		return tx.Order("ts desc").Limit(q.Limit).Find(&records).Error
+	})
+	return records, err
+}
+
+// ForEachChunk recorre las lecturas de [from, to) en orden cronológico en
+// bloques de size filas, paginando por ts para no cargar el rango completo.
+func (r *sensorRepository) ForEachChunk(deviceID uint, from, to time.Time, size int, fn func([]domain.SensorData) error) error {
+	after := from
+	first := true
+	for {
+		var chunk []domain.SensorData
+		err := r.read(func(tx *gorm.DB) error {
+			tx = tx.Where("device_id = ? AND ts < ?", deviceID, to)
+			if first {
+				tx = tx.Where("ts >= ?", after)
+			} else {
+				tx = tx.Where("ts > ?", after)
+			}
+			return tx.Order("ts").Limit(size).Find(&chunk).Error
+		})
+		if err != nil {
+			return err
+		}
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+		if len(chunk) < size {
+			return nil
+		}
+		after, first = chunk[len(chunk)-1].TS, false
+	}
+}
+
+// attributeExpr arma la comparación sobre la columna JSON según el motor. El
+// nombre del atributo viaja como parámetro, nunca concatenado en el SQL.
+func attributeExpr(dialect string, cond AttributeCondition) (string, []any, error) {
+	switch cond.Op {
+	case "=", ">=", "<=":
+	default:
+		return "", nil, fmt.Errorf("operador no soportado: %q", cond.Op)
+	}
+
+	if dialect == "postgres" {
+		switch cond.Value.(type) {
+		case float64, int64:
+			return "(attributes->>?)::numeric " + cond.Op + " ?", []any{cond.Name, cond.Value}, nil
+		case bool:
+			return "(attributes->>?)::boolean " + cond.Op + " ?", []any{cond.Name, cond.Value}, nil
+		default:
+			return "attributes->>? " + cond.Op + " ?", []any{cond.Name, cond.Value}, nil
+		}
+	}
+
+	// SQLite: json_extract devuelve los booleanos JSON como 1/0.
+	value := cond.Value
+	if b, ok := value.(bool); ok {
+		value = 0
+		if b {
+			value = 1
+		}
+	}
+	return "json_extract(attributes, ?) " + cond.Op + " ?", []any{"$." + cond.Name, value}, nil
+}
+
+func createOrLoad(tx *gorm.DB, data *domain.SensorData) (bool, error) {
+	res := tx.Clauses(onDuplicateReading).Create(data)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+	err := tx.Where("device_id = ? AND ts = ?", data.DeviceID, data.TS).First(data).Error
+	return false, err
+}
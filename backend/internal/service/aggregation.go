@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+// Intervalos de agregación admitidos por Aggregate.
+var AggregationIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+const (
+	// Máximo de intervalos por consulta de agregación.
+	maxAggregateBuckets = 10000
+	// Máximo de lecturas que se leen para simplificar un recorrido.
+	maxTrackSourcePoints = 500000
+)
+
+var (
+	ErrUnknownInterval = errors.New("intervalo no soportado: use 1m, 5m, 1h o 1d")
+	// ErrRangeTooLarge indica un rango con más intervalos o lecturas de las que
+	// se procesan en una consulta; el cliente debe acotarlo.
+	ErrRangeTooLarge = errors.New("rango demasiado grande")
+)
+
+// MetricStats resume una métrica dentro de un intervalo.
+type MetricStats struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Avg  float64 `json:"avg"`
+	Last float64 `json:"last"`
+}
+
+// AggregateBucket es un intervalo con lecturas; Lat y Lng son la última posición.
+type AggregateBucket struct {
+	TS          time.Time   `json:"ts"`
+	Count       int64       `json:"count"`
+	Lat         float64     `json:"lat"`
+	Lng         float64     `json:"lng"`
+	Speed       MetricStats `json:"speed"`
+	FuelLevel   MetricStats `json:"fuel_level"`
+	Temperature MetricStats `json:"temperature"`
+}
+
+// TrackPoint es una posición del recorrido simplificado.
+type TrackPoint struct {
+	TS    time.Time `json:"ts"`
+	Lat   float64   `json:"lat"`
+	Lng   float64   `json:"lng"`
+	Speed float64   `json:"speed"`
+}
+
+// Aggregate agrupa el historial de [from, to) por el intervalo dado ("1m",
+// "5m", "1h" o "1d"). Los intervalos sin lecturas se omiten. Las horas ya
+// borradas por la retención se toman de sus resúmenes; con intervalos menores
+// a una hora cada resumen ocupa el intervalo en que empieza su hora.
+func (s *SensorService) Aggregate(p Principal, deviceID uint, from, to time.Time, interval string) ([]AggregateBucket, error) {
+	step, ok := AggregationIntervals[interval]
+	if !ok {
+		return nil, ErrUnknownInterval
+	}
+	if to.Sub(from)/step > maxAggregateBuckets {
+		return nil, fmt.Errorf("%w: supera %d intervalos de %s", ErrRangeTooLarge, maxAggregateBuckets, interval)
+	}
+	if _, err := s.access.Authorize(p, deviceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.sensorRepo.Aggregate(deviceID, from.UTC(), to.UTC(), step)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]AggregateBucket, len(rows))
+	for i, r := range rows {
+		buckets[i] = AggregateBucket{
+			TS:          time.Unix(r.Bucket, 0).UTC(),
+			Count:       r.Count,
+			Lat:         r.LastLat,
+			Lng:         r.LastLng,
+			Speed:       MetricStats{r.MinSpeed, r.MaxSpeed, r.AvgSpeed, r.LastSpeed},
+			FuelLevel:   MetricStats{r.MinFuelLevel, r.MaxFuelLevel, r.AvgFuelLevel, r.LastFuelLevel},
+			Temperature: MetricStats{r.MinTemperature, r.MaxTemperature, r.AvgTemperature, r.LastTemperature},
+		}
+	}
+
+	if s.rollups == nil {
+		return buckets, nil
+	}
+	rollups, err := s.rollups.GetRange(deviceID, repository.RangeQuery{From: from.UTC(), To: to.UTC()})
+	if err != nil {
+		return nil, err
+	}
+	return mergeRollups(buckets, rollups, step), nil
+}
+
+// mergeRollups suma los resúmenes horarios (del más reciente al más antiguo,
+// como los devuelve el repositorio) a los intervalos de step. Los resúmenes
+// son anteriores a toda lectura cruda, así que en un intervalo con lecturas
+// crudas la última posición y los valores Last no cambian. El resumen no
+// guarda la velocidad mínima ni los últimos valores de cada métrica: en su
+// lugar se usa el promedio de la hora.
+func mergeRollups(buckets []AggregateBucket, rollups []domain.SensorRollup, step time.Duration) []AggregateBucket {
+	if len(rollups) == 0 {
+		return buckets
+	}
+	index := make(map[int64]int, len(buckets))
+	for i, b := range buckets {
+		index[b.TS.Unix()] = i
+	}
+	raw := len(buckets)
+
+	for i := len(rollups) - 1; i >= 0; i-- {
+		r := rollups[i]
+		ts := r.Hour.UTC().Truncate(step)
+		j, ok := index[ts.Unix()]
+		if !ok {
+			buckets = append(buckets, AggregateBucket{TS: ts})
+			j = len(buckets) - 1
+			index[ts.Unix()] = j
+		}
+
+		b := &buckets[j]
+		last := j >= raw
+		mergeStats(&b.Speed, b.Count, r.Count, r.SpeedAvg, r.SpeedMax, r.SpeedAvg, last)
+		mergeStats(&b.FuelLevel, b.Count, r.Count, r.FuelMin, r.FuelMax, r.FuelAvg, last)
+		mergeStats(&b.Temperature, b.Count, r.Count, r.TemperatureMin, r.TemperatureMax, r.TemperatureAvg, last)
+		if last {
+			b.Lat, b.Lng = r.LastLat, r.LastLng
+		}
+		b.Count += r.Count
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].TS.Before(buckets[j].TS) })
+	return buckets
+}
+
+// mergeStats combina n lecturas ya contadas en m con count lecturas resumidas.
+func mergeStats(m *MetricStats, n, count int64, min, max, avg float64, last bool) {
+	if n == 0 {
+		*m = MetricStats{Min: min, Max: max, Avg: avg, Last: avg}
+		return
+	}
+	m.Min = math.Min(m.Min, min)
+	m.Max = math.Max(m.Max, max)
+	m.Avg = (m.Avg*float64(n) + avg*float64(count)) / float64(n+count)
+	if last {
+		m.Last = avg
+	}
+}
+
+// Track devuelve el recorrido de [from, to) reducido a lo sumo a points
+// posiciones con LTTB, y el total de lecturas del rango. Las horas ya borradas
+// por la retención aportan una posición por hora desde sus resúmenes. Un rango
+// con más de maxTrackSourcePoints posiciones devuelve ErrRangeTooLarge en
+// lugar de simplificar solo su parte inicial.
+func (s *SensorService) Track(p Principal, deviceID uint, from, to time.Time, points int) ([]TrackPoint, int, error) {
+	if _, err := s.access.Authorize(p, deviceID); err != nil {
+		return nil, 0, err
+	}
+
+	hours, total, err := rollupReadings(s.rollups, deviceID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, 0, err
+	}
+	readings, err := s.sensorRepo.GetTrack(deviceID, from.UTC(), to.UTC(), maxTrackSourcePoints+1-len(hours))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(hours)+len(readings) > maxTrackSourcePoints {
+		return nil, 0, fmt.Errorf("%w: más de %d lecturas, acote from/to", ErrRangeTooLarge, maxTrackSourcePoints)
+	}
+	total += len(readings)
+	if len(hours) > 0 {
+		readings = append(hours, readings...)
+	}
+
+	sampled := LTTB(readings, points)
+	track := make([]TrackPoint, len(sampled))
+	for i, r := range sampled {
+		track[i] = TrackPoint{TS: r.TS, Lat: r.Lat, Lng: r.Lng, Speed: r.Speed}
+	}
+	return track, total, nil
+}
+
+// LTTB (Largest-Triangle-Three-Buckets) conserva threshold lecturas, siempre
+// la primera y la última, eligiendo en cada tramo la que forma el triángulo de
+// mayor área con la anterior elegida y el promedio del tramo siguiente. Para
+// recorridos el área se mide sobre (lng, lat), así se conservan las curvas.
+// Con threshold menor a 3 o mayor que len(data) devuelve data sin cambios.
+func LTTB(data []domain.SensorData, threshold int) []domain.SensorData {
+	if threshold < 3 || threshold >= len(data) {
+		return data
+	}
+
+	sampled := make([]domain.SensorData, 0, threshold)
+	sampled = append(sampled, data[0])
+
+	// Tramos de igual tamaño entre la primera y la última lectura.
+	every := float64(len(data)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+
+		nextStart, nextEnd := end, int(math.Floor(float64(i+2)*every))+1
+		if nextEnd > len(data) {
+			nextEnd = len(data)
+		}
+		var avgX, avgY float64
+		for _, d := range data[nextStart:nextEnd] {
+			avgX += d.Lng
+			avgY += d.Lat
+		}
+		n := float64(nextEnd - nextStart)
+		avgX /= n
+		avgY /= n
+
+		best, maxArea := start, -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((data[a].Lng-avgX)*(data[j].Lat-data[a].Lat) -
+				(data[a].Lng-data[j].Lng)*(avgY-data[a].Lat))
+			if area > maxArea {
+				maxArea, best = area, j
+			}
+		}
+		sampled = append(sampled, data[best])
+		a = best
+	}
+
+	return append(sampled, data[len(data)-1])
+}
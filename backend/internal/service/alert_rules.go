@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/ws"
+)
+
+var ErrAlertRuleNotFound = errors.New("regla de alerta no encontrada")
+
+const (
+	// Duración máxima que una condición debe sostenerse antes de alertar.
+	maxRuleDurationSeconds = 24 * 60 * 60
+	// Máximo de dispositivos en el grupo de una regla.
+	maxRuleDevices = 500
+)
+
+var alertTypePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// AlertRuleInput describe una regla a crear o reemplazar. Enabled y
+// AutoResolve nil valen true al crear y conservan su valor al reemplazar.
+type AlertRuleInput struct {
+	Name            string           `json:"name"`
+	AlertType       domain.AlertType `json:"alert_type"`
+	Metric          string           `json:"metric"`
+	Operator        string           `json:"operator"`
+	Threshold       *float64         `json:"threshold"`
+	DurationSeconds int              `json:"duration_seconds"`
+	Hysteresis      float64          `json:"hysteresis"`
+	DeviceIDs       []uint           `json:"device_ids"`
+	Severity        string           `json:"severity"`
+	Enabled         *bool            `json:"enabled"`
+	AutoResolve     *bool            `json:"auto_resolve"`
+}
+
+// AlertRuleService administra las reglas y recarga el motor tras cada cambio.
+// Al eliminar o deshabilitar una regla resuelve sus alertas pendientes, que el
+// motor ya no resolvería.
+type AlertRuleService struct {
+	repo      repository.AlertRuleRepository
+	alertRepo repository.AlertRepository
+	access    *DeviceAccess
+	engine    *RuleEngine
+	hub       *ws.Hub
+}
+
+func NewAlertRuleService(repo repository.AlertRuleRepository, alertRepo repository.AlertRepository, access *DeviceAccess, engine *RuleEngine, hub *ws.Hub) *AlertRuleService {
+	return &AlertRuleService{repo: repo, alertRepo: alertRepo, access: access, engine: engine, hub: hub}
+}
+
+func (s *AlertRuleService) List() ([]domain.AlertRule, error) {
+	return s.repo.List()
+}
+
+func (s *AlertRuleService) Get(id uint) (*domain.AlertRule, error) {
+	rule, err := s.repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAlertRuleNotFound
+	}
+	return rule, err
+}
+
+// Create valida y guarda la regla. Los errores de la petición son
+// *ValidationError.
+func (s *AlertRuleService) Create(p Principal, in AlertRuleInput) (*domain.AlertRule, error) {
+	rule := &domain.AlertRule{CreatedBy: p.UserID, Enabled: true, AutoResolve: true}
+	if err := s.apply(rule, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(rule); err != nil {
+		return nil, err
+	}
+	s.reload()
+	return rule, nil
+}
+
+// Update reemplaza la definición de la regla; su estado en el motor se reinicia.
+func (s *AlertRuleService) Update(id uint, in AlertRuleInput) (*domain.AlertRule, error) {
+	rule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	wasEnabled := rule.Enabled
+	if err := s.apply(rule, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(rule); err != nil {
+		return nil, err
+	}
+	s.reload()
+	if wasEnabled && !rule.Enabled {
+		s.resolveAlerts(rule.ID, "regla deshabilitada")
+	}
+	return rule, nil
+}
+
+func (s *AlertRuleService) Delete(id uint) error {
+	err := s.repo.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAlertRuleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.reload()
+	s.resolveAlerts(id, "regla eliminada")
+	return nil
+}
+
+// resolveAlerts resuelve las alertas pendientes de la regla y emite cada
+// cambio de estado.
+func (s *AlertRuleService) resolveAlerts(ruleID uint, reason string) {
+	resolved, err := s.alertRepo.ResolveByRule(ruleID, reason, time.Now().UTC())
+	if err != nil {
+		log.Printf("[ERROR] No se pudieron resolver las alertas de la regla %d: %v", ruleID, err)
+		return
+	}
+	for i := range resolved {
+		broadcastAlertStatus(s.hub, &resolved[i])
+	}
+}
+
+func (s *AlertRuleService) reload() {
+	if s.engine == nil {
+		return
+	}
+	if err := s.engine.Reload(); err != nil {
+		log.Printf("[ERROR] No se pudieron recargar las reglas de alerta: %v", err)
+	}
+}
+
+// apply valida in y la copia en rule.
+func (s *AlertRuleService) apply(rule *domain.AlertRule, in AlertRuleInput) error {
+	var errs []FieldError
+	add := func(field, code, msg string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: msg})
+	}
+
+	name := strings.TrimSpace(in.Name)
+	switch {
+	case name == "":
+		add("name", CodeRequired, "el nombre es obligatorio")
+	case len(name) > 100:
+		add("name", CodeOutOfRange, "máximo 100 caracteres")
+	}
+	switch {
+	case in.AlertType == "":
+		add("alert_type", CodeRequired, "el tipo de alerta es obligatorio")
+	case !alertTypePattern.MatchString(string(in.AlertType)):
+		add("alert_type", CodeInvalidFormat, "solo minúsculas, dígitos y _, empezando por letra")
+	case in.AlertType == domain.AlertFuelLow:
+		add("alert_type", CodeInvalidFormat, "el tipo está reservado para la alerta de combustible")
+	}
+	if !validRuleMetric(in.Metric) {
+		add("metric", CodeInvalidFormat, "debe ser speed, fuel_level, temperature o attributes.<nombre>")
+	}
+	switch in.Operator {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		add("operator", CodeInvalidFormat, "debe ser gt, gte, lt, lte, eq o neq")
+	}
+	if in.Threshold == nil {
+		add("threshold", CodeRequired, "el umbral es obligatorio")
+	}
+	if in.DurationSeconds < 0 || in.DurationSeconds > maxRuleDurationSeconds {
+		add("duration_seconds", CodeOutOfRange, fmt.Sprintf("debe estar entre 0 y %d", maxRuleDurationSeconds))
+	}
+	if in.Hysteresis < 0 {
+		add("hysteresis", CodeOutOfRange, "no puede ser negativa")
+	}
+	switch in.Severity {
+	case domain.SeverityInfo, domain.SeverityWarning, domain.SeverityCritical:
+	default:
+		add("severity", CodeInvalidFormat, "debe ser info, warning o critical")
+	}
+
+	devices := make([]uint, 0, len(in.DeviceIDs))
+	seen := make(map[uint]bool, len(in.DeviceIDs))
+	if len(in.DeviceIDs) > maxRuleDevices {
+		add("device_ids", CodeOutOfRange, fmt.Sprintf("máximo %d dispositivos", maxRuleDevices))
+	} else {
+		admin := Principal{Role: string(domain.RoleAdmin)}
+		for _, id := range in.DeviceIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if _, err := s.access.Authorize(admin, id); err != nil {
+				if !errors.Is(err, ErrDeviceNotFound) {
+					return err
+				}
+				add("device_ids", CodeUnknownDevice, fmt.Sprintf("el dispositivo %d no existe", id))
+				continue
+			}
+			devices = append(devices, id)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i] < devices[j] })
+
+	rule.Name = name
+	rule.AlertType = in.AlertType
+	rule.Metric = in.Metric
+	rule.Operator = in.Operator
+	rule.Threshold = *in.Threshold
+	rule.DurationSeconds = in.DurationSeconds
+	rule.Hysteresis = in.Hysteresis
+	rule.DeviceIDs = devices
+	rule.Severity = in.Severity
+	if in.Enabled != nil {
+		rule.Enabled = *in.Enabled
+	}
+	if in.AutoResolve != nil {
+		rule.AutoResolve = *in.AutoResolve
+	}
+	return nil
+}
+
+func validRuleMetric(metric string) bool {
+	if _, ok := ruleMetrics[metric]; ok {
+		return true
+	}
+	name, ok := strings.CutPrefix(metric, attributeMetricPrefix)
+	return ok && attributeNamePattern.MatchString(name)
+}
+
+// SetRuleEngine hace que cada lectura ingerida se evalúe contra las reglas de
+// alerta configuradas.
+func (s *SensorService) SetRuleEngine(engine *RuleEngine) {
+	s.rules = engine
+}
+
+// evaluateRules pasa las lecturas por el motor en orden cronológico, crea y
+// emite una alerta por cada regla que dispara y, si la regla lo indica,
+// resuelve sus alertas cuando el valor se normaliza.
+func (s *SensorService) evaluateRules(readings []domain.SensorData) {
+	if s.rules == nil || len(readings) == 0 {
+		return
+	}
+	ordered := append([]domain.SensorData(nil), readings...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].TS.Before(ordered[j].TS) })
+
+	for _, d := range ordered {
+		for _, ev := range s.rules.Evaluate(d) {
+			if !ev.Triggered {
+				log.Printf("[INFO] ✅ Regla %d (%s) normalizada - Dispositivo %d: %s=%v",
+					ev.Rule.ID, ev.Rule.Name, ev.DeviceID, ev.Rule.Metric, ev.Value)
+				if ev.Rule.AutoResolve {
+					ruleID := ev.Rule.ID
+					s.resolveAlerts(ev.DeviceID, ev.Rule.AlertType, &ruleID,
+						fmt.Sprintf("auto: %s normalizado (valor %v)", ev.Rule.Metric, ev.Value))
+				}
+				continue
+			}
+			if err := s.createRuleAlert(ev); err != nil {
+				log.Printf("[ERROR] No se pudo crear la alerta de la regla %d: %v", ev.Rule.ID, err)
+			}
+		}
+	}
+}
+
+func (s *SensorService) createRuleAlert(ev RuleEvent) error {
+	s.mu.Lock()
+	deviceName := s.getDeviceName(ev.DeviceID)
+	s.mu.Unlock()
+
+	payload, err := json.Marshal(map[string]any{
+		"rule_id":     ev.Rule.ID,
+		"rule_name":   ev.Rule.Name,
+		"device_name": deviceName,
+		"metric":      ev.Rule.Metric,
+		"operator":    ev.Rule.Operator,
+		"threshold":   ev.Rule.Threshold,
+		"value":       ev.Value,
+		"severity":    ev.Rule.Severity,
+	})
+	if err != nil {
+		return err
+	}
+
+	ruleID := ev.Rule.ID
+	alert := &domain.Alert{
+		DeviceID: ev.DeviceID,
+		Type:     ev.Rule.AlertType,
+		TS:       ev.TS.UTC(),
+		Payload:  payload,
+		RuleID:   &ruleID,
+		Severity: ev.Rule.Severity,
+	}
+	if err := s.alertRepo.Create(alert); err != nil {
+		return err
+	}
+
+	s.broadcastAlert(alert, fmt.Sprintf("🚨 %s: %s %s %v (valor %v)",
+		ev.Rule.Name, ev.Rule.Metric, ev.Rule.Operator, ev.Rule.Threshold, ev.Value), ev.Rule.Severity)
+
+	log.Printf("[ALERT] 🚨 Regla %d (%s) disparada - Dispositivo %d: %s=%v",
+		ev.Rule.ID, ev.Rule.Name, ev.DeviceID, ev.Rule.Metric, ev.Value)
+	return nil
+}
@@ -1,20 +1,182 @@
 package service
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
 	"github.com/nleea/fleet-monitoring/backend/internal/domain"
 	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+	"github.com/nleea/fleet-monitoring/backend/internal/ws"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrAlertNotFound indica una alerta inexistente o de un dispositivo al
+	// que el principal no tiene acceso.
+	ErrAlertNotFound = errors.New("alerta no encontrada")
+	// ErrAlertResolved indica que una alerta resuelta ya no admite reconocerse.
+	ErrAlertResolved = errors.New("la alerta ya está resuelta")
+)
+
+// Máximo de alertas por página y por reconocimiento masivo.
+const (
+	MaxAlertPageSize = 500
+	MaxAlertAckBatch = 500
 )
 
 type AlertService struct {
-	repo repository.AlertRepository
-	app  *appcore.App
+	repo   repository.AlertRepository
+	hub    *ws.Hub
+	logger *utils.Logger
+	access *DeviceAccess
+}
+
+func NewAlertService(repo repository.AlertRepository, hub *ws.Hub, logger *utils.Logger, access *DeviceAccess) *AlertService {
+	return &AlertService{repo: repo, hub: hub, logger: logger, access: access}
+}
+
+// AlertView es la representación de una alerta en la API.
+type AlertView struct {
+	ID        uint             `json:"id"`
+	DeviceID  uint             `json:"device_id"`
+	Type      domain.AlertType `json:"type"`
+	TS        time.Time        `json:"ts"`
+	Ack       bool             `json:"ack"`
+	Payload   json.RawMessage  `json:"payload"`
+	CreatedAt time.Time        `json:"created_at"`
+
+	RuleID           *uint              `json:"rule_id"`
+	Status           domain.AlertStatus `json:"status"`
+	AcknowledgedBy   *uint              `json:"acknowledged_by"`
+	AcknowledgedAt   *time.Time         `json:"acknowledged_at"`
+	ResolvedAt       *time.Time         `json:"resolved_at"`
+	ResolutionReason string             `json:"resolution_reason,omitempty"`
+}
+
+func newAlertView(a domain.Alert) AlertView {
+	payload := json.RawMessage(a.Payload)
+	if len(payload) == 0 || !json.Valid(payload) {
+		payload = json.RawMessage("null")
+	}
+	return AlertView{
+		ID:        a.ID,
+		DeviceID:  a.DeviceID,
+		Type:      a.Type,
+		TS:        a.TS,
+		Ack:       a.Ack,
+		Payload:   payload,
+		CreatedAt: a.CreatedAt,
+
+		RuleID:           a.RuleID,
+		Status:           a.Status,
+		AcknowledgedBy:   a.AcknowledgedBy,
+		AcknowledgedAt:   a.AcknowledgedAt,
+		ResolvedAt:       a.ResolvedAt,
+		ResolutionReason: a.ResolutionReason,
+	}
+}
+
+// AlertFilter pide una página de alertas; From es inclusivo y To exclusivo.
+// Ack nil incluye reconocidas y pendientes; Status vacío, todos los estados.
+type AlertFilter struct {
+	DeviceID uint
+	Type     domain.AlertType
+	From     time.Time
+	To       time.Time
+	Ack      *bool
+	Status   domain.AlertStatus
+	Limit    int
+	Cursor   string
+}
+
+// AlertPage son las alertas de la más reciente a la más antigua. NextCursor
+// está vacío en la última página.
+type AlertPage struct {
+	Data       []AlertView `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
-func NewAlertService(repo repository.AlertRepository, app *appcore.App) *AlertService {
-	return &AlertService{repo: repo, app: app}
+// BulkAckResult separa los ids reconocidos de los que no existen o no son
+// visibles para el principal.
+type BulkAckResult struct {
+	Acknowledged []uint `json:"acknowledged"`
+	NotFound     []uint `json:"not_found"`
+	Updated      int64  `json:"updated"`
+}
+
+// List devuelve una página de alertas de los dispositivos visibles para p
+// (todos para un admin). Filtrar por un dispositivo exige acceso a él.
+func (s *AlertService) List(p Principal, f AlertFilter) (*AlertPage, error) {
+	q := repository.AlertQuery{
+		DeviceID: f.DeviceID,
+		Type:     f.Type,
+		From:     f.From.UTC(),
+		To:       f.To.UTC(),
+		Ack:      f.Ack,
+		Status:   f.Status,
+		Limit:    f.Limit + 1,
+	}
+	if f.DeviceID != 0 {
+		if _, err := s.access.Authorize(p, f.DeviceID); err != nil {
+			return nil, err
+		}
+	} else if !p.IsAdmin() {
+		q.VisibleTo = p.UserID
+	}
+
+	var err error
+	if q.BeforeTS, q.BeforeID, err = decodeAlertCursor(f.Cursor); err != nil {
+		return nil, err
+	}
+
+	alerts, err := s.repo.List(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AlertPage{Data: make([]AlertView, 0, len(alerts))}
+	if len(alerts) > f.Limit {
+		alerts = alerts[:f.Limit]
+		last := alerts[len(alerts)-1]
+		page.NextCursor = encodeAlertCursor(last.TS, last.ID)
+	}
+	for _, a := range alerts {
+		page.Data = append(page.Data, newAlertView(a))
+	}
+	return page, nil
+}
+
+// Get devuelve la alerta si p tiene acceso a su dispositivo.
+func (s *AlertService) Get(p Principal, id uint) (*AlertView, error) {
+	alert, err := s.visible(p, id)
+	if err != nil {
+		return nil, err
+	}
+	view := newAlertView(*alert)
+	return &view, nil
+}
+
+// visible carga la alerta y comprueba el acceso a su dispositivo. Sin acceso
+// se responde como si no existiera, para no revelar ids ajenos.
+func (s *AlertService) visible(p Principal, id uint) (*domain.Alert, error) {
+	alert, err := s.repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAlertNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.access.Authorize(p, alert.DeviceID); err != nil {
+		if errors.Is(err, ErrDeviceForbidden) || errors.Is(err, ErrDeviceNotFound) {
+			return nil, ErrAlertNotFound
+		}
+		return nil, err
+	}
+	return alert, nil
 }
 
 func (s *AlertService) Create(alert *domain.Alert) error {
@@ -27,11 +189,12 @@ func (s *AlertService) Create(alert *domain.Alert) error {
 		"device_id":  alert.DeviceID,
 		"timestamp":  alert.TS.Format(time.RFC3339),
 		"ack":        alert.Ack,
+		"status":     domain.AlertOpen,
 		"payload":    string(alert.Payload),
 		"created_at": time.Now().Format(time.RFC3339),
 	}
 
-	go s.app.Hub.Broadcast(
+	go s.hub.Broadcast(
 		"telemetry",
 		payload,
 		map[string]any{
@@ -41,7 +204,7 @@ func (s *AlertService) Create(alert *domain.Alert) error {
 		"admin", "user",
 	)
 
-	s.app.Logger.Info("🚨 Alert broadcasted", "device_id", alert.DeviceID, "type", alert.Type)
+	s.logger.Info("🚨 Alert broadcasted", "device_id", alert.DeviceID, "type", alert.Type)
 	return nil
 }
 
@@ -49,6 +212,167 @@ func (s *AlertService) ListUnacknowledged(limit int) ([]domain.Alert, error) {
 	return s.repo.GetUnacknowledged(limit)
 }
 
-func (s *AlertService) Acknowledge(id uint) error {
-	return s.repo.Acknowledge(id)
+// Acknowledge pasa la alerta de abierta a reconocida por p. Reconocer una ya
+// reconocida no es un error; una resuelta devuelve ErrAlertResolved.
+func (s *AlertService) Acknowledge(p Principal, id uint) (*AlertView, error) {
+	alert, err := s.visible(p, id)
+	if err != nil {
+		return nil, err
+	}
+	switch alert.Status {
+	case domain.AlertResolved:
+		return nil, ErrAlertResolved
+	case domain.AlertOpen:
+		now := time.Now().UTC()
+		if err := s.repo.Acknowledge(id, p.UserID, now); err != nil {
+			return nil, err
+		}
+		markAcknowledged(alert, p.UserID, now)
+		broadcastAlertStatus(s.hub, alert)
+	}
+	view := newAlertView(*alert)
+	return &view, nil
+}
+
+// Resolve cierra la alerta con el motivo dado. Resolver una ya resuelta la
+// devuelve sin cambios.
+func (s *AlertService) Resolve(p Principal, id uint, reason string) (*AlertView, error) {
+	alert, err := s.visible(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status != domain.AlertResolved {
+		if reason == "" {
+			reason = "resuelta manualmente"
+		}
+		now := time.Now().UTC()
+		changed, err := s.repo.Resolve(id, reason, now)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			alert.Status = domain.AlertResolved
+			alert.ResolvedAt = &now
+			alert.ResolutionReason = reason
+			broadcastAlertStatus(s.hub, alert)
+		} else if alert, err = s.repo.GetByID(id); err != nil {
+			return nil, err
+		}
+	}
+	view := newAlertView(*alert)
+	return &view, nil
+}
+
+// AcknowledgeMany reconoce las alertas visibles para p entre ids; el resto se
+// devuelve en NotFound.
+func (s *AlertService) AcknowledgeMany(p Principal, ids []uint) (*BulkAckResult, error) {
+	alerts, err := s.repo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]domain.Alert, len(alerts))
+	for _, a := range alerts {
+		byID[a.ID] = a
+	}
+
+	result := &BulkAckResult{Acknowledged: []uint{}, NotFound: []uint{}}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		alert, ok := byID[id]
+		if ok {
+			if _, err := s.access.Authorize(p, alert.DeviceID); err != nil {
+				if !errors.Is(err, ErrDeviceForbidden) && !errors.Is(err, ErrDeviceNotFound) {
+					return nil, err
+				}
+				ok = false
+			}
+		}
+		if ok {
+			result.Acknowledged = append(result.Acknowledged, id)
+		} else {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+
+	now := time.Now().UTC()
+	changed, err := s.repo.AcknowledgeMany(result.Acknowledged, p.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+	result.Updated = int64(len(changed))
+	for _, id := range changed {
+		alert := byID[id]
+		markAcknowledged(&alert, p.UserID, now)
+		broadcastAlertStatus(s.hub, &alert)
+	}
+	return result, nil
+}
+
+func markAcknowledged(alert *domain.Alert, userID uint, at time.Time) {
+	alert.Ack = true
+	alert.Status = domain.AlertAcknowledged
+	alert.AcknowledgedBy = &userID
+	alert.AcknowledgedAt = &at
+}
+
+// broadcastAlertStatus emite cada cambio de estado de una alerta para que el
+// dashboard la actualice o la retire al resolverse.
+func broadcastAlertStatus(hub *ws.Hub, alert *domain.Alert) {
+	if hub == nil {
+		return
+	}
+
+	payload := map[string]any{
+		"id":                alert.ID,
+		"device_id":         alert.DeviceID,
+		"type":              alert.Type,
+		"status":            alert.Status,
+		"acknowledged_by":   alert.AcknowledgedBy,
+		"acknowledged_at":   formatOptionalTime(alert.AcknowledgedAt),
+		"resolved_at":       formatOptionalTime(alert.ResolvedAt),
+		"resolution_reason": alert.ResolutionReason,
+	}
+
+	go hub.Broadcast(
+		"alert_status",
+		payload,
+		map[string]any{
+			"timestamp": time.Now().Format(time.RFC3339),
+			"source":    "AlertService",
+		},
+		"admin",
+	)
+}
+
+func formatOptionalTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+// El cursor es el ts y el id de la última alerta entregada, opaco para el cliente.
+func encodeAlertCursor(ts time.Time, id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", ts.UnixNano(), id)))
+}
+
+func decodeAlertCursor(cursor string) (time.Time, uint, error) {
+	if cursor == "" {
+		return time.Time{}, 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	var ns int64
+	var id uint
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &ns, &id); err != nil || ns <= 0 || id == 0 {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, ns).UTC(), id, nil
 }
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+const (
+	CodeUnknownAttribute = "unknown_attribute"
+	CodeDuplicate        = "duplicate"
+
+	maxAttributesPerDevice   = 64
+	maxAttributeStringLength = 256
+)
+
+// Los nombres van dentro de rutas JSON en las consultas: solo minúsculas,
+// dígitos y guion bajo.
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var attributeTypes = map[string]bool{
+	domain.AttributeNumber:  true,
+	domain.AttributeInteger: true,
+	domain.AttributeBoolean: true,
+	domain.AttributeString:  true,
+}
+
+// ValidateAttributeSchema revisa nombres, tipos y duplicados de un esquema.
+func ValidateAttributeSchema(attrs []domain.DeviceAttribute) []FieldError {
+	var errs []FieldError
+	if len(attrs) > maxAttributesPerDevice {
+		errs = append(errs, FieldError{Field: "attributes", Code: CodeOutOfRange,
+			Message: fmt.Sprintf("máximo %d atributos por dispositivo", maxAttributesPerDevice)})
+	}
+
+	seen := make(map[string]bool, len(attrs))
+	for i, attr := range attrs {
+		field := fmt.Sprintf("attributes[%d]", i)
+		switch {
+		case !attributeNamePattern.MatchString(attr.Name):
+			errs = append(errs, FieldError{Field: field + ".name", Code: CodeInvalidFormat,
+				Message: "solo minúsculas, dígitos y _, empezando por letra (máx. 64)"})
+		case seen[attr.Name]:
+			errs = append(errs, FieldError{Field: field + ".name", Code: CodeDuplicate, Message: "atributo repetido"})
+		}
+		seen[attr.Name] = true
+
+		if !attributeTypes[attr.Type] {
+			errs = append(errs, FieldError{Field: field + ".type", Code: CodeInvalidType,
+				Message: "debe ser number, integer, boolean o string"})
+		}
+		if len(attr.Unit) > 16 {
+			errs = append(errs, FieldError{Field: field + ".unit", Code: CodeOutOfRange, Message: "máximo 16 caracteres"})
+		}
+	}
+	return errs
+}
+
+// checkAttributeValue devuelve un mensaje si el valor decodificado del JSON no
+// corresponde al tipo declarado.
+func checkAttributeValue(attrType string, v any) string {
+	switch attrType {
+	case domain.AttributeNumber:
+		if f, ok := v.(float64); !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "se esperaba un número"
+		}
+	case domain.AttributeInteger:
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return "se esperaba un entero"
+		}
+	case domain.AttributeBoolean:
+		if _, ok := v.(bool); !ok {
+			return "se esperaba true o false"
+		}
+	case domain.AttributeString:
+		s, ok := v.(string)
+		if !ok {
+			return "se esperaba un texto"
+		}
+		if len(s) > maxAttributeStringLength {
+			return fmt.Sprintf("máximo %d caracteres", maxAttributeStringLength)
+		}
+	}
+	return ""
+}
+
+// validateAttributes compara los atributos de la lectura con el esquema del
+// dispositivo. Un fallo al leer el esquema se devuelve como error.
+func (s *SensorService) validateAttributes(deviceID uint, attrs domain.Attributes) ([]FieldError, error) {
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+
+	schema, err := s.schemas.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []FieldError
+	for _, name := range names {
+		field := "attributes." + name
+		def, ok := schema[name]
+		if !ok {
+			errs = append(errs, FieldError{Field: field, Code: CodeUnknownAttribute, Message: "atributo no declarado para el dispositivo"})
+			continue
+		}
+		if msg := checkAttributeValue(def.Type, attrs[name]); msg != "" {
+			errs = append(errs, FieldError{Field: field, Code: CodeInvalidType, Message: msg})
+		}
+	}
+	return errs, nil
+}
+
+// AttributeFilter es un filtro de historial tal como llega en la query string.
+type AttributeFilter struct {
+	Name  string
+	Op    string // "=", ">=" o "<="
+	Value string
+}
+
+// attributeConditions convierte los filtros del historial en condiciones,
+// con cada valor en el tipo declarado del atributo. Los filtros inválidos
+// devuelven *ValidationError.
+func (s *SensorService) attributeConditions(deviceID uint, filters []AttributeFilter) ([]repository.AttributeCondition, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	schema, err := s.schemas.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []FieldError
+	conds := make([]repository.AttributeCondition, 0, len(filters))
+	for _, f := range filters {
+		field := "attr." + f.Name
+		def, ok := schema[f.Name]
+		if !ok {
+			errs = append(errs, FieldError{Field: field, Code: CodeUnknownAttribute, Message: "atributo no declarado para el dispositivo"})
+			continue
+		}
+
+		value, err := parseAttributeValue(def.Type, f.Value)
+		if err != nil {
+			errs = append(errs, FieldError{Field: field, Code: CodeInvalidType, Message: err.Error()})
+			continue
+		}
+		if f.Op != "=" && (def.Type == domain.AttributeBoolean || def.Type == domain.AttributeString) {
+			errs = append(errs, FieldError{Field: field, Code: CodeInvalidType, Message: "solo se admite igualdad para " + def.Type})
+			continue
+		}
+		conds = append(conds, repository.AttributeCondition{Name: f.Name, Op: f.Op, Value: value})
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return conds, nil
+}
+
+func parseAttributeValue(attrType, raw string) (any, error) {
+	switch attrType {
+	case domain.AttributeNumber:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba un número")
+		}
+		return f, nil
+	case domain.AttributeInteger:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba un entero")
+		}
+		return n, nil
+	case domain.AttributeBoolean:
+		b, err := strconv.ParseBool(strings.ToLower(raw))
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba true o false")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+type cachedSchema struct {
+	attrs   map[string]domain.DeviceAttribute
+	expires time.Time
+}
+
+// attributeSchemaCache recuerda el esquema de cada dispositivo durante
+// deviceCacheTTL; los cambios de esquema tardan ese tiempo en aplicarse a la ingesta.
+type attributeSchemaCache struct {
+	repo  repository.DeviceAttributeRepository
+	mu    sync.Mutex
+	items map[uint]cachedSchema
+}
+
+func newAttributeSchemaCache(repo repository.DeviceAttributeRepository) *attributeSchemaCache {
+	return &attributeSchemaCache{repo: repo, items: make(map[uint]cachedSchema)}
+}
+
+// Get devuelve un esquema vacío si no hay repositorio configurado.
+func (c *attributeSchemaCache) Get(deviceID uint) (map[string]domain.DeviceAttribute, error) {
+	if c == nil || c.repo == nil {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	item, ok := c.items[deviceID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(item.expires) {
+		return item.attrs, nil
+	}
+
+	list, err := c.repo.ListByDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]domain.DeviceAttribute, len(list))
+	for _, a := range list {
+		attrs[a.Name] = a
+	}
+
+	c.mu.Lock()
+	c.items[deviceID] = cachedSchema{attrs: attrs, expires: time.Now().Add(deviceCacheTTL)}
+	c.mu.Unlock()
+	return attrs, nil
+}
+
+// Forget descarta el esquema en caché, p. ej. tras modificarlo.
+func (c *attributeSchemaCache) Forget(deviceID uint) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.items, deviceID)
+	c.mu.Unlock()
+}
+
+// DeviceAttributeService administra el esquema de atributos de cada dispositivo.
+type DeviceAttributeService struct {
+	repo   repository.DeviceAttributeRepository
+	access *DeviceAccess
+	// Opcional: caché de la ingesta a invalidar al cambiar un esquema.
+	sensors *SensorService
+}
+
+func NewDeviceAttributeService(repo repository.DeviceAttributeRepository, access *DeviceAccess, sensors *SensorService) *DeviceAttributeService {
+	return &DeviceAttributeService{repo: repo, access: access, sensors: sensors}
+}
+
+// Schema devuelve el esquema a cualquiera con acceso al dispositivo.
+func (s *DeviceAttributeService) Schema(p Principal, deviceID uint) ([]domain.DeviceAttribute, error) {
+	if _, err := s.access.Authorize(p, deviceID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByDevice(deviceID)
+}
+
+// SetSchema reemplaza el esquema; solo el dueño o un admin. Los errores de
+// formato se devuelven como *ValidationError.
+func (s *DeviceAttributeService) SetSchema(p Principal, deviceID uint, attrs []domain.DeviceAttribute) ([]domain.DeviceAttribute, error) {
+	if _, err := s.access.AuthorizeManage(p, deviceID); err != nil {
+		return nil, err
+	}
+	for i := range attrs {
+		attrs[i].Name = strings.TrimSpace(attrs[i].Name)
+		attrs[i].Unit = strings.TrimSpace(attrs[i].Unit)
+	}
+	if errs := ValidateAttributeSchema(attrs); len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	if err := s.repo.Replace(deviceID, attrs); err != nil {
+		return nil, err
+	}
+	if s.sensors != nil {
+		s.sensors.schemas.Forget(deviceID)
+	}
+	return s.repo.ListByDevice(deviceID)
+}
@@ -9,12 +9,19 @@ import (
 	"github.com/nleea/fleet-monitoring/backend/internal/repository"
 )
 
+var (
+	ErrUserNotFound = errors.New("usuario no encontrado")
+	ErrAlreadyOwner = errors.New("el usuario ya es dueño del dispositivo")
+)
+
 type DeviceService struct {
-	repo repository.DeviceRepository
+	repo     repository.DeviceRepository
+	userRepo repository.UserRepository
+	access   *DeviceAccess
 }
 
-func NewDeviceService(repo repository.DeviceRepository) *DeviceService {
-	return &DeviceService{repo: repo}
+func NewDeviceService(repo repository.DeviceRepository, userRepo repository.UserRepository, access *DeviceAccess) *DeviceService {
+	return &DeviceService{repo: repo, userRepo: userRepo, access: access}
 }
 
 func (s *DeviceService) Register(ownerID uint, externalID string) (*domain.Device, error) {
@@ -47,6 +54,67 @@ func (s *DeviceService) ListByOwner(userID uint) ([]domain.Device, error) {
 	return s.repo.GetByOwner(userID)
 }
 
+// manageable devuelve el dispositivo si el principal es su dueño o admin;
+// compartir un dispositivo no da derecho a volver a compartirlo.
+func (s *DeviceService) manageable(p Principal, deviceID uint) (*domain.Device, error) {
+	dev, err := s.repo.GetByDeviceIdID(deviceID)
+	if err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	if !p.IsAdmin() && dev.OwnerID != p.UserID {
+		return nil, ErrDeviceForbidden
+	}
+	return dev, nil
+}
+
+// Share da acceso al dispositivo al usuario con ese email.
+func (s *DeviceService) Share(p Principal, deviceID uint, email string) (*domain.User, error) {
+	dev, err := s.manageable(p, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.ID == dev.OwnerID {
+		return nil, ErrAlreadyOwner
+	}
+
+	if err := s.repo.Share(dev.ID, user.ID); err != nil {
+		return nil, err
+	}
+	s.access.Forget(dev.ID)
+	return user, nil
+}
+
+// Unshare devuelve ErrUserNotFound si el dispositivo no estaba compartido con ese usuario.
+func (s *DeviceService) Unshare(p Principal, deviceID, userID uint) error {
+	dev, err := s.manageable(p, deviceID)
+	if err != nil {
+		return err
+	}
+	removed, err := s.repo.Unshare(dev.ID, userID)
+	if err != nil {
+		return err
+	}
+	// El acceso revocado debe dejar de valer ya, no al caducar la caché
+	s.access.Forget(dev.ID)
+	if !removed {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *DeviceService) ListShares(p Principal, deviceID uint) ([]domain.DeviceShare, error) {
+	dev, err := s.manageable(p, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListShares(dev.ID)
+}
+
 func maskExternalID(id string) string {
 	n := len(id)
 	if n == 0 {
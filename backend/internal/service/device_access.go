@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+var ErrDeviceForbidden = errors.New("sin acceso al dispositivo")
+
+// Principal es quien opera sobre un dispositivo: un usuario autenticado con
+// JWT o un dispositivo con su API key (DeviceID distinto de cero).
+type Principal struct {
+	UserID   uint
+	Role     string
+	DeviceID uint
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == string(domain.RoleAdmin)
+}
+
+// DeviceAccess resuelve si un principal puede usar un dispositivo: los admin
+// tienen acceso global, los usuarios al que poseen o les fue compartido y una
+// API key solo a su propio dispositivo.
+type DeviceAccess struct {
+	devices *deviceCache
+}
+
+func NewDeviceAccess(repo repository.DeviceRepository) *DeviceAccess {
+	return &DeviceAccess{devices: newDeviceCache(repo)}
+}
+
+// Forget descarta lo que se recuerda del dispositivo; se llama tras
+// modificarlo o cambiar con quién se comparte.
+func (a *DeviceAccess) Forget(deviceID uint) {
+	a.devices.Forget(deviceID)
+}
+
+// Authorize devuelve el dispositivo, ErrDeviceNotFound si no existe o fue
+// eliminado, o ErrDeviceForbidden si el principal no tiene acceso.
+func (a *DeviceAccess) Authorize(p Principal, deviceID uint) (*domain.Device, error) {
+	device, err := a.devices.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.DeviceID != 0:
+		if device.ID != p.DeviceID {
+			return nil, ErrDeviceForbidden
+		}
+	case p.IsAdmin(), device.OwnerID == p.UserID:
+	default:
+		shared, err := a.devices.SharedWith(device.ID, p.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !shared {
+			return nil, ErrDeviceForbidden
+		}
+	}
+	return device, nil
+}
+
+// AuthorizeManage exige ser el dueño o admin: el acceso compartido y las API
+// keys no permiten administrar el dispositivo.
+func (a *DeviceAccess) AuthorizeManage(p Principal, deviceID uint) (*domain.Device, error) {
+	device, err := a.devices.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if p.DeviceID != 0 || (!p.IsAdmin() && device.OwnerID != p.UserID) {
+		return nil, ErrDeviceForbidden
+	}
+	return device, nil
+}
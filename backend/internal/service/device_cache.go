@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Tiempo que se reutiliza un dispositivo leído de la BD durante la ingesta.
+const deviceCacheTTL = time.Minute
+
+type cachedDevice struct {
+	device  domain.Device
+	expires time.Time
+}
+
+type shareKey struct {
+	deviceID uint
+	userID   uint
+}
+
+type cachedShare struct {
+	shared  bool
+	expires time.Time
+}
+
+// deviceCache evita consultar la tabla devices en cada lectura ingerida. La
+// API y la ingesta comparten una sola (ver appcore.App.DeviceAccess), así que
+// un cambio invalidado con Forget se ve en todos los servicios a la vez.
+type deviceCache struct {
+	repo   repository.DeviceRepository
+	mu     sync.Mutex
+	items  map[uint]cachedDevice
+	shares map[shareKey]cachedShare
+}
+
+func newDeviceCache(repo repository.DeviceRepository) *deviceCache {
+	return &deviceCache{
+		repo:   repo,
+		items:  make(map[uint]cachedDevice),
+		shares: make(map[shareKey]cachedShare),
+	}
+}
+
+// Get devuelve ErrDeviceNotFound si el dispositivo no existe o fue eliminado.
+func (c *deviceCache) Get(id uint) (*domain.Device, error) {
+	c.mu.Lock()
+	item, ok := c.items[id]
+	c.mu.Unlock()
+	if ok && time.Now().Before(item.expires) {
+		dev := item.device
+		return &dev, nil
+	}
+
+	dev, err := c.repo.GetByDeviceIdID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.items[id] = cachedDevice{device: *dev, expires: time.Now().Add(deviceCacheTTL)}
+	c.mu.Unlock()
+	return dev, nil
+}
+
+// Forget descarta la copia en caché del dispositivo y de sus accesos
+// compartidos tras modificarlos.
+func (c *deviceCache) Forget(id uint) {
+	c.mu.Lock()
+	delete(c.items, id)
+	for key := range c.shares {
+		if key.deviceID == id {
+			delete(c.shares, key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// SharedWith indica si el dispositivo está compartido con el usuario. Solo se
+// recuerdan las respuestas positivas: un acceso recién concedido se ve al
+// instante y uno revocado en cuanto DeviceService llama a Forget.
+func (c *deviceCache) SharedWith(deviceID, userID uint) (bool, error) {
+	key := shareKey{deviceID: deviceID, userID: userID}
+	c.mu.Lock()
+	item, ok := c.shares[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(item.expires) {
+		return item.shared, nil
+	}
+
+	shared, err := c.repo.IsSharedWith(deviceID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if shared {
+		c.mu.Lock()
+		c.shares[key] = cachedShare{shared: true, expires: time.Now().Add(deviceCacheTTL)}
+		c.mu.Unlock()
+	}
+	return shared, nil
+}
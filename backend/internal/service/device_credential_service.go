@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeviceNotFound     = errors.New("dispositivo no encontrado")
+	ErrCredentialNotFound = errors.New("credencial no encontrada o ya revocada")
+	ErrInvalidDeviceKey   = errors.New("API key de dispositivo inválida")
+)
+
+// Frecuencia máxima con la que se actualiza last_used_at de una credencial.
+const credentialTouchInterval = time.Minute
+
+type DeviceCredentialService struct {
+	repo       repository.DeviceCredentialRepository
+	deviceRepo repository.DeviceRepository
+}
+
+func NewDeviceCredentialService(repo repository.DeviceCredentialRepository, deviceRepo repository.DeviceRepository) *DeviceCredentialService {
+	return &DeviceCredentialService{repo: repo, deviceRepo: deviceRepo}
+}
+
+// Issue emite una clave nueva para el dispositivo y devuelve el valor en claro,
+// que no vuelve a estar disponible.
+func (s *DeviceCredentialService) Issue(deviceID uint, name string, createdBy uint) (string, *domain.DeviceCredential, error) {
+	if _, err := s.deviceRepo.GetByDeviceIdID(deviceID); err != nil {
+		return "", nil, ErrDeviceNotFound
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	cred := &domain.DeviceCredential{
+		DeviceID:  deviceID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    prefix,
+		KeyHash:   utils.HashAPIKey(key),
+		CreatedBy: createdBy,
+	}
+	if err := s.repo.Create(cred); err != nil {
+		return "", nil, err
+	}
+	return key, cred, nil
+}
+
+func (s *DeviceCredentialService) List(deviceID uint) ([]domain.DeviceCredential, error) {
+	if _, err := s.deviceRepo.GetByDeviceIdID(deviceID); err != nil {
+		return nil, ErrDeviceNotFound
+	}
+	return s.repo.ListByDevice(deviceID)
+}
+
+func (s *DeviceCredentialService) Revoke(deviceID, credentialID uint) error {
+	revoked, err := s.repo.Revoke(deviceID, credentialID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+// Authenticate valida una clave presentada por un dispositivo. Solo una clave
+// desconocida, revocada o incorrecta da ErrInvalidDeviceKey; un fallo de la BD
+// se devuelve tal cual para no responder 401 a dispositivos válidos.
+func (s *DeviceCredentialService) Authenticate(key string) (*domain.DeviceCredential, error) {
+	prefix, err := utils.APIKeyPrefix(key)
+	if err != nil {
+		return nil, ErrInvalidDeviceKey
+	}
+
+	cred, err := s.repo.GetByPrefix(prefix)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidDeviceKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cred.RevokedAt != nil || !utils.CheckAPIKey(cred.KeyHash, key) {
+		return nil, ErrInvalidDeviceKey
+	}
+
+	now := time.Now().UTC()
+	if cred.LastUsedAt == nil || now.Sub(*cred.LastUsedAt) > credentialTouchInterval {
+		_ = s.repo.TouchLastUsed(cred.ID, now)
+	}
+	return cred, nil
+}
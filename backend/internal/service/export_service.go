@@ -0,0 +1,314 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+var (
+	ErrExportNotFound = errors.New("exportación no encontrada")
+	ErrExportNotReady = errors.New("la exportación aún no está lista")
+	ErrExportExpired  = errors.New("la exportación venció y su archivo se borró")
+)
+
+const (
+	// Lecturas leídas de la BD por bloque al exportar.
+	exportChunkSize = 5000
+	// Exportaciones que se generan a la vez; el resto espera su turno.
+	maxConcurrentExports = 2
+	// Máximo de dispositivos y de días por exportación.
+	maxExportDevices = 500
+	maxExportRange   = 366 * 24 * time.Hour
+	// Trabajos devueltos al listar.
+	exportListLimit = 100
+	// Intervalo mínimo entre dos barridos de exportaciones vencidas y trabajos
+	// vencidos por barrido.
+	exportExpirySweep = time.Hour
+	exportExpiryBatch = 500
+)
+
+// ExportRequest describe una exportación: dispositivos, rango [From, To),
+// columnas (vacío = todas) y formato csv o parquet.
+type ExportRequest struct {
+	DeviceIDs []uint
+	From      time.Time
+	To        time.Time
+	Columns   []string
+	Format    string
+}
+
+type ExportService struct {
+	repo    repository.ExportRepository
+	sensors repository.SensorRepository
+	rollups repository.RollupRepository
+	access  *DeviceAccess
+	store   *export.Store
+	slots   chan struct{}
+
+	// Con ttl mayor que cero los archivos terminados se borran al vencer.
+	ttl       time.Duration
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+}
+
+func NewExportService(repo repository.ExportRepository, sensors repository.SensorRepository, access *DeviceAccess, store *export.Store) *ExportService {
+	return &ExportService{
+		repo:    repo,
+		sensors: sensors,
+		access:  access,
+		store:   store,
+		slots:   make(chan struct{}, maxConcurrentExports),
+	}
+}
+
+// SetTTL hace que los archivos de las exportaciones terminadas se borren al
+// cumplir ttl y sus trabajos pasen a expired.
+func (s *ExportService) SetTTL(ttl time.Duration) {
+	s.ttl = ttl
+}
+
+// Recover marca como fallidos los trabajos interrumpidos por un reinicio y
+// borra las exportaciones vencidas.
+func (s *ExportService) Recover() error {
+	n, err := s.repo.FailInterrupted("interrumpida por un reinicio del servidor")
+	if n > 0 {
+		log.Printf("⚠️  %d exportaciones interrumpidas marcadas como fallidas", n)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.Expire(time.Now())
+	return err
+}
+
+// Expire borra los archivos de las exportaciones terminadas hace más de ttl,
+// marca sus trabajos como expired y devuelve cuántos venció. Sin ttl no hace
+// nada.
+func (s *ExportService) Expire(now time.Time) (int, error) {
+	if s.ttl <= 0 {
+		return 0, nil
+	}
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+	s.lastSweep = now
+
+	expired := 0
+	for {
+		jobs, err := s.repo.ListDoneBefore(now.Add(-s.ttl), exportExpiryBatch)
+		if err != nil {
+			return expired, err
+		}
+		for i := range jobs {
+			if err := s.store.Remove(jobs[i].FileName); err != nil {
+				return expired, err
+			}
+			jobs[i].Status = domain.ExportExpired
+			jobs[i].FileName = ""
+			if err := s.repo.Update(&jobs[i]); err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(jobs) < exportExpiryBatch {
+			break
+		}
+	}
+	if expired > 0 {
+		log.Printf("🧹 Exportaciones vencidas borradas: %d", expired)
+	}
+	return expired, nil
+}
+
+// sweep vence las exportaciones a lo sumo cada exportExpirySweep; el
+// directorio solo crece al crear exportaciones, así que basta con barrer ahí.
+func (s *ExportService) sweep() {
+	s.sweepMu.Lock()
+	due := time.Since(s.lastSweep) >= exportExpirySweep
+	s.sweepMu.Unlock()
+	if !due {
+		return
+	}
+	if _, err := s.Expire(time.Now()); err != nil {
+		log.Printf("❌ No se pudieron borrar las exportaciones vencidas: %v", err)
+	}
+}
+
+// Create valida la petición, comprueba que p pueda leer cada dispositivo y
+// encola el trabajo. Los errores de la petición son *ValidationError.
+func (s *ExportService) Create(p Principal, req ExportRequest) (*domain.ExportJob, error) {
+	var errs []FieldError
+	if req.Format != export.FormatCSV && req.Format != export.FormatParquet {
+		errs = append(errs, FieldError{Field: "format", Code: CodeInvalidFormat, Message: "debe ser csv o parquet"})
+	}
+	switch {
+	case len(req.DeviceIDs) == 0:
+		errs = append(errs, FieldError{Field: "device_ids", Code: CodeRequired, Message: "indique al menos un dispositivo"})
+	case len(req.DeviceIDs) > maxExportDevices:
+		errs = append(errs, FieldError{Field: "device_ids", Code: CodeOutOfRange, Message: fmt.Sprintf("máximo %d dispositivos", maxExportDevices)})
+	}
+	switch {
+	case req.From.IsZero() || req.To.IsZero():
+		errs = append(errs, FieldError{Field: "from", Code: CodeRequired, Message: "from y to son obligatorios"})
+	case !req.From.Before(req.To):
+		errs = append(errs, FieldError{Field: "to", Code: CodeOutOfRange, Message: "to debe ser posterior a from"})
+	case req.To.Sub(req.From) > maxExportRange:
+		errs = append(errs, FieldError{Field: "to", Code: CodeOutOfRange, Message: "el rango no puede superar 366 días"})
+	}
+	cols, err := export.SelectColumns(req.Columns)
+	if err != nil {
+		errs = append(errs, FieldError{Field: "columns", Code: CodeInvalidFormat, Message: err.Error()})
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	// Sin duplicados, en el orden pedido
+	seen := make(map[uint]bool, len(req.DeviceIDs))
+	devices := make([]uint, 0, len(req.DeviceIDs))
+	for _, id := range req.DeviceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := s.access.Authorize(p, id); err != nil {
+			return nil, err
+		}
+		devices = append(devices, id)
+	}
+
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	job := &domain.ExportJob{
+		UserID:    p.UserID,
+		DeviceIDs: devices,
+		From:      req.From.UTC(),
+		To:        req.To.UTC(),
+		Columns:   names,
+		Format:    req.Format,
+		Status:    domain.ExportPending,
+	}
+	if err := s.repo.Create(job); err != nil {
+		return nil, err
+	}
+	go s.sweep()
+
+	go s.run(*job, cols)
+	return job, nil
+}
+
+// SetRollupRepository hace que las exportaciones incluyan una fila por hora de
+// las lecturas que ya borró la retención.
+func (s *ExportService) SetRollupRepository(repo repository.RollupRepository) {
+	s.rollups = repo
+}
+
+// Get devuelve el trabajo si lo creó p; los administradores ven todos.
+func (s *ExportService) Get(p Principal, id uint) (*domain.ExportJob, error) {
+	job, err := s.repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Un trabajo ajeno se reporta como inexistente para no revelar ids.
+	if job.UserID != p.UserID && !p.IsAdmin() {
+		return nil, ErrExportNotFound
+	}
+	return job, nil
+}
+
+func (s *ExportService) List(p Principal) ([]domain.ExportJob, error) {
+	return s.repo.ListByUser(p.UserID, exportListLimit)
+}
+
+// File devuelve la ruta del archivo de un trabajo terminado y el nombre con el
+// que descargarlo.
+func (s *ExportService) File(p Principal, id uint) (path, name string, err error) {
+	job, err := s.Get(p, id)
+	if err != nil {
+		return "", "", err
+	}
+	if job.Status == domain.ExportExpired {
+		return "", "", ErrExportExpired
+	}
+	if job.Status != domain.ExportDone {
+		return "", "", ErrExportNotReady
+	}
+	name = fmt.Sprintf("telemetria-%d-%s.%s", job.ID, job.From.Format("20060102"), job.Format)
+	return s.store.Path(job.FileName), name, nil
+}
+
+// run genera el archivo recorriendo cada dispositivo por bloques. Las horas ya
+// resumidas por la retención van primero, como filas sin id con la última
+// posición y los promedios de la hora.
+func (s *ExportService) run(job domain.ExportJob, cols []export.Column) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	started := time.Now()
+	job.Status = domain.ExportRunning
+	job.StartedAt = &started
+	if err := s.repo.Update(&job); err != nil {
+		log.Printf("❌ Exportación %d: %v", job.ID, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("export-%d.%s", job.ID, job.Format)
+	size, err := s.store.Save(fileName, func(w io.Writer) error {
+		out, err := export.NewWriter(job.Format, w, cols)
+		if err != nil {
+			return err
+		}
+		for _, deviceID := range job.DeviceIDs {
+			hours, _, err := rollupReadings(s.rollups, deviceID, job.From, job.To)
+			if err != nil {
+				return err
+			}
+			if len(hours) > 0 {
+				job.Rows += int64(len(hours))
+				if err := out.Write(hours); err != nil {
+					return err
+				}
+			}
+			err = s.sensors.ForEachChunk(deviceID, job.From, job.To, exportChunkSize, func(chunk []domain.SensorData) error {
+				job.Rows += int64(len(chunk))
+				return out.Write(chunk)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return out.Close()
+	})
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if err != nil {
+		job.Status = domain.ExportFailed
+		job.Error = err.Error()
+		if len(job.Error) > 500 {
+			job.Error = job.Error[:500]
+		}
+		log.Printf("❌ Exportación %d fallida: %v", job.ID, err)
+	} else {
+		job.Status = domain.ExportDone
+		job.FileName = fileName
+		job.SizeBytes = size
+	}
+	if err := s.repo.Update(&job); err != nil {
+		log.Printf("❌ Exportación %d: %v", job.ID, err)
+	}
+}
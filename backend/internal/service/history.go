@@ -0,0 +1,165 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+// ErrInvalidCursor indica un cursor de paginación que no generó esta API.
+var ErrInvalidCursor = errors.New("cursor inválido")
+
+// HistoryQuery pide una página del historial; From es inclusivo y To exclusivo.
+// Con Attributes solo se devuelven lecturas crudas que cumplen los filtros.
+type HistoryQuery struct {
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Cursor     string
+	Attributes []AttributeFilter
+}
+
+// HistoryPage son las lecturas de la más reciente a la más antigua. Agotadas
+// las crudas, la página sigue en Rollups con los resúmenes horarios de las
+// lecturas que ya borró la retención, también del más reciente al más antiguo.
+// NextCursor está vacío en la última página.
+type HistoryPage struct {
+	Data       []domain.SensorData   `json:"data"`
+	Rollups    []domain.SensorRollup `json:"rollups,omitempty"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// SetRollupRepository hace que el historial, las agregaciones y los recorridos
+// incluyan los resúmenes horarios de las lecturas ya borradas.
+func (s *SensorService) SetRollupRepository(repo repository.RollupRepository) {
+	s.rollups = repo
+}
+
+// History devuelve una página del historial del dispositivo, si p tiene acceso.
+// Un rango sin lecturas devuelve Data vacío, no un error; los filtros de
+// atributos inválidos devuelven *ValidationError.
+func (s *SensorService) History(p Principal, deviceID uint, q HistoryQuery) (*HistoryPage, error) {
+	if _, err := s.access.Authorize(p, deviceID); err != nil {
+		return nil, err
+	}
+
+	before, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	conds, err := s.attributeConditions(deviceID, q.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Se pide una lectura de más para saber si hay otra página.
+	records, err := s.sensorRepo.GetRange(deviceID, repository.RangeQuery{
+		From:       q.From.UTC(),
+		To:         q.To.UTC(),
+		Before:     before,
+		Limit:      q.Limit + 1,
+		Attributes: conds,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &HistoryPage{Data: records}
+	if len(records) > q.Limit {
+		page.Data = records[:q.Limit]
+		page.NextCursor = encodeCursor(page.Data[q.Limit-1].TS)
+		return page, nil
+	}
+
+	// Más allá de la lectura cruda más antigua siguen los resúmenes horarios,
+	// que no conservan atributos.
+	if s.rollups == nil || len(conds) > 0 {
+		return page, nil
+	}
+	if len(records) > 0 {
+		before = records[len(records)-1].TS
+	}
+	free := q.Limit - len(records)
+	rollups, err := s.rollups.GetRange(deviceID, repository.RangeQuery{
+		From:   q.From.UTC(),
+		To:     q.To.UTC(),
+		Before: before,
+		Limit:  free + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rollups) > free {
+		rollups = rollups[:free]
+		if free > 0 {
+			page.NextCursor = encodeCursor(rollups[free-1].Hour)
+		} else {
+			page.NextCursor = encodeCursor(before)
+		}
+	}
+	if len(rollups) > 0 {
+		page.Rollups = rollups
+	}
+	return page, nil
+}
+
+// rollupReadings devuelve los resúmenes de [from, to) en orden cronológico
+// como una lectura por hora: la última posición de la hora con los promedios
+// de las métricas. Sirve a las salidas que solo entienden lecturas, como los
+// recorridos y las exportaciones. count es el total de lecturas que resumen.
+// Con repo nil no devuelve nada.
+func rollupReadings(repo repository.RollupRepository, deviceID uint, from, to time.Time) (readings []domain.SensorData, count int, err error) {
+	if repo == nil {
+		return nil, 0, nil
+	}
+	rollups, err := repo.GetRange(deviceID, repository.RangeQuery{From: from, To: to})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// GetRange los devuelve del más reciente al más antiguo.
+	slices.Reverse(rollups)
+	readings = make([]domain.SensorData, len(rollups))
+	for i, r := range rollups {
+		count += int(r.Count)
+		ts := r.LastTS
+		if ts.IsZero() {
+			ts = r.Hour
+		}
+		readings[i] = domain.SensorData{
+			DeviceID:    r.DeviceID,
+			TS:          ts,
+			Lat:         r.LastLat,
+			Lng:         r.LastLng,
+			Speed:       r.SpeedAvg,
+			FuelLevel:   r.FuelAvg,
+			Temperature: r.TemperatureAvg,
+		}
+	}
+	return readings, count, nil
+}
+
+// El cursor es el ts de la última lectura entregada, opaco para el cliente.
+func encodeCursor(ts time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(ts.UnixNano(), 10)))
+}
+
+func decodeCursor(cursor string) (time.Time, error) {
+	if cursor == "" {
+		return time.Time{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	ns, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || ns <= 0 {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return time.Unix(0, ns).UTC(), nil
+}
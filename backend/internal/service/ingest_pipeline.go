@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+// ErrQueueFull indica que la cola de ingesta no admite más lecturas; el
+// cliente debe reintentar más tarde.
+var ErrQueueFull = errors.New("cola de ingesta llena")
+
+var errPipelineStopped = errors.New("pipeline de ingesta detenido")
+
+// Espera máxima de una carga NDJSON en vivo a que la cola tenga espacio.
+const pipelineWaitTimeout = 30 * time.Second
+
+// PipelineConfig dimensiona el pipeline de ingesta. QueueSize se cuenta en
+// lecturas pendientes, sumando todas las particiones.
+type PipelineConfig struct {
+	Workers   int
+	QueueSize int
+}
+
+var DefaultPipelineConfig = PipelineConfig{Workers: 8, QueueSize: 10000}
+
+// PipelineStats es el estado de la cola para monitoreo.
+type PipelineStats struct {
+	Enabled   bool   `json:"enabled"`
+	Workers   int    `json:"workers"`
+	Capacity  int64  `json:"capacity"`
+	Depth     int64  `json:"depth"`
+	Processed uint64 `json:"processed"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+type ingestJob struct {
+	readings       []domain.SensorData
+	idempotencyKey string
+	reply          chan ingestReply
+}
+
+type ingestReply struct {
+	results []IngestResult
+	err     error
+}
+
+// ingestPipeline reparte los trabajos entre workers según el device_id, de
+// modo que los de un mismo dispositivo se procesan en orden de llegada.
+type ingestPipeline struct {
+	shards   []chan *ingestJob
+	capacity int64
+	process  func(*ingestJob)
+
+	depth     atomic.Int64
+	processed atomic.Uint64
+	rejected  atomic.Uint64
+
+	// Protege el envío a las particiones frente a su cierre en stop.
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newIngestPipeline(cfg PipelineConfig, process func(*ingestJob)) *ingestPipeline {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultPipelineConfig.Workers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultPipelineConfig.QueueSize
+	}
+
+	p := &ingestPipeline{
+		shards:   make([]chan *ingestJob, cfg.Workers),
+		capacity: int64(cfg.QueueSize),
+		process:  process,
+	}
+	for i := range p.shards {
+		// Cada trabajo lleva al menos una lectura, así que el envío nunca bloquea
+		// una vez reservada la capacidad.
+		p.shards[i] = make(chan *ingestJob, cfg.QueueSize)
+		p.wg.Add(1)
+		go p.work(p.shards[i])
+	}
+	return p
+}
+
+func (p *ingestPipeline) work(shard chan *ingestJob) {
+	defer p.wg.Done()
+	for job := range shard {
+		p.depth.Add(-int64(len(job.readings)))
+		p.process(job)
+		p.processed.Add(uint64(len(job.readings)))
+	}
+}
+
+// reserve ocupa n lugares de la cola. Un lote mayor que la capacidad total
+// solo entra con la cola vacía.
+func (p *ingestPipeline) reserve(n int64) bool {
+	for {
+		cur := p.depth.Load()
+		if cur > 0 && cur+n > p.capacity {
+			return false
+		}
+		if p.depth.CompareAndSwap(cur, cur+n) {
+			return true
+		}
+	}
+}
+
+// submit reparte las lecturas por dispositivo entre las particiones, un
+// trabajo por partición, y espera a que se persistan; los resultados vuelven
+// en el orden de entrada. Así las lecturas de un dispositivo se procesan
+// siempre en el mismo worker y en orden de llegada, aunque lleguen en lotes
+// mixtos. Cada trabajo se guarda en su propia transacción. Con wait se
+// reintenta hasta pipelineWaitTimeout en lugar de fallar con ErrQueueFull.
+func (p *ingestPipeline) submit(readings []domain.SensorData, idempotencyKey string, wait bool) ([]IngestResult, error) {
+	n := int64(len(readings))
+	deadline := time.Now().Add(pipelineWaitTimeout)
+	for !p.reserve(n) {
+		if !wait || time.Now().After(deadline) {
+			p.rejected.Add(uint64(n))
+			return nil, ErrQueueFull
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Posiciones en readings de las lecturas de cada partición
+	indexes := make(map[int][]int)
+	var order []int
+	for i, d := range readings {
+		shard := int(d.DeviceID % uint(len(p.shards)))
+		if _, ok := indexes[shard]; !ok {
+			order = append(order, shard)
+		}
+		indexes[shard] = append(indexes[shard], i)
+	}
+
+	jobs := make([]*ingestJob, len(order))
+	for j, shard := range order {
+		job := &ingestJob{idempotencyKey: idempotencyKey, reply: make(chan ingestReply, 1)}
+		if len(order) == 1 {
+			job.readings = readings
+		} else {
+			job.readings = make([]domain.SensorData, len(indexes[shard]))
+			for k, i := range indexes[shard] {
+				job.readings[k] = readings[i]
+			}
+		}
+		jobs[j] = job
+	}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		p.depth.Add(-n)
+		return nil, errPipelineStopped
+	}
+	for j, shard := range order {
+		p.shards[shard] <- jobs[j]
+	}
+	p.mu.RUnlock()
+
+	results := make([]IngestResult, len(readings))
+	var firstErr error
+	for j, shard := range order {
+		reply := <-jobs[j].reply
+		if reply.err != nil {
+			if firstErr == nil {
+				firstErr = reply.err
+			}
+			continue
+		}
+		for k, i := range indexes[shard] {
+			results[i] = reply.results[k]
+			readings[i].ID = jobs[j].readings[k].ID
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+func (p *ingestPipeline) stats() PipelineStats {
+	return PipelineStats{
+		Enabled:   true,
+		Workers:   len(p.shards),
+		Capacity:  p.capacity,
+		Depth:     p.depth.Load(),
+		Processed: p.processed.Load(),
+		Rejected:  p.rejected.Load(),
+	}
+}
+
+// stop deja de aceptar trabajos y espera a que se vacíen las particiones.
+func (p *ingestPipeline) stop() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, shard := range p.shards {
+			close(shard)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+// DefaultPositionStaleAfter es la antigüedad desde la que una posición se
+// considera desactualizada si no se configura otra.
+const DefaultPositionStaleAfter = 10 * time.Minute
+
+// DefaultPositionRefresh es cada cuánto Positions vuelve a leer de la BD la
+// última lectura de cada dispositivo si no se configura otro intervalo.
+const DefaultPositionRefresh = 5 * time.Second
+
+// Dispositivos por consulta al leer las últimas lecturas.
+const positionBatchSize = 500
+
+// DevicePosition es la última posición conocida de un dispositivo. Sin
+// lecturas, TS y AgeSeconds son nil y Stale es true.
+type DevicePosition struct {
+	DeviceID    uint       `json:"device_id"`
+	ExternalID  string     `json:"external_id"`
+	Lat         float64    `json:"lat"`
+	Lng         float64    `json:"lng"`
+	Speed       float64    `json:"speed"`
+	FuelLevel   float64    `json:"fuel_level"`
+	Temperature float64    `json:"temperature"`
+	TS          *time.Time `json:"ts"`
+	AgeSeconds  *int64     `json:"age_seconds"`
+	Stale       bool       `json:"stale"`
+}
+
+// positionCache guarda la lectura más reciente de cada dispositivo. Una
+// lectura más antigua que la guardada (backfill, reintentos) no la reemplaza.
+// Las lecturas de este proceso llegan al ingerirlas; las de otros procesos,
+// como el gateway, al refrescar desde la BD.
+type positionCache struct {
+	mu    sync.RWMutex
+	items map[uint]domain.SensorData
+
+	// refreshMu hace que peticiones simultáneas compartan un solo refresco.
+	// checked guarda cuándo se leyó de la BD cada dispositivo.
+	refreshMu sync.Mutex
+	checked   map[uint]time.Time
+}
+
+func newPositionCache() *positionCache {
+	return &positionCache{items: make(map[uint]domain.SensorData), checked: make(map[uint]time.Time)}
+}
+
+func (c *positionCache) update(readings []domain.SensorData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range readings {
+		if cur, ok := c.items[r.DeviceID]; ok && !r.TS.After(cur.TS) {
+			continue
+		}
+		r.Attributes = nil
+		c.items[r.DeviceID] = r
+	}
+}
+
+func (c *positionCache) get(deviceID uint) (domain.SensorData, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.items[deviceID]
+	return r, ok
+}
+
+// SetPositionStaleAfter cambia la antigüedad desde la que Positions marca una
+// posición como desactualizada.
+func (s *SensorService) SetPositionStaleAfter(d time.Duration) {
+	if d > 0 {
+		s.staleAfter = d
+	}
+}
+
+// SetPositionRefresh cambia cada cuánto Positions relee de la BD las últimas
+// lecturas.
+func (s *SensorService) SetPositionRefresh(d time.Duration) {
+	if d > 0 {
+		s.positionRefresh = d
+	}
+}
+
+// WarmPositions carga en la caché la última lectura de cada dispositivo; la
+// API lo llama al arrancar para que la primera consulta del mapa no espere.
+func (s *SensorService) WarmPositions() (int, error) {
+	devices, err := s.deviceRepo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uint, len(devices))
+	for i, dev := range devices {
+		ids[i] = dev.ID
+	}
+
+	s.positions.refreshMu.Lock()
+	defer s.positions.refreshMu.Unlock()
+	return s.loadPositions(ids, time.Now())
+}
+
+// refreshPositions relee la última lectura de los dispositivos que no se
+// leyeron de la BD en el último intervalo de refresco.
+func (s *SensorService) refreshPositions(devices []domain.Device) error {
+	s.positions.refreshMu.Lock()
+	defer s.positions.refreshMu.Unlock()
+
+	now := time.Now()
+	var due []uint
+	for _, dev := range devices {
+		if now.Sub(s.positions.checked[dev.ID]) >= s.positionRefresh {
+			due = append(due, dev.ID)
+		}
+	}
+	_, err := s.loadPositions(due, now)
+	return err
+}
+
+func (s *SensorService) loadPositions(ids []uint, now time.Time) (int, error) {
+	loaded := 0
+	for start := 0; start < len(ids); start += positionBatchSize {
+		batch := ids[start:min(start+positionBatchSize, len(ids))]
+		latest, err := s.sensorRepo.LatestForDevices(batch)
+		if err != nil {
+			return loaded, err
+		}
+		s.positions.update(latest)
+		for _, id := range batch {
+			s.positions.checked[id] = now
+		}
+		loaded += len(latest)
+	}
+	return loaded, nil
+}
+
+// Positions devuelve la última posición de cada dispositivo visible para el
+// principal (todos para un admin), ordenados por id. Solo se releen de la BD
+// los dispositivos visibles, cada uno a lo sumo cada positionRefresh, así que
+// las lecturas que guardan otros procesos aparecen con ese retraso máximo.
+func (s *SensorService) Positions(p Principal, now time.Time) ([]DevicePosition, error) {
+	var devices []domain.Device
+	var err error
+	if p.IsAdmin() {
+		devices, err = s.deviceRepo.GetAll()
+	} else {
+		devices, err = s.deviceRepo.GetVisibleTo(p.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshPositions(devices); err != nil {
+		return nil, err
+	}
+
+	positions := make([]DevicePosition, 0, len(devices))
+	for _, dev := range devices {
+		pos := DevicePosition{DeviceID: dev.ID, ExternalID: dev.ExternalID, Stale: true}
+		if r, ok := s.positions.get(dev.ID); ok {
+			ts := r.TS
+			age := int64(now.Sub(ts).Seconds())
+			pos.Lat, pos.Lng = r.Lat, r.Lng
+			pos.Speed, pos.FuelLevel, pos.Temperature = r.Speed, r.FuelLevel, r.Temperature
+			pos.TS = &ts
+			pos.AgeSeconds = &age
+			pos.Stale = now.Sub(ts) > s.staleAfter
+		}
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].DeviceID < positions[j].DeviceID })
+	return positions, nil
+}
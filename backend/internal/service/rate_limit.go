@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
+)
+
+// RateLimitError indica que el dispositivo superó su límite de ingesta.
+type RateLimitError struct {
+	DeviceID uint
+	Decision ratelimit.Decision
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("límite de ingesta excedido para el dispositivo %d", e.DeviceID)
+}
+
+// AsRateLimitError extrae el *RateLimitError de err, si lo es.
+func AsRateLimitError(err error) (*RateLimitError, bool) {
+	var rle *RateLimitError
+	if errors.As(err, &rle) {
+		return rle, true
+	}
+	return nil, false
+}
+
+// SetRateLimits activa el límite por dispositivo en la ingesta. Sin llamarlo
+// no se limita.
+func (s *SensorService) SetRateLimits(r *ratelimit.Registry) {
+	s.rateLimits = r
+}
+
+// AllowDevice consume un token del dispositivo, con el límite propio del
+// registro Device si lo tiene. El dispositivo ya debe estar autorizado.
+func (s *SensorService) AllowDevice(deviceID uint) (ratelimit.Decision, error) {
+	if s.rateLimits == nil {
+		return ratelimit.Decision{Allowed: true}, nil
+	}
+	dev, err := s.devices.Get(deviceID)
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+	d := s.rateLimits.AllowDevice(deviceID, deviceLimit(dev))
+	if !d.Allowed {
+		return d, &RateLimitError{DeviceID: deviceID, Decision: d}
+	}
+	return d, nil
+}
+
+// RateLimitStats devuelve los rechazos por ámbito; vacío si no hay límites.
+func (s *SensorService) RateLimitStats() ratelimit.Stats {
+	if s.rateLimits == nil {
+		return ratelimit.Stats{}
+	}
+	return s.rateLimits.Stats()
+}
+
+// deviceLimit es el límite propio del dispositivo, o nil para usar el de la
+// política. Sin ráfaga explícita se permite un segundo de lecturas.
+func deviceLimit(dev *domain.Device) *ratelimit.Limit {
+	if dev.RateLimitRPS == nil {
+		return nil
+	}
+	limit := ratelimit.Limit{Rate: *dev.RateLimitRPS, Burst: 1}
+	if dev.RateLimitBurst != nil {
+		limit.Burst = *dev.RateLimitBurst
+	} else if *dev.RateLimitRPS > 1 {
+		limit.Burst = int(*dev.RateLimitRPS)
+	}
+	return &limit
+}
+
+// SetDeviceRateLimit guarda el límite propio del dispositivo (nil lo quita) y
+// descarta la copia en caché para que se aplique de inmediato.
+func (s *SensorService) SetDeviceRateLimit(deviceID uint, rps *float64, burst *int) error {
+	ok, err := s.deviceRepo.SetRateLimit(deviceID, rps, burst)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	s.devices.Forget(deviceID)
+	return nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+// RetentionConfig define cuánto se conservan las lecturas crudas. Con Days en
+// 0 la retención está deshabilitada.
+type RetentionConfig struct {
+	Days      int
+	BatchSize int
+	Interval  time.Duration
+}
+
+var DefaultRetentionConfig = RetentionConfig{BatchSize: 5000, Interval: time.Hour}
+
+// RetentionService resume en sensor_rollups y borra las lecturas más antiguas
+// que la retención configurada.
+type RetentionService struct {
+	repo repository.RollupRepository
+	cfg  RetentionConfig
+
+	// Con sensor_data particionada por mes: meses a crear por adelantado.
+	partitions repository.SensorPartitions
+	ahead      int
+
+	// Claves de idempotencia y tiempo que se recuerdan.
+	keys   repository.IdempotencyKeyRepository
+	keyTTL time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewRetentionService(repo repository.RollupRepository, cfg RetentionConfig) *RetentionService {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultRetentionConfig.BatchSize
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultRetentionConfig.Interval
+	}
+	return &RetentionService{repo: repo, cfg: cfg}
+}
+
+// Cutoff es el inicio de la hora más antigua que se conserva cruda. Se alinea a
+// la hora para que ninguna quede repartida entre lecturas y resumen.
+func (r *RetentionService) Cutoff(now time.Time) time.Time {
+	return now.UTC().AddDate(0, 0, -r.cfg.Days).Truncate(time.Hour)
+}
+
+// SetPartitions hace que cada ejecución cree las particiones mensuales de los
+// próximos ahead meses y, con retención, resuma y borre las vencidas enteras.
+func (r *RetentionService) SetPartitions(p repository.SensorPartitions, ahead int) {
+	r.partitions = p
+	r.ahead = ahead
+}
+
+// SetIdempotencyKeys hace que cada ejecución borre las claves de idempotencia
+// con más de ttl de antigüedad; pasado ese plazo un reintento con la misma
+// clave sigue deduplicándose por (device_id, ts).
+func (r *RetentionService) SetIdempotencyKeys(keys repository.IdempotencyKeyRepository, ttl time.Duration) {
+	r.keys = keys
+	r.keyTTL = ttl
+}
+
+// purgeIdempotencyKeys borra por lotes las claves vencidas.
+func (r *RetentionService) purgeIdempotencyKeys(now time.Time) error {
+	if r.keys == nil || r.keyTTL <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-r.keyTTL)
+	var total int64
+	for {
+		n, err := r.keys.PurgeBefore(cutoff, r.cfg.BatchSize)
+		total += n
+		if err != nil {
+			return err
+		}
+		if n < int64(r.cfg.BatchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("🧹 Claves de idempotencia vencidas borradas: %d", total)
+	}
+	return nil
+}
+
+// RunOnce procesa lotes hasta que no quedan lecturas vencidas y devuelve
+// cuántas borró. Con particiones, los meses vencidos completos se resumen y se
+// eliminan con DROP; las lecturas vencidas del mes en curso, fila a fila.
+func (r *RetentionService) RunOnce(now time.Time) (int64, error) {
+	if r.partitions != nil {
+		if created, err := r.partitions.Ensure(now, r.ahead); err != nil {
+			return 0, err
+		} else if len(created) > 0 {
+			log.Printf("🗂️  Particiones creadas: %v", created)
+		}
+	}
+	if err := r.purgeIdempotencyKeys(now); err != nil {
+		return 0, err
+	}
+	if r.cfg.Days <= 0 {
+		return 0, nil
+	}
+	cutoff := r.Cutoff(now)
+
+	var total int64
+	if r.partitions != nil {
+		expired, err := r.partitions.Expired(cutoff)
+		if err != nil {
+			return 0, err
+		}
+		for _, p := range expired {
+			n, err := r.partitions.RollupAndDrop(p, r.cfg.BatchSize, rollUp)
+			total += n
+			if err != nil {
+				return total, err
+			}
+			log.Printf("🗂️  Partición %s resumida y eliminada (%d lecturas)", p.Name, n)
+		}
+	}
+
+	for {
+		n, err := r.repo.PurgeBatch(cutoff, r.cfg.BatchSize, rollUp)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(r.cfg.BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// Start ejecuta RunOnce de inmediato y luego cada Interval, hasta Stop. Corre
+// si hay retención, particiones o claves de idempotencia que mantener.
+func (r *RetentionService) Start() {
+	idle := r.cfg.Days <= 0 && r.partitions == nil && (r.keys == nil || r.keyTTL <= 0)
+	if idle || r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			start := time.Now()
+			deleted, err := r.RunOnce(start)
+			if err != nil {
+				log.Printf("❌ Retención: %v (%d lecturas borradas antes del error)", err, deleted)
+			} else if deleted > 0 {
+				log.Printf("🧹 Retención: %d lecturas resumidas y borradas en %s", deleted, time.Since(start).Round(time.Millisecond))
+			}
+
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop detiene el job y espera a que termine el lote en curso.
+func (r *RetentionService) Stop() {
+	if r.stop == nil {
+		return
+	}
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+// rollUp suma un bloque de lecturas, ordenado por dispositivo y ts, a los
+// resúmenes horarios ya guardados.
+func rollUp(prev map[repository.RollupKey]domain.SensorRollup, readings []domain.SensorData) []domain.SensorRollup {
+	var order []repository.RollupKey
+	acc := make(map[repository.RollupKey]*domain.SensorRollup)
+
+	for _, d := range readings {
+		key := repository.RollupKey{DeviceID: d.DeviceID, Hour: d.TS.UTC().Truncate(time.Hour)}
+		r, ok := acc[key]
+		if !ok {
+			existing := prev[key]
+			r = &existing
+			if r.Count == 0 {
+				r.DeviceID, r.Hour = key.DeviceID, key.Hour
+			}
+			acc[key] = r
+			order = append(order, key)
+		}
+
+		if r.Count == 0 {
+			r.FuelMin, r.FuelMax = d.FuelLevel, d.FuelLevel
+			r.TemperatureMin, r.TemperatureMax = d.Temperature, d.Temperature
+		} else {
+			r.DistanceKM += haversineKM(r.LastLat, r.LastLng, d.Lat, d.Lng)
+			r.FuelMin = math.Min(r.FuelMin, d.FuelLevel)
+			r.FuelMax = math.Max(r.FuelMax, d.FuelLevel)
+			r.TemperatureMin = math.Min(r.TemperatureMin, d.Temperature)
+			r.TemperatureMax = math.Max(r.TemperatureMax, d.Temperature)
+		}
+		r.SpeedMax = math.Max(r.SpeedMax, d.Speed)
+
+		// Promedios incrementales, válidos también al continuar un resumen guardado.
+		n := float64(r.Count + 1)
+		r.SpeedAvg += (d.Speed - r.SpeedAvg) / n
+		r.FuelAvg += (d.FuelLevel - r.FuelAvg) / n
+		r.TemperatureAvg += (d.Temperature - r.TemperatureAvg) / n
+		r.Count++
+
+		r.LastTS, r.LastLat, r.LastLng = d.TS, d.Lat, d.Lng
+	}
+
+	rollups := make([]domain.SensorRollup, len(order))
+	for i, key := range order {
+		rollups[i] = *acc[key]
+	}
+	return rollups
+}
+
+// haversineKM es la distancia en km sobre la superficie terrestre.
+func haversineKM(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKM = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKM * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+// Operadores de comparación de una regla.
+const (
+	OpGreater      = "gt"
+	OpGreaterEqual = "gte"
+	OpLess         = "lt"
+	OpLessEqual    = "lte"
+	OpEqual        = "eq"
+	OpNotEqual     = "neq"
+)
+
+// Métricas de la lectura evaluables por una regla; los atributos de
+// telemetría se indican como "attributes.<nombre>".
+var ruleMetrics = map[string]func(domain.SensorData) float64{
+	"speed":       func(d domain.SensorData) float64 { return d.Speed },
+	"fuel_level":  func(d domain.SensorData) float64 { return d.FuelLevel },
+	"temperature": func(d domain.SensorData) float64 { return d.Temperature },
+}
+
+const attributeMetricPrefix = "attributes."
+
+// metricValue devuelve el valor de la métrica en la lectura. Un atributo
+// ausente o no numérico no se evalúa; los booleanos cuentan como 1 y 0.
+func metricValue(metric string, d domain.SensorData) (float64, bool) {
+	if fn, ok := ruleMetrics[metric]; ok {
+		return fn(d), true
+	}
+	name, ok := strings.CutPrefix(metric, attributeMetricPrefix)
+	if !ok {
+		return 0, false
+	}
+	switch v := d.Attributes[name].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func compare(op string, value, threshold float64) bool {
+	switch op {
+	case OpGreater:
+		return value > threshold
+	case OpGreaterEqual:
+		return value >= threshold
+	case OpLess:
+		return value < threshold
+	case OpLessEqual:
+		return value <= threshold
+	case OpEqual:
+		return value == threshold
+	case OpNotEqual:
+		return value != threshold
+	}
+	return false
+}
+
+// stillActive indica si una regla ya disparada sigue en falta: el umbral se
+// corre Hysteresis hacia el lado normal para que el valor no oscile.
+func stillActive(rule domain.AlertRule, value float64) bool {
+	threshold := rule.Threshold
+	switch rule.Operator {
+	case OpGreater, OpGreaterEqual:
+		threshold -= rule.Hysteresis
+	case OpLess, OpLessEqual:
+		threshold += rule.Hysteresis
+	}
+	return compare(rule.Operator, value, threshold)
+}
+
+// RuleEvent es un cambio de estado de una regla para un dispositivo:
+// Triggered la condición se cumplió durante la duración de la regla; si no,
+// el valor volvió a la normalidad.
+type RuleEvent struct {
+	Rule      domain.AlertRule
+	DeviceID  uint
+	TS        time.Time
+	Value     float64
+	Triggered bool
+}
+
+type ruleKey struct {
+	ruleID   uint
+	deviceID uint
+}
+
+type ruleState struct {
+	lastTS       time.Time
+	pendingSince time.Time
+	active       bool
+}
+
+// RuleEngine evalúa las reglas habilitadas sobre cada lectura. El estado de
+// cada regla por dispositivo vive en memoria; al cargar las reglas, las
+// alertas sin resolver en la BD cuentan como activas, para que tras un
+// reinicio se resuelvan al normalizarse y no se abran de nuevo.
+type RuleEngine struct {
+	repo repository.AlertRuleRepository
+
+	mu      sync.Mutex
+	rules   []domain.AlertRule
+	loaded  bool
+	version repository.AlertRuleVersion
+	state   map[ruleKey]*ruleState
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewRuleEngine(repo repository.AlertRuleRepository) *RuleEngine {
+	return &RuleEngine{repo: repo, state: make(map[ruleKey]*ruleState), stop: make(chan struct{})}
+}
+
+// Reload vuelve a leer las reglas habilitadas. Las reglas eliminadas o
+// modificadas pierden su estado y empiezan de cero.
+func (e *RuleEngine) Reload() error {
+	// La versión se lee antes que las reglas: un cambio entre ambas lecturas
+	// se detecta en la siguiente verificación.
+	version, err := e.repo.Version()
+	if err != nil {
+		return err
+	}
+	rules, open, err := e.load()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.setRules(rules, open)
+	e.version = version
+	return nil
+}
+
+func (e *RuleEngine) load() ([]domain.AlertRule, []repository.OpenRuleAlert, error) {
+	rules, err := e.repo.ListEnabled()
+	if err != nil {
+		return nil, nil, err
+	}
+	open, err := e.repo.ListOpenAlerts()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, open, nil
+}
+
+// StartReloads verifica cada interval si las reglas cambiaron en la BD y, si
+// es así, las recarga. Así los procesos que no atienden la API de reglas,
+// como el gateway, ven las altas, cambios y bajas sin reiniciar.
+func (e *RuleEngine) StartReloads(interval time.Duration) {
+	if interval <= 0 || e.done != nil {
+		return
+	}
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				if err := e.reloadIfChanged(); err != nil {
+					log.Printf("[ERROR] No se pudieron recargar las reglas de alerta: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop detiene las verificaciones de StartReloads.
+func (e *RuleEngine) Stop() {
+	e.stopOnce.Do(func() { close(e.stop) })
+	if e.done != nil {
+		<-e.done
+	}
+}
+
+func (e *RuleEngine) reloadIfChanged() error {
+	version, err := e.repo.Version()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	changed := !e.loaded || version != e.version
+	e.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	return e.Reload()
+}
+
+// setRules reemplaza las reglas; las eliminadas o modificadas pierden su
+// estado. Los dispositivos sin estado con una alerta abierta de la regla
+// quedan activos.
+func (e *RuleEngine) setRules(rules []domain.AlertRule, open []repository.OpenRuleAlert) {
+	current := make(map[uint]time.Time, len(rules))
+	for _, r := range rules {
+		current[r.ID] = r.UpdatedAt
+	}
+	previous := make(map[uint]time.Time, len(e.rules))
+	for _, r := range e.rules {
+		previous[r.ID] = r.UpdatedAt
+	}
+	for key := range e.state {
+		updated, ok := current[key.ruleID]
+		if !ok || !updated.Equal(previous[key.ruleID]) {
+			delete(e.state, key)
+		}
+	}
+	for _, o := range open {
+		key := ruleKey{ruleID: o.RuleID, deviceID: o.DeviceID}
+		if _, ok := current[o.RuleID]; ok && e.state[key] == nil {
+			e.state[key] = &ruleState{active: true}
+		}
+	}
+	e.rules = rules
+	e.loaded = true
+}
+
+// Evaluate aplica las reglas a una lectura y devuelve los cambios de estado.
+// Las lecturas anteriores a la última evaluada para el dispositivo se ignoran.
+func (e *RuleEngine) Evaluate(d domain.SensorData) []RuleEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.loaded {
+		rules, open, err := e.load()
+		if err != nil {
+			log.Printf("[ERROR] No se pudieron cargar las reglas de alerta: %v", err)
+			return nil
+		}
+		e.setRules(rules, open)
+	}
+
+	var events []RuleEvent
+	for _, rule := range e.rules {
+		if !ruleApplies(rule, d.DeviceID) {
+			continue
+		}
+		value, ok := metricValue(rule.Metric, d)
+		if !ok {
+			continue
+		}
+
+		key := ruleKey{ruleID: rule.ID, deviceID: d.DeviceID}
+		st := e.state[key]
+		if st == nil {
+			st = &ruleState{}
+			e.state[key] = st
+		}
+		if !d.TS.After(st.lastTS) {
+			continue
+		}
+		st.lastTS = d.TS
+
+		if st.active {
+			if !stillActive(rule, value) {
+				st.active = false
+				st.pendingSince = time.Time{}
+				events = append(events, RuleEvent{Rule: rule, DeviceID: d.DeviceID, TS: d.TS, Value: value})
+			}
+			continue
+		}
+
+		if !compare(rule.Operator, value, rule.Threshold) {
+			st.pendingSince = time.Time{}
+			continue
+		}
+		if st.pendingSince.IsZero() {
+			st.pendingSince = d.TS
+		}
+		if d.TS.Sub(st.pendingSince) >= time.Duration(rule.DurationSeconds)*time.Second {
+			st.active = true
+			events = append(events, RuleEvent{Rule: rule, DeviceID: d.DeviceID, TS: d.TS, Value: value, Triggered: true})
+		}
+	}
+	return events
+}
+
+func ruleApplies(rule domain.AlertRule, deviceID uint) bool {
+	if len(rule.DeviceIDs) == 0 {
+		return true
+	}
+	for _, id := range rule.DeviceIDs {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
@@ -1,19 +1,25 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
 	"github.com/nleea/fleet-monitoring/backend/internal/repository"
 
 	"github.com/nleea/fleet-monitoring/backend/internal/ws"
 )
 
+// Filas por INSERT en las cargas masivas.
+const insertBatchSize = 500
+
 type SensorService struct {
 	sensorRepo repository.SensorRepository
 	alertRepo  repository.AlertRepository
@@ -26,9 +32,30 @@ type SensorService struct {
 
 	deviceNames map[uint]string
 	deviceRepo  repository.DeviceRepository
+	devices     *deviceCache
+	access      *DeviceAccess
+	schemas     *attributeSchemaCache
+
+	limits     TelemetryLimits
+	rateLimits *ratelimit.Registry
+
+	// Resúmenes horarios que continúan el historial tras la retención.
+	rollups repository.RollupRepository
+
+	// Última lectura de cada dispositivo, para el mapa de la flota.
+	positions       *positionCache
+	staleAfter      time.Duration
+	positionRefresh time.Duration
+
+	// Reglas de alerta configurables; nil si no hay motor.
+	rules *RuleEngine
+
+	// Nil hasta StartPipeline: entonces la ingesta es síncrona.
+	pipeline atomic.Pointer[ingestPipeline]
 }
 
 func NewSensorService(sensorRepo repository.SensorRepository, alertRepo repository.AlertRepository, hub *ws.Hub, deviceRepo repository.DeviceRepository) *SensorService {
+	devices := newDeviceCache(deviceRepo)
 	return &SensorService{
 		sensorRepo:  sensorRepo,
 		alertRepo:   alertRepo,
@@ -36,12 +63,117 @@ func NewSensorService(sensorRepo repository.SensorRepository, alertRepo reposito
 		activeAlert: make(map[uint]bool),
 		hub:         hub,
 		deviceRepo: deviceRepo,
+		devices:     devices,
+		access:      &DeviceAccess{devices: devices},
+		limits:      DefaultTelemetryLimits,
+		positions:   newPositionCache(),
+		staleAfter:  DefaultPositionStaleAfter,
+		positionRefresh: DefaultPositionRefresh,
+	}
+}
+
+// SetDeviceAccess hace que la ingesta use la misma caché de dispositivos que
+// el resto de servicios, para que Forget la invalide en todos.
+func (s *SensorService) SetDeviceAccess(access *DeviceAccess) {
+	s.access = access
+	s.devices = access.devices
+}
+
+// StartPipeline pasa la ingesta a una cola acotada atendida por workers
+// particionados por dispositivo. Las llamadas a Ingest e IngestBatch esperan a
+// que la lectura se persista, pero la emisión por WebSocket y la evaluación de
+// alertas ocurren en el worker, fuera del request. Con la cola llena devuelven
+// ErrQueueFull.
+func (s *SensorService) StartPipeline(cfg PipelineConfig) {
+	if s.pipeline.Load() != nil {
+		return
+	}
+	p := newIngestPipeline(cfg, s.processJob)
+	if !s.pipeline.CompareAndSwap(nil, p) {
+		p.stop()
+	}
+}
+
+// StopPipeline procesa lo ya encolado y vuelve a la ingesta síncrona. Es
+// seguro llamarlo con ingestas en curso: las que lleguen después de cerrar la
+// cola se guardan de forma síncrona.
+func (s *SensorService) StopPipeline() {
+	if p := s.pipeline.Swap(nil); p != nil {
+		p.stop()
+	}
+}
+
+// PipelineStats devuelve la profundidad de la cola y sus contadores.
+func (s *SensorService) PipelineStats() PipelineStats {
+	p := s.pipeline.Load()
+	if p == nil {
+		return PipelineStats{}
+	}
+	return p.stats()
+}
+
+func (s *SensorService) processJob(job *ingestJob) {
+	results, fresh, err := s.persist(job.readings, job.idempotencyKey)
+	job.reply <- ingestReply{results: results, err: err}
+	if err == nil {
+		s.afterIngest(fresh)
+	}
+}
+
+// persist guarda las lecturas y devuelve el resultado de cada una junto con
+// las que eran nuevas. El idempotencyKey solo aplica a lecturas individuales.
+func (s *SensorService) persist(readings []domain.SensorData, idempotencyKey string) ([]IngestResult, []domain.SensorData, error) {
+	var created []bool
+	if len(readings) == 1 {
+		ok, err := s.sensorRepo.Create(&readings[0], idempotencyKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		created = []bool{ok}
+	} else {
+		var err error
+		created, err = s.sensorRepo.CreateBatch(readings)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+
+	results := make([]IngestResult, len(readings))
+	fresh := make([]domain.SensorData, 0, len(readings))
+	for i := range readings {
+		results[i] = IngestResult{ID: readings[i].ID, Duplicate: !created[i]}
+		if created[i] {
+			fresh = append(fresh, readings[i])
+		}
+	}
+	// Antes de responder, para que la posición se vea en cuanto se guarda
+	s.positions.update(fresh)
+	return results, fresh, nil
+}
+
+// SetAttributeRepository habilita los atributos extendidos. Sin repositorio,
+// cualquier atributo en una lectura se rechaza como no declarado.
+func (s *SensorService) SetAttributeRepository(repo repository.DeviceAttributeRepository) {
+	s.schemas = newAttributeSchemaCache(repo)
+}
+
+// AuthorizeDevice verifica que el principal pueda escribir lecturas del
+// dispositivo; ver DeviceAccess.Authorize.
+func (s *SensorService) AuthorizeDevice(p Principal, deviceID uint) error {
+	_, err := s.access.Authorize(p, deviceID)
+	return err
+}
+
+// IngestResult indica si la lectura era nueva o un reintento ya guardado.
+type IngestResult struct {
+	ID        uint `json:"id"`
+	Duplicate bool `json:"duplicate"`
+	// Estado del límite del dispositivo tras consumir la lectura.
+	RateLimit ratelimit.Decision `json:"-"`
 }
 
 func (s *SensorService) IngestData(deviceID uint, lat, lng, speed, fuel, temp float64, ts ...time.Time) error {
-	data := &domain.SensorData{
-		Channel:     "Telemetry",
+	_, err := s.Ingest(&domain.SensorData{
 		DeviceID:    deviceID,
 		TS:          ts[0],
 		Lat:         lat,
@@ -49,21 +181,195 @@ func (s *SensorService) IngestData(deviceID uint, lat, lng, speed, fuel, temp fl
 		Speed:       speed,
 		FuelLevel:   fuel,
 		Temperature: temp,
+	}, "")
+	return err
+}
+
+// TrackerReading es un registro de un rastreador GPS. FuelLevel y Temperature
+// son nil cuando el equipo no reportó el elemento IO correspondiente.
+type TrackerReading struct {
+	TS          time.Time
+	Lat         float64
+	Lng         float64
+	Speed       float64
+	FuelLevel   *float64
+	Temperature *float64
+}
+
+// IngestTracker ingiere un registro de rastreador. Los valores no reportados
+// se toman de la última lectura del dispositivo en lugar de guardarse como 0,
+// que el análisis de combustible tomaría por un tanque vacío. No consume el
+// límite del dispositivo: el gateway lo hace con AllowDevice una vez por
+// paquete, que puede traer más registros que la ráfaga permitida.
+func (s *SensorService) IngestTracker(deviceID uint, r TrackerReading) error {
+	data := &domain.SensorData{DeviceID: deviceID, TS: r.TS, Lat: r.Lat, Lng: r.Lng, Speed: r.Speed}
+	if r.FuelLevel == nil || r.Temperature == nil {
+		last, err := s.sensorRepo.GetRecentByDevicePrimary(deviceID, 1)
+		if err != nil {
+			return err
+		}
+		if len(last) > 0 {
+			data.FuelLevel, data.Temperature = last[0].FuelLevel, last[0].Temperature
+		}
 	}
-	if err := s.sensorRepo.Create(data); err != nil {
-		return err
+	if r.FuelLevel != nil {
+		data.FuelLevel = *r.FuelLevel
+	}
+	if r.Temperature != nil {
+		data.Temperature = *r.Temperature
+	}
+	_, err := s.ingest(data, "", false)
+	return err
+}
+
+// Ingest valida y guarda una lectura de forma idempotente: un (device_id, ts)
+// repetido o un idempotencyKey ya visto devuelve la lectura original sin volver
+// a emitirla ni reevaluar alertas. Las lecturas inválidas devuelven
+// *ValidationError y las que superan el límite del dispositivo *RateLimitError.
+func (s *SensorService) Ingest(data *domain.SensorData, idempotencyKey string) (IngestResult, error) {
+	return s.ingest(data, idempotencyKey, true)
+}
+
+// ingest es Ingest; con charge en false no consume el límite del dispositivo.
+func (s *SensorService) ingest(data *domain.SensorData, idempotencyKey string, charge bool) (IngestResult, error) {
+	errs, err := s.ValidateReading(data, false)
+	if err != nil {
+		return IngestResult{}, err
+	}
+	if len(errs) > 0 {
+		return IngestResult{}, &ValidationError{Errors: errs}
+	}
+	decision := ratelimit.Decision{Allowed: true}
+	if charge {
+		if decision, err = s.AllowDevice(data.DeviceID); err != nil {
+			return IngestResult{}, err
+		}
+	}
+	data.Channel = "Telemetry"
+
+	if p := s.pipeline.Load(); p != nil {
+		results, err := p.submit([]domain.SensorData{*data}, idempotencyKey, false)
+		if !errors.Is(err, errPipelineStopped) {
+			if err != nil {
+				return IngestResult{}, err
+			}
+			data.ID = results[0].ID
+			results[0].RateLimit = decision
+			return results[0], nil
+		}
+	}
+
+	created, err := s.sensorRepo.Create(data, idempotencyKey)
+	if err != nil {
+		return IngestResult{}, err
+	}
+	if !created {
+		return IngestResult{ID: data.ID, Duplicate: true, RateLimit: decision}, nil
+	}
+
+	s.positions.update([]domain.SensorData{*data})
+	s.broadcastTelemetry(data)
+	s.evaluateRules([]domain.SensorData{*data})
+
+	return IngestResult{ID: data.ID, RateLimit: decision}, s.checkFuelAlert(data.DeviceID)
+}
+
+// IngestBatch persiste un lote de lecturas, ya validadas con ValidateReading, y
+// evalúa la alerta de combustible una sola vez por dispositivo, no por
+// lectura. Con el pipeline activo se usa una transacción por partición.
+func (s *SensorService) IngestBatch(readings []domain.SensorData) ([]IngestResult, error) {
+	return s.ingestBatch(readings, false)
+}
+
+func (s *SensorService) ingestBatch(readings []domain.SensorData, wait bool) ([]IngestResult, error) {
+	if len(readings) == 0 {
+		return nil, nil
+	}
+	if p := s.pipeline.Load(); p != nil {
+		results, err := p.submit(readings, "", wait)
+		if !errors.Is(err, errPipelineStopped) {
+			return results, err
+		}
+	}
+
+	results, fresh, err := s.persist(readings, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.afterIngest(fresh)
+	return results, nil
+}
+
+// IngestChunk persiste un bloque de una carga masiva (NDJSON), ya validado con
+// ValidateReading, y devuelve cuántas lecturas eran nuevas. En modo backfill
+// se usan INSERTs por lotes y no se emite telemetría por WebSocket ni se
+// evalúan alertas: son datos históricos y no deben inundar el dashboard.
+func (s *SensorService) IngestChunk(readings []domain.SensorData, backfill bool) (int, error) {
+	if len(readings) == 0 {
+		return 0, nil
+	}
+
+	if backfill {
+		inserted, err := s.sensorRepo.CreateInBatches(readings, insertBatchSize)
+		if err == nil {
+			s.positions.update(readings)
+		}
+		return int(inserted), err
+	}
+
+	// Una carga en vivo espera a que haya espacio en la cola en lugar de
+	// rechazar el bloque.
+	results, err := s.ingestBatch(readings, true)
+	if err != nil {
+		return 0, err
+	}
+	inserted := 0
+	for _, r := range results {
+		if !r.Duplicate {
+			inserted++
+		}
+	}
+	return inserted, nil
+}
+
+// afterIngest emite la telemetría, evalúa las reglas en cada lectura y el
+// combustible una vez por dispositivo.
+func (s *SensorService) afterIngest(readings []domain.SensorData) {
+	var devices []uint
+	seen := make(map[uint]bool)
+	for i := range readings {
+		s.broadcastTelemetry(&readings[i])
+		if !seen[readings[i].DeviceID] {
+			seen[readings[i].DeviceID] = true
+			devices = append(devices, readings[i].DeviceID)
+		}
+	}
+	s.evaluateRules(readings)
+
+	for _, deviceID := range devices {
+		if err := s.checkFuelAlert(deviceID); err != nil {
+			log.Printf("[ERROR] Dev:%d - Falló la evaluación de combustible del lote: %v", deviceID, err)
+		}
+	}
+}
+
+func (s *SensorService) broadcastTelemetry(data *domain.SensorData) {
+	if s.hub == nil {
+		return
 	}
 
 	go s.hub.Broadcast(
 		"telemetry",
 		map[string]any{
-			"device_id":   deviceID,
-			"lat":         lat,
-			"lng":         lng,
-			"speed":       speed,
-			"fuel":        fuel,
-			"temperature": temp,
-			"ts":   ts[0].Format(time.RFC3339),
+			"device_id":   data.DeviceID,
+			"lat":         data.Lat,
+			"lng":         data.Lng,
+			"speed":       data.Speed,
+			"fuel":        data.FuelLevel,
+			"temperature": data.Temperature,
+			"attributes":  data.Attributes,
+			"ts":          data.TS.Format(time.RFC3339),
 		},
 		map[string]any{
 			"timestamp": time.Now().Format(time.RFC3339),
@@ -71,8 +377,6 @@ func (s *SensorService) IngestData(deviceID uint, lat, lng, speed, fuel, temp fl
 		},
 		"admin", "user",
 	)
-
-	return s.checkFuelAlert(deviceID)
 }
 
 func (s *SensorService) GetSensorDataByDeviceID(deviceID uint) (*[]domain.SensorData, error) {
@@ -86,8 +390,8 @@ func (s *SensorService) GetSensorDataByDeviceID(deviceID uint) (*[]domain.Sensor
 }
 
 func (s *SensorService) PredictiveFuelCheck(deviceID uint) (bool, float64, error) {
-	// Análisis de tendencia
-	recent, err := s.sensorRepo.GetRecentByDevice(deviceID, 100)
+	// Análisis de tendencia, desde la primaria: debe incluir la lectura recién guardada
+	recent, err := s.sensorRepo.GetRecentByDevicePrimary(deviceID, 100)
 	if err != nil {
 		return false, 0, err
 	}
@@ -234,7 +538,7 @@ func (s *SensorService) PredictiveFuelCheck(deviceID uint) (bool, float64, error
 	return debeAlertar, autonomiaMinutos, nil
 }
 
-func (s *SensorService) broadcastAlert(alert *domain.Alert) {
+func (s *SensorService) broadcastAlert(alert *domain.Alert, message, severity string) {
 	if s.hub == nil {
 		return
 	}
@@ -245,8 +549,9 @@ func (s *SensorService) broadcastAlert(alert *domain.Alert) {
 		"device_id": alert.DeviceID,
 		"timestamp": alert.TS.Format(time.RFC3339),
 		"payload":   string(alert.Payload),
-		"message":   "🚨 Combustible crítico detectado",
+		"message":   message,
 		"id":        alert.ID,
+		"status":    domain.AlertOpen,
 	}
 
 	go s.hub.Broadcast(
@@ -255,7 +560,7 @@ func (s *SensorService) broadcastAlert(alert *domain.Alert) {
 		map[string]any{
 			"timestamp": alert.TS.Format(time.RFC3339),
 			"source":    "AlertService",
-			"severity":  "critical",
+			"severity":  severity,
 		},
 		"admin",
 	)
@@ -264,7 +569,7 @@ func (s *SensorService) broadcastAlert(alert *domain.Alert) {
 func (s *SensorService) checkFuelAlert(deviceID uint) error {
 	s.mu.Lock()
 	lastAlertTime, hasLastAlert := s.lastAlert[deviceID]
-	isActive := s.activeAlert[deviceID]
+	isActive, known := s.activeAlert[deviceID]
 	s.mu.Unlock()
 
 	if hasLastAlert && time.Since(lastAlertTime) < 5*time.Second {
@@ -277,10 +582,19 @@ func (s *SensorService) checkFuelAlert(deviceID uint) error {
 		return err
 	}
 
+	// Sin estado en memoria, como tras un reinicio, la alerta activa se toma
+	// de la BD: así se resuelve al normalizarse y no se abre otra.
+	if !known {
+		if isActive, err = s.alertRepo.HasOpen(deviceID, domain.AlertFuelLow, nil); err != nil {
+			return err
+		}
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.lastAlert[deviceID] = time.Now()
+	s.activeAlert[deviceID] = isActive
 
 	if debeAlertar && !isActive {
 		s.activeAlert[deviceID] = true
@@ -291,6 +605,7 @@ func (s *SensorService) checkFuelAlert(deviceID uint) error {
 			DeviceID: deviceID,
 			Type:     domain.AlertFuelLow,
 			TS:       time.Now().UTC(),
+			Severity: domain.SeverityCritical,
 			Payload: []byte(fmt.Sprintf(
 				`{"device_name":"%s","autonomy_minutes":%.2f,"autonomy_hours":%.2f}`,
 				deviceName,
@@ -304,7 +619,7 @@ func (s *SensorService) checkFuelAlert(deviceID uint) error {
 			return err
 		}
 
-		s.broadcastAlert(alert)
+		s.broadcastAlert(alert, "🚨 Combustible crítico detectado", domain.SeverityCritical)
 
 		log.Printf("[ALERT] 🚨 ALERTA ACTIVADA - Dispositivo %d: Combustible crítico, autonomía %.0f min (%.1f horas)",
 			deviceID, autonomiaMinutos, autonomiaMinutos/60)
@@ -312,6 +627,8 @@ func (s *SensorService) checkFuelAlert(deviceID uint) error {
 
 	if !debeAlertar && isActive {
 		s.activeAlert[deviceID] = false
+		s.resolveAlerts(deviceID, domain.AlertFuelLow, nil,
+			fmt.Sprintf("auto: combustible normalizado, autonomía %.0f min", autonomiaMinutos))
 		log.Printf("[INFO] ✅ ALERTA RESUELTA - Dispositivo %d: Combustible normalizado, autonomía %.0f min (%.1f horas)",
 			deviceID, autonomiaMinutos, autonomiaMinutos/60)
 	}
@@ -319,6 +636,19 @@ func (s *SensorService) checkFuelAlert(deviceID uint) error {
 	return nil
 }
 
+// resolveAlerts cierra las alertas sin resolver del dispositivo y emite cada
+// cambio de estado.
+func (s *SensorService) resolveAlerts(deviceID uint, alertType domain.AlertType, ruleID *uint, reason string) {
+	resolved, err := s.alertRepo.ResolveOpen(deviceID, alertType, ruleID, reason, time.Now().UTC())
+	if err != nil {
+		log.Printf("[ERROR] No se pudieron resolver las alertas %s del dispositivo %d: %v", alertType, deviceID, err)
+		return
+	}
+	for i := range resolved {
+		broadcastAlertStatus(s.hub, &resolved[i])
+	}
+}
+
 func (s *SensorService) getDeviceName(deviceID uint) string {
 	if s.deviceNames == nil {
 		s.deviceNames = make(map[uint]string)
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+// Códigos de error por campo devueltos en las respuestas 422.
+const (
+	CodeRequired      = "required"
+	CodeInvalidType   = "invalid_type"
+	CodeInvalidFormat = "invalid_format"
+	CodeOutOfRange    = "out_of_range"
+	CodeInFuture      = "in_future"
+	CodeTooOld        = "too_old"
+	CodeUnknownDevice = "unknown_device"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError agrupa todos los problemas de una lectura para reportarlos juntos.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return "lectura inválida: " + strings.Join(parts, "; ")
+}
+
+// AsValidationError devuelve los errores por campo si err es de validación.
+func AsValidationError(err error) ([]FieldError, bool) {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr.Errors, true
+	}
+	return nil, false
+}
+
+// TelemetryLimits define qué lecturas se consideran plausibles.
+type TelemetryLimits struct {
+	MinTemperature float64
+	MaxTemperature float64
+	MaxSpeed       float64
+	// Tolerancia a relojes adelantados.
+	MaxFuture time.Duration
+	// Antigüedad máxima en ingesta en vivo; 0 la deshabilita. No aplica a backfill.
+	MaxAge time.Duration
+}
+
+var DefaultTelemetryLimits = TelemetryLimits{
+	MinTemperature: -50,
+	MaxTemperature: 125,
+	MaxSpeed:       300,
+	MaxFuture:      5 * time.Minute,
+	MaxAge:         30 * 24 * time.Hour,
+}
+
+// SetValidationLimits reemplaza los límites por defecto (p. ej. desde config).
+func (s *SensorService) SetValidationLimits(limits TelemetryLimits) {
+	s.limits = limits
+}
+
+// ValidateReading revisa rangos físicos, marca de tiempo, que el dispositivo
+// exista y que los atributos extendidos estén declarados en su esquema. En backfill se aceptan lecturas antiguas.
+// Los errores de la BD al consultar el dispositivo o su esquema se devuelven
+// aparte, no como errores de validación.
+func (s *SensorService) ValidateReading(data *domain.SensorData, backfill bool) ([]FieldError, error) {
+	var errs []FieldError
+	add := func(field, code, msg string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(msg, args...)})
+	}
+	inRange := func(field string, v, min, max float64) {
+		if math.IsNaN(v) || v < min || v > max {
+			add(field, CodeOutOfRange, "debe estar entre %g y %g", min, max)
+		}
+	}
+
+	if data.DeviceID == 0 {
+		add("device_id", CodeRequired, "campo requerido")
+	}
+
+	inRange("lat", data.Lat, -90, 90)
+	inRange("lng", data.Lng, -180, 180)
+	inRange("fuel_level", data.FuelLevel, 0, 100)
+	inRange("speed", data.Speed, 0, s.limits.MaxSpeed)
+	inRange("temperature", data.Temperature, s.limits.MinTemperature, s.limits.MaxTemperature)
+
+	now := time.Now()
+	switch {
+	case data.TS.IsZero():
+		add("ts", CodeRequired, "campo requerido")
+	case data.TS.After(now.Add(s.limits.MaxFuture)):
+		add("ts", CodeInFuture, "no puede estar más de %s en el futuro", s.limits.MaxFuture)
+	case !backfill && s.limits.MaxAge > 0 && data.TS.Before(now.Add(-s.limits.MaxAge)):
+		add("ts", CodeTooOld, "no puede tener más de %s de antigüedad", s.limits.MaxAge)
+	}
+
+	if data.DeviceID != 0 {
+		_, err := s.devices.Get(data.DeviceID)
+		switch {
+		case errors.Is(err, ErrDeviceNotFound):
+			add("device_id", CodeUnknownDevice, "dispositivo %d no registrado", data.DeviceID)
+		case err != nil:
+			// Un fallo de la BD no invalida la lectura: el cliente debe reintentarla
+			return nil, err
+		default:
+			attrErrs, err := s.validateAttributes(data.DeviceID, data.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			errs = append(errs, attrErrs...)
+		}
+	}
+
+	return errs, nil
+}
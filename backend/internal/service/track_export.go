@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+)
+
+const (
+	// Lecturas por bloque al generar un recorrido.
+	trackExportChunkSize = 5000
+	// Rango máximo de un recorrido descargable.
+	maxTrackExportRange = 366 * 24 * time.Hour
+)
+
+var ErrTrackRangeTooLong = errors.New("el rango del recorrido no puede superar 366 días")
+
+// ExportTrack autoriza el dispositivo, abre el escritor con open y le pasa las
+// lecturas de [from, to) por bloques en orden cronológico, precedidas por una
+// posición por hora de las que ya borró la retención. Los errores previos a
+// open permiten responder con un código HTTP; los posteriores no.
+func (s *SensorService) ExportTrack(p Principal, deviceID uint, from, to time.Time, open func(*domain.Device) (export.Writer, error)) error {
+	if to.Sub(from) > maxTrackExportRange {
+		return ErrTrackRangeTooLong
+	}
+	dev, err := s.access.Authorize(p, deviceID)
+	if err != nil {
+		return err
+	}
+
+	hours, _, err := rollupReadings(s.rollups, deviceID, from.UTC(), to.UTC())
+	if err != nil {
+		return err
+	}
+
+	out, err := open(dev)
+	if err != nil {
+		return err
+	}
+	if len(hours) > 0 {
+		if err := out.Write(hours); err != nil {
+			return err
+		}
+	}
+	err = s.sensorRepo.ForEachChunk(deviceID, from.UTC(), to.UTC(), trackExportChunkSize, out.Write)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
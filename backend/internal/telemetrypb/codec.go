@@ -0,0 +1,94 @@
+// Package telemetrypb contiene los mensajes de telemetry.proto, generados con
+// protoc-gen-go, y las conversiones que necesita la API de ingesta.
+package telemetrypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative telemetry.proto
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType es el tipo MIME con el que se negocia protobuf.
+const ContentType = "application/x-protobuf"
+
+// Schema es el .proto publicado para los clientes.
+//
+//go:embed telemetry.proto
+var Schema []byte
+
+var ErrMalformed = errors.New("protobuf: mensaje mal formado")
+
+// Float64 convierte un float del cable a su representación decimal más corta
+// (70.3 y no 70.30000305).
+func Float64(f float32) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return v
+}
+
+// UnmarshalReading decodifica una lectura.
+func UnmarshalReading(b []byte) (*Reading, error) {
+	r := &Reading{}
+	if err := proto.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return r, nil
+}
+
+// AttributeMap devuelve los atributos de la lectura con el valor del oneof.
+// Los enteros se devuelven como float64, igual que al decodificar JSON.
+func AttributeMap(r *Reading) (map[string]any, error) {
+	if len(r.GetAttributes()) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]any, len(r.GetAttributes()))
+	for name, value := range r.GetAttributes() {
+		if name == "" {
+			return nil, fmt.Errorf("%w: atributo sin nombre", ErrMalformed)
+		}
+		switch v := value.GetValue().(type) {
+		case *AttributeValue_NumberValue:
+			out[name] = v.NumberValue
+		case *AttributeValue_IntegerValue:
+			out[name] = float64(v.IntegerValue)
+		case *AttributeValue_BoolValue:
+			out[name] = v.BoolValue
+		case *AttributeValue_StringValue:
+			out[name] = v.StringValue
+		default:
+			out[name] = nil
+		}
+	}
+	return out, nil
+}
+
+// SplitBatch devuelve cada Reading de un ReadingBatch sin decodificar, para
+// poder rechazar lecturas individuales sin descartar el lote.
+func SplitBatch(b []byte) ([][]byte, error) {
+	readings := (&ReadingBatch{}).ProtoReflect().Descriptor().Fields().ByName("readings").Number()
+	var items [][]byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if num == readings && typ == protowire.BytesType {
+			var raw []byte
+			raw, n = protowire.ConsumeBytes(b)
+			items = append(items, raw)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return items, nil
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Formato de las API keys de dispositivo: fdk_<prefijo>_<secreto>.
+// El prefijo es público y sirve para buscar la credencial sin escanear la tabla.
+const apiKeyScheme = "fdk"
+
+func GenerateAPIKey() (key, prefix string, err error) {
+	p := make([]byte, 4)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(p)
+	return apiKeyScheme + "_" + prefix + "_" + hex.EncodeToString(secret), prefix, nil
+}
+
+// APIKeyPrefix extrae el prefijo de una clave con el formato esperado.
+func APIKeyPrefix(key string) (string, error) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme || len(parts[1]) != 8 || parts[2] == "" {
+		return "", errors.New("formato de API key inválido")
+	}
+	return parts[1], nil
+}
+
+// HashAPIKey usa SHA-256: las claves son aleatorias de alta entropía, así que no
+// necesitan el coste de bcrypt y se validan en cada lectura ingerida.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func CheckAPIKey(hash, key string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKey(key))) == 1
+}
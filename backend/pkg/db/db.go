@@ -0,0 +1,110 @@
+// Package db abre la conexión de GORM con el driver que corresponde al
+// esquema del DSN y aplica la configuración del pool.
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Driver construye el dialecto de GORM a partir del DSN completo.
+type Driver func(dsn string) (gorm.Dialector, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register asocia un esquema de DSN ("postgres", "file", ...) con un driver.
+func Register(scheme string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[strings.ToLower(scheme)] = driver
+}
+
+// PoolConfig limita las conexiones abiertas; en cero se usa el valor por
+// defecto de database/sql (sin límite ni vencimiento).
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// Dialector elige el driver por el esquema del DSN. Un DSN clave=valor sin
+// esquema ("host=... user=...") se interpreta como PostgreSQL.
+func Dialector(dsn string) (gorm.Dialector, error) {
+	if dsn == "" {
+		return nil, errors.New("DB_DSN vacío")
+	}
+	scheme, ok := dsnScheme(dsn)
+	if !ok {
+		if strings.Contains(dsn, "=") {
+			scheme = "postgres"
+		} else {
+			return nil, errors.New("DB_DSN sin esquema: use postgres://, sqlite:// o file:")
+		}
+	}
+
+	driversMu.RLock()
+	driver, found := drivers[scheme]
+	driversMu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("esquema de DB_DSN no soportado: %q", scheme)
+	}
+	return driver(dsn)
+}
+
+// Connect abre la base, aplica el pool y verifica la conexión; un ping fallido
+// es un error, no una advertencia.
+func Connect(dsn string, pool PoolConfig) (*gorm.DB, error) {
+	dialector, err := Dialector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("no se pudo verificar la conexión: %w", err)
+	}
+
+	log.Printf("✅ Conectado a %s", dialector.Name())
+	return db, nil
+}
+
+// dsnScheme devuelve lo que precede a ":" si tiene forma de esquema.
+func dsnScheme(dsn string) (string, bool) {
+	i := strings.Index(dsn, ":")
+	if i <= 0 {
+		return "", false
+	}
+	for _, r := range dsn[:i] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			return "", false
+		}
+	}
+	return strings.ToLower(dsn[:i]), true
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tiempo máximo del ping que decide si una réplica sigue disponible.
+const replicaPingTimeout = 2 * time.Second
+
+// replica es una base de solo lectura. Si no se pudo abrir al arrancar, db
+// queda vacío y las verificaciones reintentan la conexión con dsn.
+type replica struct {
+	db      atomic.Pointer[gorm.DB]
+	healthy atomic.Bool
+
+	dsn  string
+	pool PoolConfig
+}
+
+// ReadRouter reparte las consultas de solo lectura entre réplicas en ronda y
+// usa la primaria cuando no hay ninguna disponible. Sin réplicas, todo va a la
+// primaria.
+type ReadRouter struct {
+	primary  *gorm.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+func NewReadRouter(primary *gorm.DB, replicas ...*gorm.DB) *ReadRouter {
+	r := &ReadRouter{primary: primary, stop: make(chan struct{})}
+	for _, db := range replicas {
+		rep := &replica{}
+		rep.db.Store(db)
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+// ConnectReplicas crea el router de primary con una réplica por DSN. Una
+// réplica que no responde al arrancar queda en el router como caída: las
+// lecturas van a la primaria hasta que StartHealthChecks logra conectarla.
+func ConnectReplicas(primary *gorm.DB, dsns []string, pool PoolConfig) *ReadRouter {
+	r := NewReadRouter(primary)
+	for i, dsn := range dsns {
+		rep := &replica{dsn: dsn, pool: pool}
+		if db, err := Connect(dsn, pool); err != nil {
+			log.Printf("⚠️  Réplica %d no disponible, se reintenta en cada verificación: %v", i+1, err)
+		} else {
+			rep.db.Store(db)
+			rep.healthy.Store(true)
+		}
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+// Primary devuelve la conexión de escritura, también usada para lecturas que
+// deben ver lo recién escrito.
+func (r *ReadRouter) Primary() *gorm.DB {
+	return r.primary
+}
+
+// Read ejecuta fn en una réplica disponible. Si falla y la réplica no responde
+// al ping, se marca caída y fn se repite en la primaria; fn debe ser solo una
+// consulta, sin efectos fuera de la BD.
+func (r *ReadRouter) Read(fn func(db *gorm.DB) error) error {
+	rep := r.pick()
+	if rep == nil {
+		return fn(r.primary)
+	}
+
+	db := rep.db.Load()
+	err := fn(db)
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ping(db) == nil {
+		return err
+	}
+	if rep.healthy.CompareAndSwap(true, false) {
+		log.Printf("⚠️  Réplica de lectura caída, se usa la primaria: %v", err)
+	}
+	return fn(r.primary)
+}
+
+// Healthy devuelve cuántas réplicas están disponibles y cuántas hay.
+func (r *ReadRouter) Healthy() (healthy, total int) {
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy, len(r.replicas)
+}
+
+// StartHealthChecks verifica las réplicas cada interval y reincorpora las que
+// vuelven a responder, incluidas las que no se pudieron conectar al arrancar.
+func (r *ReadRouter) StartHealthChecks(interval time.Duration) {
+	if len(r.replicas) == 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.check()
+			}
+		}
+	}()
+}
+
+// Stop detiene las verificaciones periódicas.
+func (r *ReadRouter) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+func (r *ReadRouter) check() {
+	for i, rep := range r.replicas {
+		ok := rep.connect() == nil && ping(rep.db.Load()) == nil
+		if rep.healthy.Swap(ok) != ok {
+			if ok {
+				log.Printf("✅ Réplica %d disponible de nuevo", i+1)
+			} else {
+				log.Printf("⚠️  Réplica %d no responde, se usa la primaria", i+1)
+			}
+		}
+	}
+}
+
+// connect abre la réplica si aún no tiene conexión.
+func (rep *replica) connect() error {
+	if rep.db.Load() != nil {
+		return nil
+	}
+	db, err := Connect(rep.dsn, rep.pool)
+	if err != nil {
+		return err
+	}
+	rep.db.Store(db)
+	return nil
+}
+
+// pick devuelve la siguiente réplica disponible, o nil si no hay ninguna.
+func (r *ReadRouter) pick() *replica {
+	n := len(r.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+uint64(i))%uint64(n)]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+func ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"net/url"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Parámetros que se agregan al DSN de SQLite si no vienen en él: claves
+// foráneas activas (ON DELETE CASCADE), WAL para que las lecturas no bloqueen
+// la ingesta y espera ante una escritura concurrente en lugar de fallar.
+var sqliteDefaults = [][2]string{
+	{"_foreign_keys", "1"},
+	{"_journal_mode", "WAL"},
+	{"_busy_timeout", "5000"},
+}
+
+func init() {
+	Register("sqlite", openSQLite)
+	Register("file", openSQLite)
+}
+
+// openSQLite acepta sqlite://ruta (sqlite:///ruta/absoluta), sqlite:ruta y file:ruta.
+func openSQLite(dsn string) (gorm.Dialector, error) {
+	if rest, ok := strings.CutPrefix(dsn, "sqlite://"); ok {
+		dsn = "file:" + rest
+	} else if rest, ok := strings.CutPrefix(dsn, "sqlite:"); ok {
+		dsn = "file:" + rest
+	}
+
+	path, query, _ := strings.Cut(dsn, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range sqliteDefaults {
+		if !params.Has(kv[0]) {
+			params.Set(kv[0], kv[1])
+		}
+	}
+	return sqlite.Open(path + "?" + params.Encode()), nil
+}
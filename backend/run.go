@@ -7,15 +7,25 @@ import (
 )
 
 func main() {
-	log.Println("🚀 Running migrations and seed before starting server...")
+	log.Println("🚀 Running migrations before starting server...")
 
-	cmd := exec.Command("./seed")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Printf("⚠️ Error running seed: %v", err)
-	} else {
-		log.Println("✅ Seed completed successfully.")
+	migrate := exec.Command("./migrate", "up")
+	migrate.Stdout = os.Stdout
+	migrate.Stderr = os.Stderr
+	if err := migrate.Run(); err != nil {
+		log.Fatalf("❌ Migrations failed: %v", err)
+	}
+
+	// Demo users and devices only when explicitly requested
+	if os.Getenv("SEED_DEMO_DATA") == "true" {
+		cmd := exec.Command("./seed")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("⚠️ Error running seed: %v", err)
+		} else {
+			log.Println("✅ Seed completed successfully.")
+		}
 	}
 
 	log.Println("🌐 Starting fleet-backend...")
@@ -38,20 +38,6 @@ func firstOrCreateUser(db *gorm.DB, email, pass, role string) domain.User {
 	return u
 }
 
-// Migrar modelos principales
-func autoMigrate(db *gorm.DB) {
-	err := db.AutoMigrate(
-		&domain.User{},
-		&domain.Device{},
-		&domain.SensorData{},
-		&domain.Alert{},
-	)
-	if err != nil {
-		log.Fatalf("❌ Error al migrar modelos: %v", err)
-	}
-	log.Println("✅ Migraciones completadas correctamente")
-}
-
 // Crear varios dispositivos de ejemplo
 func seedDevices(db *gorm.DB, ownerID uint, count int) {
 	for i := 1; i <= count; i++ {
@@ -84,17 +70,15 @@ func main() {
 		os.Setenv("ADMIN_PASSWORD", "admin123")
 	}
 
+	// El esquema lo crea el comando migrate; appcore.New falla si está desactualizado
 	cfg := config.Load()
 	app := appcore.New(cfg)
 
-	// 1️⃣ Ejecutar migraciones
-	autoMigrate(app.DB)
-
-	// 2️⃣ Crear usuarios
+	// 1️⃣ Crear usuarios
 	admin := firstOrCreateUser(app.DB, os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD"), "admin")
 	user := firstOrCreateUser(app.DB, "user@example.com", "user123", "user")
 
-	// 3️⃣ Crear 5 dispositivos del admin
+	// 2️⃣ Crear 5 dispositivos del admin
 	seedDevices(app.DB, admin.ID, 5)
 
 	log.Printf("✅ Seed completo. Admin: %s | User: %s", admin.Email, user.Email)
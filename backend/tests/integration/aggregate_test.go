@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+func TestSensorAggregate_Buckets(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-AGG-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	items := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		items = append(items, fmt.Sprintf(`{"device_id":%d,"lat":%g,"lng":-74.1,"speed":%d,"fuel_level":%d,"temperature":%d,"ts":"%s"}`,
+			device.ID, 4.0+float64(i)/10, i*10, 100-i, 20+i, testTS(1000+i)))
+	}
+	w := postJSON(t, token, "/api/v1/protected/sensors/data/batch", "["+strings.Join(items, ",")+"]")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	path := fmt.Sprintf("/api/v1/protected/sensors/data/%d/aggregate", device.ID)
+	rng := url.Values{"from": {testTS(1000)}, "to": {testTS(1010)}}
+
+	var resp struct {
+		Buckets []service.AggregateBucket `json:"buckets"`
+	}
+	w = getJSON(t, token, path+"?interval=5m&"+rng.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Buckets, 2) {
+		first := resp.Buckets[0]
+		assert.True(t, testBaseTS.Add(1000*time.Minute).Equal(first.TS))
+		assert.Equal(t, int64(5), first.Count)
+		assert.Equal(t, service.MetricStats{Min: 0, Max: 40, Avg: 20, Last: 40}, first.Speed)
+		assert.Equal(t, service.MetricStats{Min: 96, Max: 100, Avg: 98, Last: 96}, first.FuelLevel)
+		assert.InDelta(t, 4.4, first.Lat, 1e-9)
+		assert.Equal(t, 90.0, resp.Buckets[1].Speed.Last)
+	}
+
+	w = getJSON(t, token, path+"?interval=1h&"+rng.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Buckets, 1) {
+		assert.Equal(t, int64(10), resp.Buckets[0].Count)
+		assert.Equal(t, 29.0, resp.Buckets[0].Temperature.Max)
+	}
+
+	// Rango sin lecturas
+	empty := url.Values{"from": {testTS(1100)}, "to": {testTS(1200)}}
+	w = getJSON(t, token, path+"?interval=1m&"+empty.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Buckets)
+
+	w = getJSON(t, token, path+"?interval=7m")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = getJSON(t, token, path+"?mode=raw")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Recorrido simplificado: conserva la primera y la última posición
+	var track struct {
+		Total  int                  `json:"total"`
+		Points []service.TrackPoint `json:"points"`
+	}
+	w = getJSON(t, token, path+"?mode=lttb&points=4&"+rng.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &track))
+	assert.Equal(t, 10, track.Total)
+	if assert.Len(t, track.Points, 4) {
+		assert.InDelta(t, 4.0, track.Points[0].Lat, 1e-9)
+		assert.InDelta(t, 4.9, track.Points[3].Lat, 1e-9)
+	}
+
+	w = getJSON(t, token, path+"?mode=lttb&points=2")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
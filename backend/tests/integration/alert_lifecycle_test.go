@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+type wsMessage struct {
+	Channel string         `json:"channel"`
+	Data    map[string]any `json:"data"`
+}
+
+// readAlertStatus lee del WebSocket hasta recibir el cambio de estado de la alerta.
+func readAlertStatus(t *testing.T, conn *websocket.Conn, alertID uint) map[string]any {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, raw, err := conn.ReadMessage()
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		var msg wsMessage
+		assert.NoError(t, json.Unmarshal(raw, &msg))
+		if msg.Channel == "alert_status" && msg.Data["id"] == float64(alertID) {
+			return msg.Data
+		}
+	}
+}
+
+func TestAlerts_Lifecycle(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-ALERT-LIFE", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	alert := domain.Alert{DeviceID: device.ID, TS: time.Now().UTC().Add(-time.Hour), Type: "test_life"}
+	assert.NoError(t, testApp.DB.Create(&alert).Error)
+
+	s := httptest.NewServer(testRouter)
+	defer s.Close()
+	u := url.URL{Scheme: "ws", Host: s.Listener.Addr().String(), Path: "/api/v1/ws"}
+	header := http.Header{}
+	header.Add("Authorization", "Bearer "+token)
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	assert.NoError(t, err)
+	defer conn.Close()
+	// El hub registra al cliente de forma asíncrona
+	time.Sleep(50 * time.Millisecond)
+
+	page := getAlerts(t, token, fmt.Sprintf("device_id=%d&status=open", device.ID))
+	assert.Equal(t, []uint{alert.ID}, alertIDs(page.Data))
+
+	w := postJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/%d/ack", alert.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var view service.AlertView
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Equal(t, domain.AlertAcknowledged, view.Status)
+	if assert.NotNil(t, view.AcknowledgedBy) {
+		assert.Equal(t, uint(1), *view.AcknowledgedBy)
+	}
+	assert.NotNil(t, view.AcknowledgedAt)
+	assert.Nil(t, view.ResolvedAt)
+
+	event := readAlertStatus(t, conn, alert.ID)
+	assert.Equal(t, "acknowledged", event["status"])
+
+	w = postJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/%d/resolve", alert.ID), `{"reason":"sensor reemplazado"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Equal(t, domain.AlertResolved, view.Status)
+	assert.Equal(t, "sensor reemplazado", view.ResolutionReason)
+	assert.NotNil(t, view.ResolvedAt)
+	assert.NotNil(t, view.AcknowledgedAt)
+
+	event = readAlertStatus(t, conn, alert.ID)
+	assert.Equal(t, "resolved", event["status"])
+	assert.Equal(t, "sensor reemplazado", event["resolution_reason"])
+
+	// Resuelta: no admite reconocerse y resolverla de nuevo no cambia el motivo
+	w = postJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/%d/ack", alert.ID), "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = postJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/%d/resolve", alert.ID), `{"reason":"otro"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Equal(t, "sensor reemplazado", view.ResolutionReason)
+
+	page = getAlerts(t, token, fmt.Sprintf("device_id=%d&status=resolved", device.ID))
+	assert.Equal(t, []uint{alert.ID}, alertIDs(page.Data))
+	page = getAlerts(t, token, fmt.Sprintf("device_id=%d&status=open", device.ID))
+	assert.Empty(t, page.Data)
+
+	w = getJSON(t, token, "/api/v1/protected/alerts/?status=closed")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Una alerta resuelta sin reconocer se resuelve sin motivo explícito con el
+// motivo por defecto, y el reconocimiento masivo la omite.
+func TestAlerts_ResolveWithoutAck(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-ALERT-LIFE-2", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	alert := domain.Alert{DeviceID: device.ID, TS: time.Now().UTC().Add(-time.Hour), Type: "test_life"}
+	assert.NoError(t, testApp.DB.Create(&alert).Error)
+
+	w := postJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/%d/resolve", alert.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var view service.AlertView
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Equal(t, domain.AlertResolved, view.Status)
+	assert.Equal(t, "resuelta manualmente", view.ResolutionReason)
+	assert.Nil(t, view.AcknowledgedBy)
+
+	w = postJSON(t, token, "/api/v1/protected/alerts/ack", fmt.Sprintf(`{"ids":[%d]}`, alert.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result service.BulkAckResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, int64(0), result.Updated)
+
+	var stored domain.Alert
+	assert.NoError(t, testApp.DB.First(&stored, alert.ID).Error)
+	assert.Equal(t, domain.AlertResolved, stored.Status)
+	assert.False(t, stored.Ack)
+}
+
+// La alerta de combustible abierta antes de un reinicio se resuelve cuando el
+// nivel se normaliza aunque el proceso no la haya creado.
+func TestAlerts_FuelResolvesAfterRestart(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-ALERT-LIFE-3", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	alert := domain.Alert{DeviceID: device.ID, TS: time.Now().UTC().Add(-time.Hour), Type: domain.AlertFuelLow}
+	assert.NoError(t, testApp.DB.Create(&alert).Error)
+
+	w := postJSON(t, token, "/api/v1/protected/sensors/data", fmt.Sprintf(
+		`{"device_id":%d,"lat":4.6,"lng":-74.1,"speed":40,"fuel_level":80,"ts":"%s"}`,
+		device.ID, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)))
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	var stored domain.Alert
+	assert.Eventually(t, func() bool {
+		return testApp.DB.First(&stored, alert.ID).Error == nil && stored.Status == domain.AlertResolved
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Contains(t, stored.ResolutionReason, "combustible normalizado")
+}
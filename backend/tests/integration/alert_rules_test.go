@@ -0,0 +1,206 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+func deleteJSON(t *testing.T, token, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+// waitAlerts espera a que el dispositivo tenga n alertas del tipo dado: las
+// reglas se evalúan en el worker de ingesta, después de responder.
+func waitAlerts(t *testing.T, deviceID uint, alertType domain.AlertType, n int) []domain.Alert {
+	var alerts []domain.Alert
+	for i := 0; i < 100; i++ {
+		alerts = nil
+		assert.NoError(t, testApp.DB.Where("device_id = ? AND type = ?", deviceID, alertType).Order("id").Find(&alerts).Error)
+		if len(alerts) >= n {
+			return alerts
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return alerts
+}
+
+func TestAlertRules_CRUDAndValidation(t *testing.T) {
+	adminToken := extractTokenFromLogin(t)
+	_, userToken := createUserToken(t, "rules@example.com")
+
+	w := getJSON(t, userToken, "/api/v1/protected/alerts/rules/")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = postJSON(t, adminToken, "/api/v1/protected/alerts/rules/",
+		`{"name":"","alert_type":"Fuel Low","metric":"rpm","operator":">","severity":"high","duration_seconds":-1,"device_ids":[999999]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, map[string]string{
+		"name":             "required",
+		"alert_type":       "invalid_format",
+		"metric":           "invalid_format",
+		"operator":         "invalid_format",
+		"threshold":        "required",
+		"duration_seconds": "out_of_range",
+		"severity":         "invalid_format",
+		"device_ids":       "unknown_device",
+	}, fieldCodes(t, w))
+
+	w = postJSON(t, adminToken, "/api/v1/protected/alerts/rules/",
+		`{"name":"Batería baja","alert_type":"low_battery","metric":"attributes.battery","operator":"lt","threshold":11.5,"severity":"warning"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var rule domain.AlertRule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.True(t, rule.Enabled)
+	assert.Empty(t, rule.DeviceIDs)
+
+	url := fmt.Sprintf("/api/v1/protected/alerts/rules/%d", rule.ID)
+	w = putJSON(t, adminToken, url,
+		`{"name":"Batería baja","alert_type":"low_battery","metric":"attributes.battery","operator":"lt","threshold":11,"severity":"critical","enabled":false}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.False(t, rule.Enabled)
+	assert.True(t, rule.AutoResolve)
+	assert.Equal(t, 11.0, rule.Threshold)
+
+	// Enabled y auto_resolve omitidos conservan el valor actual
+	w = putJSON(t, adminToken, url,
+		`{"name":"Batería baja","alert_type":"low_battery","metric":"attributes.battery","operator":"lt","threshold":11,"severity":"critical","auto_resolve":false}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.False(t, rule.Enabled)
+	assert.False(t, rule.AutoResolve)
+	w = putJSON(t, adminToken, url,
+		`{"name":"Batería baja","alert_type":"low_battery","metric":"attributes.battery","operator":"lt","threshold":10,"severity":"critical"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.False(t, rule.Enabled)
+	assert.False(t, rule.AutoResolve)
+
+	w = getJSON(t, adminToken, url)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = deleteJSON(t, adminToken, url)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = getJSON(t, adminToken, url)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = deleteJSON(t, adminToken, url)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAlertRules_TriggerOnIngest(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-RULE-1", OwnerID: 1}
+	other := domain.Device{ExternalID: "DEV-RULE-2", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	assert.NoError(t, testApp.DB.Create(&other).Error)
+
+	body := fmt.Sprintf(`{"name":"Exceso de velocidad","alert_type":"overspeed","metric":"speed","operator":"gt",
+		"threshold":100,"duration_seconds":60,"hysteresis":10,"device_ids":[%d],"severity":"critical"}`, device.ID)
+	w := postJSON(t, token, "/api/v1/protected/alerts/rules/", body)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var rule domain.AlertRule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	defer deleteJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/rules/%d", rule.ID))
+
+	base := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+	send := func(deviceID uint, offset time.Duration, speed int) {
+		w := postJSON(t, token, "/api/v1/protected/sensors/data", fmt.Sprintf(
+			`{"device_id":%d,"lat":4.6,"lng":-74.1,"speed":%d,"fuel_level":80,"ts":"%s"}`,
+			deviceID, speed, base.Add(offset).Format(time.RFC3339)))
+		assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	// Por encima del umbral pero menos de 60 s: aún no hay alerta
+	send(device.ID, 0, 120)
+	send(device.ID, 30*time.Second, 125)
+	send(other.ID, 0, 150)
+	send(other.ID, 90*time.Second, 150)
+	send(device.ID, 70*time.Second, 130)
+
+	alerts := waitAlerts(t, device.ID, "overspeed", 1)
+	if assert.Len(t, alerts, 1) {
+		var payload map[string]any
+		assert.NoError(t, json.Unmarshal(alerts[0].Payload, &payload))
+		assert.Equal(t, float64(rule.ID), payload["rule_id"])
+		assert.Equal(t, 130.0, payload["value"])
+		assert.Equal(t, "critical", payload["severity"])
+		assert.Equal(t, "DEV-RULE-1", payload["device_name"])
+		assert.Equal(t, domain.SeverityCritical, alerts[0].Severity)
+	}
+
+	// Sigue activa dentro de la histéresis; al normalizarse se resuelve y puede
+	// volver a disparar
+	send(device.ID, 80*time.Second, 95)
+	send(device.ID, 90*time.Second, 80)
+	send(device.ID, 100*time.Second, 120)
+	send(device.ID, 170*time.Second, 120)
+	alerts = waitAlerts(t, device.ID, "overspeed", 2)
+	if assert.Len(t, alerts, 2) {
+		// La primera se resolvió sola al normalizarse la velocidad
+		assert.Equal(t, domain.AlertResolved, alerts[0].Status)
+		assert.Contains(t, alerts[0].ResolutionReason, "speed normalizado")
+		assert.NotNil(t, alerts[0].ResolvedAt)
+		assert.Equal(t, domain.AlertOpen, alerts[1].Status)
+		if assert.NotNil(t, alerts[1].RuleID) {
+			assert.Equal(t, rule.ID, *alerts[1].RuleID)
+		}
+	}
+
+	// La regla solo aplica a su grupo de dispositivos
+	assert.Empty(t, waitAlerts(t, other.ID, "overspeed", 0))
+}
+
+// Al deshabilitar o eliminar una regla sus alertas pendientes se resuelven:
+// el motor ya no la evalúa y quedarían abiertas para siempre.
+func TestAlertRules_DisableAndDeleteResolveAlerts(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-RULE-CLOSE", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	body := `{"name":"Frío","alert_type":"cold_close","metric":"temperature","operator":"lt","threshold":0,"severity":"info"}`
+	createRule := func() domain.AlertRule {
+		w := postJSON(t, token, "/api/v1/protected/alerts/rules/", body)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var rule domain.AlertRule
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+		return rule
+	}
+	openAlert := func(ruleID uint, status domain.AlertStatus) domain.Alert {
+		alert := domain.Alert{DeviceID: device.ID, TS: time.Now().UTC(), Type: "cold_close", RuleID: &ruleID, Status: status}
+		assert.NoError(t, testApp.DB.Create(&alert).Error)
+		return alert
+	}
+	assertResolved := func(id uint, reason string) {
+		var stored domain.Alert
+		assert.NoError(t, testApp.DB.First(&stored, id).Error)
+		assert.Equal(t, domain.AlertResolved, stored.Status)
+		assert.Equal(t, reason, stored.ResolutionReason)
+		assert.NotNil(t, stored.ResolvedAt)
+	}
+
+	disabled := createRule()
+	alert := openAlert(disabled.ID, domain.AlertOpen)
+	url := fmt.Sprintf("/api/v1/protected/alerts/rules/%d", disabled.ID)
+	w := putJSON(t, token, url, body[:len(body)-1]+`,"enabled":false}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assertResolved(alert.ID, "regla deshabilitada")
+	deleteJSON(t, token, url)
+
+	deleted := createRule()
+	alert = openAlert(deleted.ID, domain.AlertAcknowledged)
+	w = deleteJSON(t, token, fmt.Sprintf("/api/v1/protected/alerts/rules/%d", deleted.ID))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assertResolved(alert.ID, "regla eliminada")
+}
@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+type alertPageResponse struct {
+	Data       []service.AlertView `json:"data"`
+	NextCursor string              `json:"next_cursor"`
+}
+
+func getAlerts(t *testing.T, token, query string) alertPageResponse {
+	w := getJSON(t, token, "/api/v1/protected/alerts/?"+query)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page alertPageResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func alertIDs(alerts []service.AlertView) []uint {
+	ids := make([]uint, 0, len(alerts))
+	for _, a := range alerts {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+func TestAlerts_ListFiltersAndVisibility(t *testing.T) {
+	adminToken := extractTokenFromLogin(t)
+	userID, userToken := createUserToken(t, "alerts@example.com")
+
+	owned := domain.Device{ExternalID: "DEV-ALERT-OWN", OwnerID: userID}
+	shared := domain.Device{ExternalID: "DEV-ALERT-SHARED", OwnerID: 1}
+	hidden := domain.Device{ExternalID: "DEV-ALERT-HIDDEN", OwnerID: 1}
+	for _, d := range []*domain.Device{&owned, &shared, &hidden} {
+		assert.NoError(t, testApp.DB.Create(d).Error)
+	}
+	assert.NoError(t, testApp.DB.Create(&domain.DeviceShare{DeviceID: shared.ID, UserID: userID}).Error)
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	alerts := []domain.Alert{
+		{DeviceID: owned.ID, TS: base, Type: "test_speed", Payload: []byte(`{"speed":120}`)},
+		{DeviceID: owned.ID, TS: base.Add(time.Minute), Type: "test_temp", Ack: true},
+		{DeviceID: shared.ID, TS: base.Add(2 * time.Minute), Type: "test_speed"},
+		{DeviceID: hidden.ID, TS: base.Add(3 * time.Minute), Type: "test_speed"},
+	}
+	assert.NoError(t, testApp.DB.Create(&alerts).Error)
+
+	// El usuario ve las de sus dispositivos y las compartidas, nunca las ajenas
+	page := getAlerts(t, userToken, "")
+	assert.Equal(t, []uint{alerts[2].ID, alerts[1].ID, alerts[0].ID}, alertIDs(page.Data))
+	assert.JSONEq(t, `{"speed":120}`, string(page.Data[2].Payload))
+
+	page = getAlerts(t, userToken, "type=test_speed&ack=false")
+	assert.Equal(t, []uint{alerts[2].ID, alerts[0].ID}, alertIDs(page.Data))
+
+	page = getAlerts(t, userToken, fmt.Sprintf("device_id=%d", owned.ID))
+	assert.Equal(t, []uint{alerts[1].ID, alerts[0].ID}, alertIDs(page.Data))
+
+	page = getAlerts(t, userToken, fmt.Sprintf("from=%s&to=%s",
+		base.Add(time.Minute).Format(time.RFC3339), base.Add(2*time.Minute).Format(time.RFC3339)))
+	assert.Equal(t, []uint{alerts[1].ID}, alertIDs(page.Data))
+
+	w := getJSON(t, userToken, fmt.Sprintf("/api/v1/protected/alerts/?device_id=%d", hidden.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = getJSON(t, userToken, "/api/v1/protected/alerts/?device_id=999999")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = getJSON(t, userToken, fmt.Sprintf("/api/v1/protected/alerts/%d", alerts[3].ID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, q := range []string{"ack=maybe", "limit=0", "from=ayer", "cursor=xyz"} {
+		w = getJSON(t, userToken, "/api/v1/protected/alerts/?"+q)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+
+	// El admin ve todas
+	page = getAlerts(t, adminToken, fmt.Sprintf("device_id=%d", hidden.ID))
+	assert.Equal(t, []uint{alerts[3].ID}, alertIDs(page.Data))
+}
+
+func TestAlerts_Pagination(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-ALERT-PAGE", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	// Dos alertas con el mismo ts: el cursor desempata por id
+	ts := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Second)
+	alerts := []domain.Alert{
+		{DeviceID: device.ID, TS: ts, Type: "test_page"},
+		{DeviceID: device.ID, TS: ts, Type: "test_page"},
+		{DeviceID: device.ID, TS: ts.Add(time.Minute), Type: "test_page"},
+	}
+	assert.NoError(t, testApp.DB.Create(&alerts).Error)
+
+	query := fmt.Sprintf("device_id=%d&limit=2", device.ID)
+	page := getAlerts(t, token, query)
+	assert.Equal(t, []uint{alerts[2].ID, alerts[1].ID}, alertIDs(page.Data))
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = getAlerts(t, token, query+"&cursor="+page.NextCursor)
+	assert.Equal(t, []uint{alerts[0].ID}, alertIDs(page.Data))
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestAlerts_Acknowledge(t *testing.T) {
+	userID, userToken := createUserToken(t, "alerts-ack@example.com")
+	owned := domain.Device{ExternalID: "DEV-ALERT-ACK", OwnerID: userID}
+	hidden := domain.Device{ExternalID: "DEV-ALERT-ACK-HIDDEN", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&owned).Error)
+	assert.NoError(t, testApp.DB.Create(&hidden).Error)
+
+	ts := time.Now().UTC().Add(-time.Hour)
+	alerts := []domain.Alert{
+		{DeviceID: owned.ID, TS: ts, Type: "test_ack"},
+		{DeviceID: owned.ID, TS: ts, Type: "test_ack"},
+		{DeviceID: owned.ID, TS: ts, Type: "test_ack"},
+		{DeviceID: hidden.ID, TS: ts, Type: "test_ack"},
+	}
+	assert.NoError(t, testApp.DB.Create(&alerts).Error)
+
+	w := postJSON(t, userToken, fmt.Sprintf("/api/v1/protected/alerts/%d/ack", alerts[0].ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var view service.AlertView
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.True(t, view.Ack)
+
+	// Reconocer de nuevo no es un error
+	w = postJSON(t, userToken, fmt.Sprintf("/api/v1/protected/alerts/%d/ack", alerts[0].ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postJSON(t, userToken, fmt.Sprintf("/api/v1/protected/alerts/%d/ack", alerts[3].ID), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	body := fmt.Sprintf(`{"ids":[%d,%d,%d,%d,999999]}`, alerts[0].ID, alerts[1].ID, alerts[2].ID, alerts[3].ID)
+	w = postJSON(t, userToken, "/api/v1/protected/alerts/ack", body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result service.BulkAckResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, []uint{alerts[0].ID, alerts[1].ID, alerts[2].ID}, result.Acknowledged)
+	assert.Equal(t, []uint{alerts[3].ID, 999999}, result.NotFound)
+	assert.Equal(t, int64(2), result.Updated)
+
+	var hiddenAlert domain.Alert
+	assert.NoError(t, testApp.DB.First(&hiddenAlert, alerts[3].ID).Error)
+	assert.False(t, hiddenAlert.Ack)
+
+	page := getAlerts(t, userToken, "type=test_ack&ack=false")
+	assert.Empty(t, page.Data)
+
+	w = postJSON(t, userToken, "/api/v1/protected/alerts/ack", `{"ids":[]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
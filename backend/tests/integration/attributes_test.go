@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+func putJSON(t *testing.T, token, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("PUT", path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+func getJSON(t *testing.T, token, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+// Atributos declarados por dispositivo: se validan al ingerir y se pueden filtrar
+func TestSensorIngest_ExtendedAttributes(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-ATTR-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	schemaPath := fmt.Sprintf("/api/v1/protected/devices/%d/attributes", device.ID)
+
+	w := putJSON(t, token, schemaPath, `{"attributes":[{"name":"RPM","type":"integer"},{"name":"odometer","type":"decimal"}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = putJSON(t, token, schemaPath, `{"attributes":[
+		{"name":"odometer","unit":"km","type":"number"},
+		{"name":"rpm","unit":"rpm","type":"integer"},
+		{"name":"battery_voltage","unit":"V","type":"number"},
+		{"name":"ignition","type":"boolean"}
+	]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = getJSON(t, token, schemaPath)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"unit":"km"`)
+
+	reading := func(minute int, attrs string) string {
+		return fmt.Sprintf(`{"device_id":%d,"lat":11.2,"lng":-74.1,"fuel_level":55,"ts":%q,"attributes":%s}`, device.ID, testTS(minute), attrs)
+	}
+	w = postJSON(t, token, "/api/v1/protected/sensors/data", reading(500, `{"odometer":1520.4,"rpm":2100,"ignition":true}`))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w = postJSON(t, token, "/api/v1/protected/sensors/data", reading(501, `{"odometer":1521.0,"rpm":0,"ignition":false}`))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// No declarado o de otro tipo
+	w = postJSON(t, token, "/api/v1/protected/sensors/data", reading(502, `{"door_open":true,"rpm":1500.5}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	codes := fieldCodes(t, w)
+	assert.Equal(t, "unknown_attribute", codes["attributes.door_open"])
+	assert.Equal(t, "invalid_type", codes["attributes.rpm"])
+
+	// Consultas en el historial, paginadas como el resto del historial
+	var page struct {
+		Data []struct {
+			Attributes map[string]any
+		}
+		NextCursor string `json:"next_cursor"`
+	}
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr[ignition]=true", device.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, 2100.0, page.Data[0].Attributes["rpm"])
+	}
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr_gte[odometer]=1520&limit=1", device.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.Len(t, page.Data, 1) && assert.NotEmpty(t, page.NextCursor) {
+		assert.Equal(t, 1521.0, page.Data[0].Attributes["odometer"])
+		w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr_gte[odometer]=1520&limit=1&cursor=%s", device.ID, page.NextCursor))
+		page.NextCursor = ""
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		if assert.Len(t, page.Data, 1) {
+			assert.Equal(t, 1520.4, page.Data[0].Attributes["odometer"])
+		}
+		assert.Empty(t, page.NextCursor)
+	}
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr_gte[odometer]=1520&from=%s", device.ID, testTS(501)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 1)
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr[rpm]=5000", device.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+
+	// Los filtros de atributos no saltan el control de acceso
+	_, otherToken := createUserToken(t, "attr-outsider@example.com")
+	w = getJSON(t, otherToken, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr[ignition]=true", device.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?attr_gte[ignition]=true", device.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+// createUserToken crea un usuario con rol user y devuelve su id y un JWT válido.
+func createUserToken(t *testing.T, email string) (uint, string) {
+	user := domain.User{Email: email, PasswordHash: "-", Role: domain.RoleUser}
+	assert.NoError(t, testApp.DB.Create(&user).Error)
+
+	token, err := utils.SignJWT([]byte(testApp.Config.JWTSecret), utils.JWTPayload{
+		Sub:   user.ID,
+		Role:  string(user.Role),
+		Email: user.Email,
+		Iat:   time.Now(),
+		Exp:   time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+	return user.ID, token
+}
+
+// Solo el dueño, los usuarios con acceso compartido y los admin pueden ingerir
+func TestSensorIngest_DeviceOwnership(t *testing.T) {
+	adminToken := extractTokenFromLogin(t)
+	ownerID, ownerToken := createUserToken(t, "owner@example.com")
+	otherID, otherToken := createUserToken(t, "other@example.com")
+
+	device := domain.Device{ExternalID: "DEV-OWNED-1", OwnerID: ownerID}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	reading := func(minute int) string {
+		return fmt.Sprintf(`{"device_id":%d,"lat":11.2,"lng":-74.1,"fuel_level":60,"ts":%q}`, device.ID, testTS(minute))
+	}
+
+	w := postJSON(t, ownerToken, "/api/v1/protected/sensors/data", reading(400))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = postJSON(t, otherToken, "/api/v1/protected/sensors/data", reading(401))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = postJSON(t, adminToken, "/api/v1/protected/sensors/data", reading(402))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Compartido, el otro usuario ya puede escribir
+	w = postJSON(t, ownerToken, fmt.Sprintf("/api/v1/protected/devices/%d/shares", device.ID), `{"email":"other@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = postJSON(t, otherToken, "/api/v1/protected/sensors/data", reading(403))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Un usuario con acceso compartido no puede volver a compartir
+	w = postJSON(t, otherToken, fmt.Sprintf("/api/v1/protected/devices/%d/shares", device.ID), `{"email":"admin@example.com"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = getJSON(t, otherToken, fmt.Sprintf("/api/v1/protected/alerts/?device_id=%d", device.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Al dejar de compartir, la ingesta y la API lo rechazan de inmediato
+	// aunque el acceso siguiera en caché
+	w = deleteJSON(t, ownerToken, fmt.Sprintf("/api/v1/protected/devices/%d/shares/%d", device.ID, otherID))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = postJSON(t, otherToken, "/api/v1/protected/sensors/data", reading(404))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = getJSON(t, otherToken, fmt.Sprintf("/api/v1/protected/alerts/?device_id=%d", device.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Dispositivos inexistentes o eliminados responden 404
+func TestSensorIngest_DeviceNotFound(t *testing.T) {
+	token := extractTokenFromLogin(t)
+
+	w := postJSON(t, token, "/api/v1/protected/sensors/data", fmt.Sprintf(`{"device_id":9999,"ts":%q}`, testTS(410)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	device := domain.Device{ExternalID: "DEV-DELETED-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	assert.NoError(t, testApp.DB.Delete(&device).Error)
+
+	w = postJSON(t, token, "/api/v1/protected/sensors/data", fmt.Sprintf(`{"device_id":%d,"ts":%q}`, device.ID, testTS(411)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// En un lote, el ítem se rechaza sin afectar al resto
+	w = postJSON(t, token, "/api/v1/protected/sensors/data/batch",
+		fmt.Sprintf(`[{"device_id":1,"ts":%q},{"device_id":9999,"ts":%q}]`, testTS(412), testTS(413)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"rejected":1`)
+	assert.Contains(t, w.Body.String(), "dispositivo no encontrado")
+}
@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+func issueDeviceKey(t *testing.T, token string, deviceID uint) (uint, string) {
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/protected/devices/%d/credentials", deviceID),
+		bytes.NewBufferString(`{"name":"tracker-principal"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var resp struct {
+		ID  uint   `json:"id"`
+		Key string `json:"key"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NotEmpty(t, resp.Key)
+	return resp.ID, resp.Key
+}
+
+func ingestWithKey(key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/api/v1/ingest/data", bytes.NewBufferString(body))
+	req.Header.Set("X-Device-Key", key)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+// Emitir, usar y revocar una API key de dispositivo
+func TestDeviceCredential_Lifecycle(t *testing.T) {
+	token := extractTokenFromLogin(t)
+
+	device := domain.Device{ExternalID: "DEV-KEY-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	credID, key := issueDeviceKey(t, token, device.ID)
+
+	// El device_id se toma de la credencial si se omite
+	w := ingestWithKey(key, fmt.Sprintf(`{"lat":11.2,"lng":-74.1,"fuel_level":70,"ts":%q}`, testTS(200)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Otro device_id con la misma credencial se rechaza
+	w = ingestWithKey(key, fmt.Sprintf(`{"device_id":%d,"lat":11.2,"ts":%q}`, device.ID+100, testTS(201)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// El listado no expone la clave ni su hash
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/protected/devices/%d/credentials", device.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	lw := httptest.NewRecorder()
+	testRouter.ServeHTTP(lw, req)
+	assert.Equal(t, http.StatusOK, lw.Code)
+	assert.Contains(t, lw.Body.String(), `"prefix"`)
+	assert.NotContains(t, lw.Body.String(), key)
+
+	// Revocada, deja de autenticar
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/protected/devices/%d/credentials/%d", device.ID, credID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rw := httptest.NewRecorder()
+	testRouter.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	w = ingestWithKey(key, fmt.Sprintf(`{"lat":11.2,"ts":%q}`, testTS(202)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// Sin API key o con una clave mal formada
+func TestDeviceCredential_InvalidKey(t *testing.T) {
+	w := ingestWithKey("", `{"lat":1,"ts":"2025-10-27T09:00:00Z"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = ingestWithKey("fdk_00000000_deadbeef", `{"lat":1,"ts":"2025-10-27T09:00:00Z"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+// waitExport consulta el trabajo hasta que deja de estar pendiente.
+func waitExport(t *testing.T, token string, id uint) domain.ExportJob {
+	var job domain.ExportJob
+	for i := 0; i < 100; i++ {
+		w := getJSON(t, token, fmt.Sprintf("/api/v1/protected/exports/%d", id))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		if job.Status == domain.ExportDone || job.Status == domain.ExportFailed {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("la exportación %d no terminó", id)
+	return job
+}
+
+func TestExport_CSVAndParquet(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-EXP-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	items := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		items = append(items, fmt.Sprintf(`{"device_id":%d,"lat":4.6,"lng":-74.1,"speed":%d,"fuel_level":50,"ts":"%s"}`, device.ID, 10*i, testTS(1400+i)))
+	}
+	w := postJSON(t, token, "/api/v1/protected/sensors/data/batch", "["+strings.Join(items, ",")+"]")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	body := fmt.Sprintf(`{"device_ids":[%d],"from":"%s","to":"%s","columns":["ts","speed"],"format":"csv"}`, device.ID, testTS(1400), testTS(1410))
+	w = postJSON(t, token, "/api/v1/protected/exports/", body)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var job domain.ExportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+
+	job = waitExport(t, token, job.ID)
+	assert.Equal(t, domain.ExportDone, job.Status, job.Error)
+	assert.Equal(t, int64(3), job.Rows)
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/exports/%d/download", job.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 4) {
+		assert.Equal(t, []string{"ts", "speed"}, records[0])
+		assert.Equal(t, "20", records[3][1])
+	}
+
+	body = fmt.Sprintf(`{"device_ids":[%d],"from":"%s","to":"%s","format":"parquet"}`, device.ID, testTS(1400), testTS(1410))
+	w = postJSON(t, token, "/api/v1/protected/exports/", body)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	job = waitExport(t, token, job.ID)
+	assert.Equal(t, domain.ExportDone, job.Status, job.Error)
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/exports/%d/download", job.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	file, err := parquet.OpenFile(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), file.NumRows())
+		_, ok := file.Schema().Lookup("fuel_level")
+		assert.True(t, ok)
+	}
+}
+
+func TestExport_ValidationAndOwnership(t *testing.T) {
+	adminToken := extractTokenFromLogin(t)
+	_, token := createUserToken(t, "exporter@example.com")
+	rng := fmt.Sprintf(`"from":"%s","to":"%s"`, testTS(1400), testTS(1410))
+
+	// Dispositivo 1 pertenece al admin
+	w := postJSON(t, token, "/api/v1/protected/exports/", `{"device_ids":[1],`+rng+`,"format":"csv"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = postJSON(t, token, "/api/v1/protected/exports/", `{"device_ids":[1],`+rng+`,"format":"xlsx","columns":["rpm"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	codes := fieldCodes(t, w)
+	assert.Equal(t, "invalid_format", codes["format"])
+	assert.Equal(t, "invalid_format", codes["columns"])
+
+	// El trabajo de otro usuario no existe para este
+	w = postJSON(t, adminToken, "/api/v1/protected/exports/", `{"device_ids":[1],`+rng+`,"format":"csv"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var job domain.ExportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	waitExport(t, adminToken, job.ID)
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/exports/%d", job.ID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/exports/%d/download", job.ID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = getJSON(t, token, "/api/v1/protected/exports/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"exports":[]}`, w.Body.String())
+}
@@ -48,3 +48,30 @@ func TestPredictiveFuelCheck_ShouldTriggerAlert(t *testing.T) {
 	assert.Less(t, autonomiaMin, 60.0, "Autonomía debe ser menor a 60 min")
 	t.Logf("🔍 Autonomía calculada: %.1f min", autonomiaMin)
 }
+
+// Un registro de rastreador sin combustible conserva el último nivel conocido
+// en lugar de guardar 0 y disparar la alerta.
+func TestIngestTracker_MissingFuelKeepsLastLevel(t *testing.T) {
+	device := domain.Device{ExternalID: "DEV-TRACKER-FUEL", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	svc := testApp.Sensors()
+
+	base := time.Now().UTC().Add(-30 * time.Minute).Truncate(time.Second)
+	fuel, temp := 80.0, -3.5
+	assert.NoError(t, svc.IngestTracker(device.ID, service.TrackerReading{TS: base, FuelLevel: &fuel, Temperature: &temp}))
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, svc.IngestTracker(device.ID, service.TrackerReading{TS: base.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	var stored []domain.SensorData
+	assert.NoError(t, testApp.DB.Where("device_id = ?", device.ID).Order("ts").Find(&stored).Error)
+	if assert.Len(t, stored, 4) {
+		for _, d := range stored {
+			assert.Equal(t, 80.0, d.FuelLevel)
+			assert.Equal(t, -3.5, d.Temperature)
+		}
+	}
+	var alerts int64
+	assert.NoError(t, testApp.DB.Model(&domain.Alert{}).Where("device_id = ?", device.ID).Count(&alerts).Error)
+	assert.Zero(t, alerts)
+}
@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+type historyResponse struct {
+	Data []struct {
+		TS time.Time
+	} `json:"data"`
+	Rollups []struct {
+		Hour  time.Time
+		Count int64
+	} `json:"rollups"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func getHistory(t *testing.T, token string, deviceID uint, query string) (int, historyResponse) {
+	var resp historyResponse
+	w := getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?%s", deviceID, query))
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w.Code, resp
+}
+
+func historyMinutes(resp historyResponse) []int {
+	minutes := make([]int, len(resp.Data))
+	for i, row := range resp.Data {
+		minutes[i] = int(row.TS.Sub(testBaseTS) / time.Minute)
+	}
+	return minutes
+}
+
+func TestSensorHistory_RangeAndCursor(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-HIST-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	items := make([]string, 0, 5)
+	for m := 800; m <= 804; m++ {
+		items = append(items, fmt.Sprintf(`{"device_id":%d,"lat":4.6,"lng":-74.1,"ts":"%s"}`, device.ID, testTS(m)))
+	}
+	w := postJSON(t, token, "/api/v1/protected/sensors/data/batch", "["+strings.Join(items, ",")+"]")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Páginas de la más reciente a la más antigua
+	code, page := getHistory(t, token, device.ID, "limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []int{804, 803}, historyMinutes(page))
+	assert.NotEmpty(t, page.NextCursor)
+
+	code, page = getHistory(t, token, device.ID, "limit=2&cursor="+page.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []int{802, 801}, historyMinutes(page))
+
+	code, page = getHistory(t, token, device.ID, "limit=2&cursor="+page.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []int{800}, historyMinutes(page))
+	assert.Empty(t, page.NextCursor)
+
+	// from inclusivo, to exclusivo
+	query := url.Values{"from": {testTS(801)}, "to": {testTS(803)}}
+	code, page = getHistory(t, token, device.ID, query.Encode())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []int{802, 801}, historyMinutes(page))
+
+	// Sin lecturas en el rango: lista vacía, no 404
+	query = url.Values{"from": {testTS(900)}}
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d?%s", device.ID, query.Encode()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+
+	for _, bad := range []string{"cursor=nope", "limit=0", "limit=5000", "from=ayer",
+		url.Values{"from": {testTS(803)}, "to": {testTS(801)}}.Encode()} {
+		code, _ = getHistory(t, token, device.ID, bad)
+		assert.Equal(t, http.StatusBadRequest, code, bad)
+	}
+}
+
+func TestSensorHistory_Access(t *testing.T) {
+	_, token := createUserToken(t, "history@example.com")
+
+	code, _ := getHistory(t, token, 1, "")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = getHistory(t, token, 999999, "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
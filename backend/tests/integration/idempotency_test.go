@@ -0,0 +1,130 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+type ingestResponse struct {
+	Status    string `json:"status"`
+	ID        uint   `json:"id"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+func postReading(t *testing.T, token, body, idempotencyKey string) (int, ingestResponse) {
+	req, _ := http.NewRequest("POST", "/api/v1/protected/sensors/data", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+
+	var resp ingestResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// El mismo (device_id, ts) reenviado no crea otra fila
+func TestSensorIngest_DuplicateReading(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	ts := testTS(100)
+	body := fmt.Sprintf(`{"device_id":1,"lat":11.24,"lng":-74.12,"fuel_level":70,"ts":%q}`, ts)
+
+	code, first := postReading(t, token, body, "")
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.False(t, first.Duplicate)
+
+	code, second := postReading(t, token, body, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, second.Duplicate)
+	assert.Equal(t, first.ID, second.ID)
+
+	var count int64
+	testApp.DB.Model(&domain.SensorData{}).Where("device_id = ? AND ts = ?", 1, testBaseTS.Add(100*time.Minute)).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+// Con Idempotency-Key, un reintento con otro ts devuelve la lectura original
+func TestSensorIngest_IdempotencyKey(t *testing.T) {
+	token := extractTokenFromLogin(t)
+
+	code, first := postReading(t, token, fmt.Sprintf(`{"device_id":1,"fuel_level":65,"ts":%q}`, testTS(110)), "retry-abc-1")
+	assert.Equal(t, http.StatusAccepted, code)
+
+	code, second := postReading(t, token, fmt.Sprintf(`{"device_id":1,"fuel_level":65,"ts":%q}`, testTS(111)), "retry-abc-1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, second.Duplicate)
+	assert.Equal(t, first.ID, second.ID)
+}
+
+// En un lote, los duplicados se reportan por ítem
+func TestSensorIngestBatch_Duplicates(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	payload := []map[string]interface{}{
+		{"device_id": 1, "fuel_level": 60, "ts": testTS(120)},
+		{"device_id": 1, "fuel_level": 60, "ts": testTS(120)},
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/v1/protected/sensors/data/batch", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		Accepted   int `json:"accepted"`
+		Duplicates int `json:"duplicates"`
+		Results    []struct {
+			Status string `json:"status"`
+			ID     uint   `json:"id"`
+		} `json:"results"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, 1, resp.Duplicates)
+	if assert.Len(t, resp.Results, 2) {
+		assert.Equal(t, "accepted", resp.Results[0].Status)
+		assert.Equal(t, "duplicate", resp.Results[1].Status)
+		assert.Equal(t, resp.Results[0].ID, resp.Results[1].ID)
+	}
+}
+
+// Las claves más antiguas que el TTL se borran en lotes; las recientes se conservan
+func TestIdempotencyKeys_PurgeExpired(t *testing.T) {
+	device := domain.Device{ExternalID: "DEV-IDEM-TTL", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	now := time.Now().UTC()
+	keys := []domain.IdempotencyKey{
+		{DeviceID: device.ID, Key: "old-1", SensorDataID: 1, CreatedAt: now.Add(-100 * time.Hour)},
+		{DeviceID: device.ID, Key: "old-2", SensorDataID: 2, CreatedAt: now.Add(-90 * time.Hour)},
+		{DeviceID: device.ID, Key: "old-3", SensorDataID: 3, CreatedAt: now.Add(-80 * time.Hour)},
+		{DeviceID: device.ID, Key: "new-1", SensorDataID: 4, CreatedAt: now.Add(-time.Hour)},
+	}
+	assert.NoError(t, testApp.DB.Create(&keys).Error)
+
+	job := service.NewRetentionService(repository.NewRollupRepository(testApp.DB), service.RetentionConfig{BatchSize: 2})
+	job.SetIdempotencyKeys(repository.NewIdempotencyKeyRepository(testApp.DB), 72*time.Hour)
+	_, err := job.RunOnce(now)
+	assert.NoError(t, err)
+
+	var left []domain.IdempotencyKey
+	assert.NoError(t, testApp.DB.Where("device_id = ?", device.ID).Find(&left).Error)
+	if assert.Len(t, left, 1) {
+		assert.Equal(t, "new-1", left[0].Key)
+	}
+}
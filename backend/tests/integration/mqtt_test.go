@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/mqttbridge"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+// Requiere un broker local, p. ej.:
+//
+//	docker compose -f docker-compose.db.yml up mosquitto
+//	MQTT_TEST_BROKER=tcp://localhost:1883 go test ./tests/integration -run MQTT
+func TestMQTTBridge_IngestsFromBroker(t *testing.T) {
+	broker := os.Getenv("MQTT_TEST_BROKER")
+	if broker == "" {
+		t.Skip("MQTT_TEST_BROKER no definido; se omite la prueba contra broker")
+	}
+
+	device := domain.Device{ExternalID: "DEV-MQTT-IT", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	sub, err := mqttbridge.New(mqttbridge.Config{
+		BrokerURL: broker,
+		ClientID:  "fleet-backend-test",
+		Topic:     "fleet-test/{external_id}/telemetry",
+		QoS:       1,
+	}, repository.NewDeviceRepository(testApp.DB), testApp.Sensors(), utils.NewLogger("test"))
+	assert.NoError(t, err)
+	sub.Start()
+	defer sub.Stop()
+
+	pub := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("fleet-publisher-test"))
+	token := pub.Connect()
+	assert.True(t, token.WaitTimeout(5*time.Second))
+	assert.NoError(t, token.Error())
+	defer pub.Disconnect(250)
+
+	// Dar tiempo a que el suscriptor se conecte y se suscriba
+	time.Sleep(500 * time.Millisecond)
+
+	token = pub.Publish("fleet-test/DEV-MQTT-IT/telemetry", 1, false,
+		`{"lat":11.24,"lng":-74.12,"speed":30,"fuel_level":64,"temperature":22,"ts":"2025-10-26T16:00:00Z"}`)
+	assert.True(t, token.WaitTimeout(5*time.Second))
+
+	assert.Eventually(t, func() bool {
+		var count int64
+		testApp.DB.Model(&domain.SensorData{}).Where("device_id = ?", device.ID).Count(&count)
+		return count == 1
+	}, 5*time.Second, 100*time.Millisecond)
+}
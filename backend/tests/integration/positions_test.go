@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+type positionsResponse struct {
+	StaleAfterSeconds int64                    `json:"stale_after_seconds"`
+	Positions         []service.DevicePosition `json:"positions"`
+}
+
+func getPositions(t *testing.T, token string) positionsResponse {
+	w := getJSON(t, token, "/api/v1/protected/devices/positions")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp positionsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func findPosition(resp positionsResponse, deviceID uint) *service.DevicePosition {
+	for i := range resp.Positions {
+		if resp.Positions[i].DeviceID == deviceID {
+			return &resp.Positions[i]
+		}
+	}
+	return nil
+}
+
+func TestDevicePositions_CacheAndVisibility(t *testing.T) {
+	adminToken := extractTokenFromLogin(t)
+	userID, userToken := createUserToken(t, "positions@example.com")
+
+	owned := domain.Device{ExternalID: "DEV-POS-OWN", OwnerID: userID}
+	shared := domain.Device{ExternalID: "DEV-POS-SHARED", OwnerID: 1}
+	hidden := domain.Device{ExternalID: "DEV-POS-HIDDEN", OwnerID: 1}
+	for _, d := range []*domain.Device{&owned, &shared, &hidden} {
+		assert.NoError(t, testApp.DB.Create(d).Error)
+	}
+	assert.NoError(t, testApp.DB.Create(&domain.DeviceShare{DeviceID: shared.ID, UserID: userID}).Error)
+
+	// Lecturas que guarda otro proceso, como el gateway: llegan a la caché
+	// cuando vence el intervalo de refresco desde la BD
+	sensors := testApp.Sensors()
+	sensors.SetPositionRefresh(time.Hour)
+	defer sensors.SetPositionRefresh(service.DefaultPositionRefresh)
+	_, err := sensors.WarmPositions()
+	assert.NoError(t, err)
+
+	old := testBaseTS.Add(1436 * time.Minute)
+	assert.NoError(t, testApp.DB.Create(&[]domain.SensorData{
+		{DeviceID: shared.ID, TS: old.Add(-time.Minute), Lat: 1, Lng: 1},
+		{DeviceID: shared.ID, TS: old, Lat: 4.7, Lng: -74.05, Speed: 30, FuelLevel: 55},
+	}).Error)
+	assert.Nil(t, findPosition(getPositions(t, userToken), shared.ID).TS)
+
+	sensors.SetPositionRefresh(time.Nanosecond)
+
+	// Una lectura ingerida actualiza la caché sin consultar la BD
+	fresh := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	body := fmt.Sprintf(`{"device_id":%d,"lat":4.6,"lng":-74.1,"speed":12,"fuel_level":80,"temperature":20,"ts":"%s"}`, owned.ID, fresh.Format(time.RFC3339))
+	w := postJSON(t, userToken, "/api/v1/protected/sensors/data", body)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	resp := getPositions(t, userToken)
+	assert.Equal(t, int64(600), resp.StaleAfterSeconds)
+	assert.Len(t, resp.Positions, 2)
+	assert.Nil(t, findPosition(resp, hidden.ID))
+
+	pos := findPosition(resp, owned.ID)
+	if assert.NotNil(t, pos) && assert.NotNil(t, pos.TS) {
+		assert.True(t, fresh.Equal(*pos.TS))
+		assert.Equal(t, 80.0, pos.FuelLevel)
+		assert.False(t, pos.Stale)
+		assert.Less(t, *pos.AgeSeconds, int64(600))
+	}
+
+	pos = findPosition(resp, shared.ID)
+	if assert.NotNil(t, pos) && assert.NotNil(t, pos.TS) {
+		assert.True(t, old.Equal(*pos.TS))
+		assert.Equal(t, 4.7, pos.Lat)
+		assert.Equal(t, 30.0, pos.Speed)
+		assert.True(t, pos.Stale)
+	}
+
+	// Un backfill más antiguo no reemplaza la última posición
+	w = postJSON(t, userToken, "/api/v1/protected/sensors/data",
+		fmt.Sprintf(`{"device_id":%d,"lat":1,"lng":1,"ts":"%s"}`, owned.ID, fresh.Add(-time.Hour).Format(time.RFC3339)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	pos = findPosition(getPositions(t, userToken), owned.ID)
+	assert.Equal(t, 4.6, pos.Lat)
+
+	// El admin ve toda la flota; sin lecturas la posición queda vacía y desactualizada
+	pos = findPosition(getPositions(t, adminToken), hidden.ID)
+	if assert.NotNil(t, pos) {
+		assert.Nil(t, pos.TS)
+		assert.Nil(t, pos.AgeSeconds)
+		assert.True(t, pos.Stale)
+	}
+}
@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/telemetrypb"
+	"google.golang.org/protobuf/proto"
+)
+
+func postProtobuf(t *testing.T, token, path string, body []byte) (*httptest.ResponseRecorder, *telemetrypb.IngestResponse) {
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", telemetrypb.ContentType)
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, telemetrypb.ContentType, w.Header().Get("Content-Type"))
+	resp := &telemetrypb.IngestResponse{}
+	assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), resp))
+	return w, resp
+}
+
+func tsMillis(minute int) int64 {
+	return testBaseTS.Add(time.Duration(minute) * time.Minute).UnixMilli()
+}
+
+// Lectura individual en protobuf, con respuesta en protobuf
+func TestSensorIngest_Protobuf(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	reading, err := proto.Marshal(&telemetrypb.Reading{DeviceId: 1, TsUnixMs: tsMillis(600), Lat: 11.24, Lng: -74.12, Speed: 40, FuelLevel: 66.6})
+	assert.NoError(t, err)
+
+	w, resp := postProtobuf(t, token, "/api/v1/protected/sensors/data", reading)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "data received", resp.Status)
+	assert.NotZero(t, resp.Id)
+
+	w, resp = postProtobuf(t, token, "/api/v1/protected/sensors/data", reading)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, resp.Duplicate)
+
+	// Los errores de validación también vuelven en protobuf
+	bad, err := proto.Marshal(&telemetrypb.Reading{DeviceId: 1, TsUnixMs: tsMillis(601), FuelLevel: 250})
+	assert.NoError(t, err)
+	w, resp = postProtobuf(t, token, "/api/v1/protected/sensors/data", bad)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "fuel_level", resp.Errors[0].Field)
+		assert.Equal(t, "out_of_range", resp.Errors[0].Code)
+	}
+
+	w, resp = postProtobuf(t, token, "/api/v1/protected/sensors/data", []byte{0xff, 0xff})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "protobuf inválido", resp.Error)
+}
+
+// Lote en protobuf con resultado por ítem
+func TestSensorIngestBatch_Protobuf(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	body, err := proto.Marshal(&telemetrypb.ReadingBatch{Readings: []*telemetrypb.Reading{
+		{DeviceId: 1, TsUnixMs: tsMillis(610), FuelLevel: 60},
+		{DeviceId: 1, TsUnixMs: tsMillis(611), Lat: 120},
+		{DeviceId: 1, TsUnixMs: tsMillis(612), FuelLevel: 59},
+	}})
+	assert.NoError(t, err)
+
+	w, resp := postProtobuf(t, token, "/api/v1/protected/sensors/data/batch", body)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, uint32(2), resp.Accepted)
+	assert.Equal(t, uint32(1), resp.Rejected)
+	if assert.Len(t, resp.Results, 3) {
+		assert.Equal(t, uint32(1), resp.Results[1].Index)
+		assert.Equal(t, "rejected", resp.Results[1].Status)
+		assert.Equal(t, "accepted", resp.Results[2].Status)
+	}
+}
+
+func TestTelemetrySchema_Published(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/schemas/telemetry.proto", nil)
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "message ReadingBatch")
+}
@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/api"
+	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+// newRateLimitedRouter arma una App sobre la misma BD con los límites dados.
+// Las tasas son tan bajas que el bucket no se recarga durante la prueba.
+func newRateLimitedRouter(userBurst, deviceBurst int) *gin.Engine {
+	cfg := *testApp.Config
+	cfg.RateLimitUserRPS, cfg.RateLimitUserBurst = 0, 0
+	cfg.RateLimitDeviceRPS, cfg.RateLimitDeviceBurst = 0, 0
+	if userBurst > 0 {
+		cfg.RateLimitUserRPS, cfg.RateLimitUserBurst = 0.001, userBurst
+	}
+	if deviceBurst > 0 {
+		cfg.RateLimitDeviceRPS, cfg.RateLimitDeviceBurst = 0.001, deviceBurst
+	}
+	return api.SetupRouter(&appcore.App{DB: testApp.DB, Config: &cfg, Hub: testApp.Hub})
+}
+
+func send(router *gin.Engine, token, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIngest_DeviceRateLimit(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-RL-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	router := newRateLimitedRouter(0, 2)
+
+	reading := func(minute int) string {
+		return fmt.Sprintf(`{"device_id":%d,"lat":4.6,"lng":-74.1,"speed":10,"fuel_level":50,"temperature":20,"ts":"%s"}`, device.ID, testTS(minute))
+	}
+
+	w := send(router, token, "POST", "/api/v1/protected/sensors/data", reading(700))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data", reading(701))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data", reading(702))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Un lote de un dispositivo ya agotado se rechaza entero
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data/batch", "["+reading(703)+"]")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	var count int64
+	testApp.DB.Model(&domain.SensorData{}).Where("device_id = ?", device.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	w = send(router, token, "GET", "/health", "")
+	var health struct {
+		RateLimitRejected struct {
+			Device uint64 `json:"device"`
+		} `json:"rate_limit_rejected"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, uint64(2), health.RateLimitRejected.Device)
+}
+
+// El límite guardado en el dispositivo reemplaza al de la configuración
+func TestIngest_DeviceRateLimitOverride(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-RL-2", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+	router := newRateLimitedRouter(0, 100)
+
+	path := fmt.Sprintf("/api/v1/protected/devices/%d/rate-limit", device.ID)
+	w := send(router, token, "PUT", path, `{"rps":0.001,"burst":1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = send(router, token, "PUT", "/api/v1/protected/devices/999999/rate-limit", `{"rps":1}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(router, token, "PUT", path, `{"rps":-1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	batch := fmt.Sprintf(`[{"device_id":%d,"lat":4.6,"lng":-74.1,"ts":"%s"},{"device_id":%d,"lat":4.6,"lng":-74.1,"ts":"%s"}]`,
+		device.ID, testTS(710), device.ID, testTS(711))
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data/batch", batch)
+	assert.Equal(t, http.StatusAccepted, w.Code, "un lote consume un solo token por dispositivo")
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+
+	reading := fmt.Sprintf(`{"device_id":%d,"lat":4.6,"lng":-74.1,"ts":"%s"}`, device.ID, testTS(712))
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data", reading)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Sin límite propio vuelve al de la configuración
+	w = send(router, token, "PUT", path, `{"rps":null}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data", reading)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestIngest_UserRateLimit(t *testing.T) {
+	_, token := createUserToken(t, "ratelimited@example.com")
+	router := newRateLimitedRouter(1, 0)
+
+	w := send(router, token, "POST", "/api/v1/protected/sensors/data", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+
+	w = send(router, token, "POST", "/api/v1/protected/sensors/data", `{}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Las consultas no consumen el límite de ingesta
+	w = send(router, token, "GET", "/api/v1/protected/sensors/data/1", "")
+	assert.NotEqual(t, http.StatusTooManyRequests, w.Code)
+}
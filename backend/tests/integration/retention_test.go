@@ -0,0 +1,132 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+func TestRetention_RollsUpAndPurges(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-RET-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	// Dos horas vencidas: se insertan directo porque la API rechaza ts tan antiguos
+	hour := time.Now().UTC().AddDate(0, 0, -40).Truncate(time.Hour)
+	old := []domain.SensorData{
+		{DeviceID: device.ID, TS: hour, Lat: 4.00, Lng: -74, Speed: 10, FuelLevel: 80, Temperature: 20},
+		{DeviceID: device.ID, TS: hour.Add(10 * time.Minute), Lat: 4.01, Lng: -74, Speed: 20, FuelLevel: 78, Temperature: 22},
+		{DeviceID: device.ID, TS: hour.Add(20 * time.Minute), Lat: 4.02, Lng: -74, Speed: 30, FuelLevel: 76, Temperature: 24},
+		{DeviceID: device.ID, TS: hour.Add(65 * time.Minute), Lat: 4.10, Lng: -74, Speed: 5, FuelLevel: 70, Temperature: 18},
+		{DeviceID: device.ID, TS: hour.Add(75 * time.Minute), Lat: 4.10, Lng: -74, Speed: 0, FuelLevel: 68, Temperature: 18},
+	}
+	assert.NoError(t, testApp.DB.Create(&old).Error)
+	assert.NoError(t, testApp.DB.Create(&domain.IdempotencyKey{DeviceID: device.ID, Key: "ret-1", SensorDataID: old[0].ID}).Error)
+
+	w := postJSON(t, token, "/api/v1/protected/sensors/data",
+		fmt.Sprintf(`{"device_id":%d,"lat":4.2,"lng":-74,"fuel_level":60,"ts":"%s"}`, device.ID, testTS(1300)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Lotes de 2 lecturas: la primera hora queda repartida entre lotes
+	job := service.NewRetentionService(repository.NewRollupRepository(testApp.DB), service.RetentionConfig{Days: 30, BatchSize: 2})
+	deleted, err := job.RunOnce(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+
+	var raw, keys int64
+	testApp.DB.Model(&domain.SensorData{}).Where("device_id = ?", device.ID).Count(&raw)
+	assert.Equal(t, int64(1), raw)
+	testApp.DB.Model(&domain.IdempotencyKey{}).Where("device_id = ?", device.ID).Count(&keys)
+	assert.Zero(t, keys)
+
+	var rollups []domain.SensorRollup
+	assert.NoError(t, testApp.DB.Where("device_id = ?", device.ID).Order("hour").Find(&rollups).Error)
+	if assert.Len(t, rollups, 2) {
+		first := rollups[0]
+		assert.True(t, hour.Equal(first.Hour))
+		assert.Equal(t, int64(3), first.Count)
+		assert.Equal(t, 76.0, first.FuelMin)
+		assert.Equal(t, 80.0, first.FuelMax)
+		assert.InDelta(t, 78.0, first.FuelAvg, 1e-9)
+		assert.InDelta(t, 22.0, first.TemperatureAvg, 1e-9)
+		assert.Equal(t, 30.0, first.SpeedMax)
+		assert.InDelta(t, 2.224, first.DistanceKM, 0.01)
+		assert.Equal(t, 4.02, first.LastLat)
+		assert.Equal(t, int64(2), rollups[1].Count)
+	}
+
+	deleted, err = job.RunOnce(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	// El historial sigue con los resúmenes tras la lectura cruda
+	code, page := getHistory(t, token, device.ID, "limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Data, 1)
+	if assert.Len(t, page.Rollups, 1) {
+		assert.True(t, hour.Add(time.Hour).Equal(page.Rollups[0].Hour))
+		assert.Equal(t, int64(2), page.Rollups[0].Count)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	code, page = getHistory(t, token, device.ID, "limit=2&cursor="+page.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, page.Data)
+	if assert.Len(t, page.Rollups, 1) {
+		assert.True(t, hour.Equal(page.Rollups[0].Hour))
+	}
+	assert.Empty(t, page.NextCursor)
+
+	// Agregación y recorrido cubren las horas ya borradas
+	rng := url.Values{"from": {hour.Format(time.RFC3339)}, "to": {hour.Add(2 * time.Hour).Format(time.RFC3339)}}
+	path := fmt.Sprintf("/api/v1/protected/sensors/data/%d/aggregate?", device.ID)
+	var agg struct {
+		Buckets []service.AggregateBucket `json:"buckets"`
+	}
+	w = getJSON(t, token, path+"interval=1d&"+rng.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &agg))
+	if assert.Len(t, agg.Buckets, 1) {
+		b := agg.Buckets[0]
+		assert.Equal(t, int64(5), b.Count)
+		assert.Equal(t, 68.0, b.FuelLevel.Min)
+		assert.Equal(t, 80.0, b.FuelLevel.Max)
+		assert.InDelta(t, 74.4, b.FuelLevel.Avg, 1e-9)
+		assert.Equal(t, 4.10, b.Lat)
+	}
+
+	var track struct {
+		Total  int                  `json:"total"`
+		Points []service.TrackPoint `json:"points"`
+	}
+	w = getJSON(t, token, path+"mode=lttb&"+rng.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &track))
+	assert.Equal(t, 5, track.Total)
+	if assert.Len(t, track.Points, 2) {
+		assert.Equal(t, 4.02, track.Points[0].Lat)
+	}
+
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d/track?format=geojson&%s", device.ID, rng.Encode()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "4.02")
+
+	body := fmt.Sprintf(`{"device_ids":[%d],"from":"%s","to":"%s","columns":["ts","fuel_level"],"format":"csv"}`,
+		device.ID, rng.Get("from"), rng.Get("to"))
+	w = postJSON(t, token, "/api/v1/protected/exports/", body)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var export domain.ExportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	export = waitExport(t, token, export.ID)
+	assert.Equal(t, domain.ExportDone, export.Status, export.Error)
+	assert.Equal(t, int64(2), export.Rows)
+}
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -19,7 +20,7 @@ func TestSensorIngest_Valid(t *testing.T) {
 		"speed":       42.5,
 		"fuel_level":  70.0,
 		"temperature": 25.0,
-		"ts":          "2025-10-26T15:00:00Z",
+		"ts":          testTS(0),
 	}
 	body, _ := json.Marshal(payload)
 	req, _ := http.NewRequest("POST", "/api/v1/protected/sensors/data", bytes.NewBuffer(body))
@@ -63,3 +64,86 @@ func TestSensorIngest_InvalidToken(t *testing.T) {
 	testRouter.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+// Lote con lecturas válidas e inválidas: estado por ítem
+func TestSensorIngestBatch_PerItemResults(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	payload := []map[string]interface{}{
+		{"device_id": 1, "lat": 11.24, "lng": -74.12, "fuel_level": 70.0, "ts": testTS(1)},
+		{"lat": 11.25},
+		{"device_id": 1, "lat": 11.26, "lng": -74.13, "fuel_level": 69.5, "ts": testTS(2)},
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/v1/protected/sensors/data/batch", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		Accepted int `json:"accepted"`
+		Rejected int `json:"rejected"`
+		Results  []struct {
+			Index  int    `json:"index"`
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 2, resp.Accepted)
+	assert.Equal(t, 1, resp.Rejected)
+	assert.Len(t, resp.Results, 3)
+	assert.Equal(t, "accepted", resp.Results[0].Status)
+	assert.Equal(t, "rejected", resp.Results[1].Status)
+	assert.Equal(t, "accepted", resp.Results[2].Status)
+}
+
+// El cuerpo debe ser un arreglo
+func TestSensorIngestBatch_NotAnArray(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	req, _ := http.NewRequest("POST", "/api/v1/protected/sensors/data/batch", bytes.NewBufferString(`{"device_id":1}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// NDJSON comprimido con gzip: resumen de ingeridas, omitidas y fallidas
+func TestSensorIngestStream_GzipBackfill(t *testing.T) {
+	token := extractTokenFromLogin(t)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"device_id":1,"lat":11.1,"lng":-74.1,"fuel_level":80,"ts":"2024-01-01T00:00:00Z"}` + "\n"))
+	gz.Write([]byte("esto no es json\n"))
+	gz.Write([]byte("\n"))
+	gz.Write([]byte(`{"device_id":1,"lat":11.2,"lng":-74.2,"fuel_level":79,"ts":"2024-01-01T00:01:00Z"}`))
+	gz.Close()
+
+	req, _ := http.NewRequest("POST", "/api/v1/protected/sensors/data/stream", &buf)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var summary struct {
+		Mode     string `json:"mode"`
+		Ingested int    `json:"ingested"`
+		Skipped  int    `json:"skipped"`
+		Failed   int    `json:"failed"`
+		Errors   []struct {
+			Line int `json:"line"`
+		} `json:"errors"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &summary)
+	assert.Equal(t, "backfill", summary.Mode)
+	assert.Equal(t, 2, summary.Ingested)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, 0, summary.Failed)
+	if assert.Len(t, summary.Errors, 1) {
+		assert.Equal(t, 2, summary.Errors[0].Line)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
@@ -13,6 +14,7 @@ import (
 	"github.com/nleea/fleet-monitoring/backend/internal/appcore"
 	"github.com/nleea/fleet-monitoring/backend/internal/config"
 	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/migrations"
 	"github.com/nleea/fleet-monitoring/backend/internal/utils"
 	"github.com/nleea/fleet-monitoring/backend/internal/ws"
 )
@@ -20,17 +22,44 @@ import (
 var testApp *appcore.App
 var testRouter *gin.Engine
 
+// Base de los ts de prueba: reciente para pasar la validación de antigüedad.
+var testBaseTS = time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Hour)
+
+// testTS devuelve un ts a minute minutos de la base, en RFC3339. Cada prueba
+// usa su propio rango para no chocar con la unicidad de (device_id, ts).
+func testTS(minute int) string {
+	return testBaseTS.Add(time.Duration(minute) * time.Minute).Format(time.RFC3339)
+}
+
 func TestMain(m *testing.M) {
 	// DB en memoria para pruebas
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("❌ Error al crear DB de prueba: %v", err)
 	}
+	// Cada conexión a :memory: abre una base vacía distinta; con una sola
+	// conexión los jobs en segundo plano ven las mismas tablas que los requests.
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("❌ Error al crear DB de prueba: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
 
-	_ = db.AutoMigrate(&domain.Device{}, &domain.SensorData{}, &domain.Alert{}, &domain.User{})
+	if _, err := migrations.NewDefault(db).Up(0); err != nil {
+		log.Fatalf("❌ Error migrando DB de prueba: %v", err)
+	}
 
 	// Config para JWT y entorno
 	cfg := config.Load()
+	// Sin límites de ingesta: las pruebas envían ráfagas al mismo dispositivo.
+	// rate_limit_test.go arma su propia App con límites.
+	cfg.RateLimitUserRPS = 0
+	cfg.RateLimitDeviceRPS = 0
+	exportDir, err := os.MkdirTemp("", "fleet-exports-")
+	if err != nil {
+		log.Fatalf("❌ Error creando directorio de exportaciones: %v", err)
+	}
+	cfg.ExportDir = exportDir
 
 	// Inicializa Hub WS y App
 	hub := ws.NewHub()
@@ -54,6 +83,12 @@ func TestMain(m *testing.M) {
 		log.Fatalf("❌ Error creando usuario de prueba: %v", err)
 	}
 
+	// Dispositivo 1, usado por las pruebas de ingesta
+	if err := db.Create(&domain.Device{ExternalID: "DEV-TEST-1", OwnerID: user.ID}).Error; err != nil {
+		log.Fatalf("❌ Error creando dispositivo de prueba: %v", err)
+	}
+
 	code := m.Run()
+	os.RemoveAll(exportDir)
 	os.Exit(code)
 }
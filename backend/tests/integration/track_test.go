@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+)
+
+func TestTrack_Formats(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	device := domain.Device{ExternalID: "DEV-TRACK-1", OwnerID: 1}
+	assert.NoError(t, testApp.DB.Create(&device).Error)
+
+	items := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		items = append(items, fmt.Sprintf(`{"device_id":%d,"lat":4.6%d,"lng":-74.1,"speed":%d,"fuel_level":40,"temperature":21.5,"ts":"%s"}`, device.ID, i, 10*i, testTS(1420+i)))
+	}
+	w := postJSON(t, token, "/api/v1/protected/sensors/data/batch", "["+strings.Join(items, ",")+"]")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	base := fmt.Sprintf("/api/v1/protected/sensors/data/%d/track?from=%s&to=%s", device.ID, testTS(1420), testTS(1425))
+
+	w = getJSON(t, token, base+"&format=gpx")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gpx+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "DEV-TRACK-1-")
+	var gpx struct {
+		Name   string `xml:"trk>name"`
+		Points []struct {
+			Lat  float64 `xml:"lat,attr"`
+			Lon  float64 `xml:"lon,attr"`
+			Time string  `xml:"time"`
+		} `xml:"trk>trkseg>trkpt"`
+	}
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &gpx))
+	assert.Equal(t, "DEV-TRACK-1", gpx.Name)
+	if assert.Len(t, gpx.Points, 3) {
+		assert.Equal(t, 4.62, gpx.Points[2].Lat)
+		assert.Equal(t, -74.1, gpx.Points[2].Lon)
+		assert.Equal(t, testTS(1420), gpx.Points[0].Time)
+	}
+
+	w = getJSON(t, token, base+"&format=kml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var kml struct {
+		Placemarks []struct {
+			Begin       string `xml:"TimeSpan>begin"`
+			End         string `xml:"TimeSpan>end"`
+			Coordinates string `xml:"LineString>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &kml))
+	if assert.Len(t, kml.Placemarks, 1) {
+		assert.Equal(t, testTS(1420), kml.Placemarks[0].Begin)
+		assert.Equal(t, testTS(1422), kml.Placemarks[0].End)
+		assert.Len(t, strings.Fields(kml.Placemarks[0].Coordinates), 3)
+	}
+
+	w = getJSON(t, token, base+"&format=geojson")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fc))
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	if assert.Len(t, fc.Features, 3) {
+		assert.Equal(t, []float64{-74.1, 4.61}, fc.Features[1].Geometry.Coordinates)
+		assert.Equal(t, 10.0, fc.Features[1].Properties["speed"])
+		assert.Equal(t, 40.0, fc.Features[1].Properties["fuel_level"])
+		assert.Equal(t, 21.5, fc.Features[1].Properties["temperature"])
+	}
+
+	// Un rango sin lecturas sigue siendo un documento válido
+	w = getJSON(t, token, fmt.Sprintf("/api/v1/protected/sensors/data/%d/track?format=geojson&from=%s&to=%s", device.ID, testTS(1430), testTS(1435)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fc))
+	assert.Empty(t, fc.Features)
+}
+
+func TestTrack_Errors(t *testing.T) {
+	_, token := createUserToken(t, "track@example.com")
+
+	w := getJSON(t, token, "/api/v1/protected/sensors/data/1/track?format=shp")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = getJSON(t, token, "/api/v1/protected/sensors/data/1/track?format=gpx")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = getJSON(t, token, "/api/v1/protected/sensors/data/999999/track?format=kml")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
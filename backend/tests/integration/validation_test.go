@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fieldErrorsResponse struct {
+	Error  string `json:"error"`
+	Errors []struct {
+		Field string `json:"field"`
+		Code  string `json:"code"`
+	} `json:"errors"`
+}
+
+func postJSON(t *testing.T, token, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+func fieldCodes(t *testing.T, w *httptest.ResponseRecorder) map[string]string {
+	var resp fieldErrorsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	codes := make(map[string]string, len(resp.Errors))
+	for _, e := range resp.Errors {
+		codes[e.Field] = e.Code
+	}
+	return codes
+}
+
+// Valores fuera de rango se rechazan con 422 y un error por campo
+func TestSensorIngest_OutOfRange(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	body := fmt.Sprintf(`{"device_id":1,"lat":95,"lng":-74.1,"fuel_level":350,"speed":-3,"ts":%q}`, testTS(300))
+
+	w := postJSON(t, token, "/api/v1/protected/sensors/data", body)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	codes := fieldCodes(t, w)
+	assert.Equal(t, "out_of_range", codes["lat"])
+	assert.Equal(t, "out_of_range", codes["fuel_level"])
+	assert.Equal(t, "out_of_range", codes["speed"])
+	assert.NotContains(t, codes, "lng")
+}
+
+// ts en el futuro o demasiado antiguo
+func TestSensorIngest_InvalidTimestamp(t *testing.T) {
+	token := extractTokenFromLogin(t)
+
+	w := postJSON(t, token, "/api/v1/protected/sensors/data", `{"device_id":1,"ts":"2099-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "in_future", fieldCodes(t, w)["ts"])
+
+	w = postJSON(t, token, "/api/v1/protected/sensors/data", `{"device_id":1,"ts":"2001-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "too_old", fieldCodes(t, w)["ts"])
+
+	w = postJSON(t, token, "/api/v1/protected/sensors/data", `{"device_id":1,"ts":"ayer"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_format", fieldCodes(t, w)["ts"])
+}
+
+// En un lote, los errores de validación se reportan por ítem
+func TestSensorIngestBatch_FieldErrors(t *testing.T) {
+	token := extractTokenFromLogin(t)
+	body := fmt.Sprintf(`[{"device_id":1,"fuel_level":50,"ts":%q},{"device_id":1,"temperature":900,"ts":%q}]`,
+		testTS(320), testTS(321))
+
+	w := postJSON(t, token, "/api/v1/protected/sensors/data/batch", body)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		Accepted int `json:"accepted"`
+		Rejected int `json:"rejected"`
+		Results  []struct {
+			Status string `json:"status"`
+			Errors []struct {
+				Field string `json:"field"`
+				Code  string `json:"code"`
+			} `json:"errors"`
+		} `json:"results"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, 1, resp.Rejected)
+	if assert.Len(t, resp.Results, 2) && assert.Len(t, resp.Results[1].Errors, 1) {
+		assert.Equal(t, "rejected", resp.Results[1].Status)
+		assert.Equal(t, "temperature", resp.Results[1].Errors[0].Field)
+		assert.Equal(t, "out_of_range", resp.Results[1].Errors[0].Code)
+	}
+}
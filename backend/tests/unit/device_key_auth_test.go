@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/middleware"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+func deviceKeyStatus(t *testing.T, db *gorm.DB, key string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.DeviceKeyAuth(service.NewDeviceCredentialService(
+		repository.NewDeviceCredentialRepository(db),
+		repository.NewDeviceRepository(db),
+	)))
+	r.POST("/data", func(c *gin.Context) { c.Status(http.StatusAccepted) })
+
+	req, _ := http.NewRequest("POST", "/data", nil)
+	req.Header.Set("X-Device-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// Solo una clave desconocida es 401; si la BD falla el dispositivo recibe un
+// 5xx y reintenta en vez de darse por revocado.
+func TestDeviceKeyAuth_DatabaseErrorIsNotUnauthorized(t *testing.T) {
+	key, _, err := utils.GenerateAPIKey()
+	assert.NoError(t, err)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, deviceKeyStatus(t, db, key))
+
+	assert.NoError(t, db.AutoMigrate(&domain.DeviceCredential{}))
+	assert.Equal(t, http.StatusUnauthorized, deviceKeyStatus(t, db, key))
+}
@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+// Las exportaciones terminadas hace más de EXPORT_TTL pierden su archivo y
+// pasan a expired; las recientes y las fallidas no cambian.
+func TestExportService_Expire(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&domain.User{}, &domain.Device{}, &domain.ExportJob{}))
+	store, err := export.NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	job := func(name string, status domain.ExportStatus, finished time.Time) domain.ExportJob {
+		if name != "" {
+			_, err := store.Save(name, func(w io.Writer) error {
+				_, err := io.WriteString(w, "ts,speed\n")
+				return err
+			})
+			assert.NoError(t, err)
+		}
+		j := domain.ExportJob{UserID: 1, DeviceIDs: []uint{1}, From: now.Add(-time.Hour), To: now, Columns: []string{"ts"},
+			Format: export.FormatCSV, Status: status, FileName: name, FinishedAt: &finished}
+		assert.NoError(t, db.Create(&j).Error)
+		return j
+	}
+	old := job("export-old.csv", domain.ExportDone, now.Add(-8*24*time.Hour))
+	recent := job("export-recent.csv", domain.ExportDone, now.Add(-time.Hour))
+	failed := job("", domain.ExportFailed, now.Add(-30*24*time.Hour))
+
+	repo := repository.NewExportRepository(db)
+	svc := service.NewExportService(repo, repository.NewSensorRepository(db), service.NewDeviceAccess(repository.NewDeviceRepository(db)), store)
+	svc.SetTTL(7 * 24 * time.Hour)
+
+	n, err := svc.Expire(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	stored, err := repo.GetByID(old.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ExportExpired, stored.Status)
+	assert.Empty(t, stored.FileName)
+	_, err = os.Stat(store.Path("export-old.csv"))
+	assert.True(t, os.IsNotExist(err))
+	_, _, err = svc.File(service.Principal{UserID: 1}, old.ID)
+	assert.ErrorIs(t, err, service.ErrExportExpired)
+
+	stored, err = repo.GetByID(recent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ExportDone, stored.Status)
+	_, err = os.Stat(store.Path("export-recent.csv"))
+	assert.NoError(t, err)
+
+	stored, err = repo.GetByID(failed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ExportFailed, stored.Status)
+
+	// Un segundo barrido no encuentra nada más
+	n, err = svc.Expire(now)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
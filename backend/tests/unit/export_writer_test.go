@@ -0,0 +1,42 @@
+package unit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+)
+
+// El escritor Parquet vuelca cada row group al llenarse en lugar de guardar
+// todo el archivo en memoria hasta Close.
+func TestParquetWriter_FlushesRowGroups(t *testing.T) {
+	cols, err := export.SelectColumns([]string{"ts", "speed"})
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := export.NewWriter(export.FormatParquet, &buf, cols)
+	assert.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	chunk := make([]domain.SensorData, 10_000)
+	total := 2*export.ParquetRowGroupRows + len(chunk)
+	for written := 0; written < total; written += len(chunk) {
+		for i := range chunk {
+			chunk[i] = domain.SensorData{TS: base.Add(time.Duration(written+i) * time.Second), Speed: float64(i % 120)}
+		}
+		assert.NoError(t, w.Write(chunk))
+	}
+	// Antes de cerrar ya se escribieron los row groups completos
+	assert.Greater(t, buf.Len(), 100_000)
+
+	assert.NoError(t, w.Close())
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(total), file.NumRows())
+		assert.Len(t, file.RowGroups(), 3)
+	}
+}
@@ -3,11 +3,14 @@ package unit
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/nleea/fleet-monitoring/backend/internal/domain"
 	"github.com/nleea/fleet-monitoring/backend/internal/repository"
 	"github.com/nleea/fleet-monitoring/backend/internal/service"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 type FailingSensorRepo struct{}
@@ -15,7 +18,34 @@ type FailingSensorRepo struct{}
 func (f *FailingSensorRepo) GetRecentByDevice(deviceID uint, limit int) ([]domain.SensorData, error) {
 	return nil, errors.New("db failure")
 }
-func (f *FailingSensorRepo) Create(data *domain.SensorData) error { return nil }
+func (f *FailingSensorRepo) GetRecentByDevicePrimary(deviceID uint, limit int) ([]domain.SensorData, error) {
+	return nil, errors.New("db failure")
+}
+func (f *FailingSensorRepo) Create(data *domain.SensorData, idempotencyKey string) (bool, error) {
+	return true, nil
+}
+func (f *FailingSensorRepo) CreateBatch(data []domain.SensorData) ([]bool, error) {
+	return make([]bool, len(data)), nil
+}
+func (f *FailingSensorRepo) CreateInBatches(data []domain.SensorData, batchSize int) (int64, error) {
+	return int64(len(data)), nil
+}
+func (f *FailingSensorRepo) GetRange(deviceID uint, q repository.RangeQuery) ([]domain.SensorData, error) {
+	return nil, errors.New("db failure")
+}
+func (f *FailingSensorRepo) Aggregate(deviceID uint, from, to time.Time, interval time.Duration) ([]repository.AggregateRow, error) {
+	return nil, errors.New("db failure")
+}
+func (f *FailingSensorRepo) GetTrack(deviceID uint, from, to time.Time, limit int) ([]domain.SensorData, error) {
+	return nil, errors.New("db failure")
+}
+func (f *FailingSensorRepo) ForEachChunk(deviceID uint, from, to time.Time, size int, fn func([]domain.SensorData) error) error {
+	return errors.New("db failure")
+}
+
+func (f *FailingSensorRepo) LatestForDevices(deviceIDs []uint) ([]domain.SensorData, error) {
+	return nil, errors.New("db failure")
+}
 
 func TestPredictiveFuelCheck_DBError(t *testing.T) {
 	svc := service.NewSensorService(&FailingSensorRepo{}, repository.NewAlertRepository(nil), nil, repository.NewDeviceRepository(nil))
@@ -25,3 +55,16 @@ func TestPredictiveFuelCheck_DBError(t *testing.T) {
 	assert.False(t, trigger)
 	assert.Equal(t, 0.0, autonomy)
 }
+
+// Un fallo de la BD al buscar el dispositivo no es un error de validación: la
+// lectura debe reintentarse, no descartarse.
+func TestIngest_DeviceLookupDBError(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	svc := service.NewSensorService(&FailingSensorRepo{}, repository.NewAlertRepository(db), nil, repository.NewDeviceRepository(db))
+
+	_, err = svc.Ingest(&domain.SensorData{DeviceID: 1, TS: time.Now()}, "")
+	assert.Error(t, err)
+	_, invalid := service.AsValidationError(err)
+	assert.False(t, invalid)
+}
@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+)
+
+func newIdempotencyDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&domain.SensorData{}, &domain.IdempotencyKey{}))
+	return db
+}
+
+// Una clave cuya lectura ya purgó la retención no bloquea la ingesta: se
+// guarda la lectura nueva y la clave pasa a apuntar a ella.
+func TestSensorRepository_DanglingIdempotencyKey(t *testing.T) {
+	db := newIdempotencyDB(t)
+	assert.NoError(t, db.Create(&domain.IdempotencyKey{DeviceID: 1, Key: "k-1", SensorDataID: 999}).Error)
+
+	repo := repository.NewSensorRepository(db)
+	data := domain.SensorData{DeviceID: 1, TS: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	created, err := repo.Create(&data, "k-1")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	var key domain.IdempotencyKey
+	assert.NoError(t, db.Where("device_id = ? AND key = ?", 1, "k-1").First(&key).Error)
+	assert.Equal(t, data.ID, key.SensorDataID)
+}
+
+// Si otra petición con la misma clave guarda la suya mientras tanto, se
+// devuelve esa lectura como duplicado y la nuestra no queda guardada.
+func TestSensorRepository_IdempotencyKeyRace(t *testing.T) {
+	db := newIdempotencyDB(t)
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Simula la petición concurrente justo antes de insertar nuestra clave
+	var winner domain.SensorData
+	raced := false
+	assert.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:race", func(tx *gorm.DB) {
+		if raced || tx.Statement.Table != "idempotency_keys" {
+			return
+		}
+		raced = true
+		other := tx.Session(&gorm.Session{NewDB: true})
+		winner = domain.SensorData{DeviceID: 1, TS: ts.Add(time.Minute)}
+		assert.NoError(t, other.Create(&winner).Error)
+		assert.NoError(t, other.Create(&domain.IdempotencyKey{DeviceID: 1, Key: "k-1", SensorDataID: winner.ID}).Error)
+	}))
+
+	repo := repository.NewSensorRepository(db)
+	data := domain.SensorData{DeviceID: 1, TS: ts}
+	created, err := repo.Create(&data, "k-1")
+	assert.NoError(t, err)
+	assert.True(t, raced)
+	assert.False(t, created)
+	assert.Equal(t, winner.ID, data.ID)
+
+	var count int64
+	db.Model(&domain.SensorData{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
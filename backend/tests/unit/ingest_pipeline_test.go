@@ -0,0 +1,189 @@
+package unit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+// newAlertRepo guarda las alertas en una base en memoria de una sola conexión.
+func newAlertRepo(t *testing.T) repository.AlertRepository {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	assert.NoError(t, db.AutoMigrate(&domain.Alert{}))
+	return repository.NewAlertRepository(db)
+}
+
+// blockingSensorRepo retiene cada Create hasta que se cierra release.
+type blockingSensorRepo struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu      sync.Mutex
+	order   []time.Time
+	batches []int
+}
+
+func (r *blockingSensorRepo) Create(data *domain.SensorData, idempotencyKey string) (bool, error) {
+	r.started <- struct{}{}
+	<-r.release
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = append(r.order, data.TS)
+	data.ID = uint(len(r.order))
+	return true, nil
+}
+func (r *blockingSensorRepo) CreateBatch(data []domain.SensorData) ([]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, len(data))
+	created := make([]bool, len(data))
+	for i := range created {
+		created[i] = true
+		data[i].ID = data[i].DeviceID
+	}
+	return created, nil
+}
+func (r *blockingSensorRepo) CreateInBatches(data []domain.SensorData, batchSize int) (int64, error) {
+	return int64(len(data)), nil
+}
+func (r *blockingSensorRepo) GetRecentByDevice(deviceID uint, limit int) ([]domain.SensorData, error) {
+	return nil, nil
+}
+func (r *blockingSensorRepo) GetRecentByDevicePrimary(deviceID uint, limit int) ([]domain.SensorData, error) {
+	return nil, nil
+}
+func (r *blockingSensorRepo) GetRange(deviceID uint, q repository.RangeQuery) ([]domain.SensorData, error) {
+	return nil, nil
+}
+func (r *blockingSensorRepo) Aggregate(deviceID uint, from, to time.Time, interval time.Duration) ([]repository.AggregateRow, error) {
+	return nil, nil
+}
+func (r *blockingSensorRepo) GetTrack(deviceID uint, from, to time.Time, limit int) ([]domain.SensorData, error) {
+	return nil, nil
+}
+func (r *blockingSensorRepo) ForEachChunk(deviceID uint, from, to time.Time, size int, fn func([]domain.SensorData) error) error {
+	return nil
+}
+
+func (r *blockingSensorRepo) LatestForDevices(deviceIDs []uint) ([]domain.SensorData, error) {
+	return nil, nil
+}
+
+func TestIngestPipeline_QueueFull(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	_ = db.AutoMigrate(&domain.Device{})
+	device := domain.Device{ExternalID: "DEV-QUEUE", OwnerID: 1}
+	assert.NoError(t, db.Create(&device).Error)
+
+	repo := &blockingSensorRepo{started: make(chan struct{}, 4), release: make(chan struct{})}
+	svc := service.NewSensorService(repo, newAlertRepo(t), nil, repository.NewDeviceRepository(db))
+	svc.StartPipeline(service.PipelineConfig{Workers: 1, QueueSize: 1})
+	defer svc.StopPipeline()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	reading := func(offset time.Duration) *domain.SensorData {
+		return &domain.SensorData{DeviceID: device.ID, FuelLevel: 50, TS: now.Add(offset)}
+	}
+
+	var wg sync.WaitGroup
+	results := make([]service.IngestResult, 2)
+	errs := make([]error, 2)
+
+	// La primera lectura ocupa al worker; la segunda queda en cola
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], errs[0] = svc.Ingest(reading(-2*time.Second), "")
+	}()
+	<-repo.started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[1], errs[1] = svc.Ingest(reading(-time.Second), "")
+	}()
+	assert.Eventually(t, func() bool { return svc.PipelineStats().Depth == 1 }, time.Second, 5*time.Millisecond)
+
+	// Con la cola llena se rechaza de inmediato
+	_, err = svc.Ingest(reading(0), "")
+	assert.ErrorIs(t, err, service.ErrQueueFull)
+	assert.Equal(t, uint64(1), svc.PipelineStats().Rejected)
+
+	close(repo.release)
+	wg.Wait()
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Equal(t, uint(1), results[0].ID)
+	assert.Equal(t, uint(2), results[1].ID)
+	assert.Equal(t, []time.Time{now.Add(-2 * time.Second), now.Add(-time.Second)}, repo.order)
+
+	stats := svc.PipelineStats()
+	assert.Equal(t, int64(0), stats.Depth)
+	assert.Equal(t, uint64(2), stats.Processed)
+}
+
+// Un lote con lecturas de varios dispositivos se reparte entre las
+// particiones de cada dispositivo y los resultados vuelven en orden.
+func TestIngestPipeline_BatchSplitsByShard(t *testing.T) {
+	repo := &blockingSensorRepo{}
+	svc := service.NewSensorService(repo, newAlertRepo(t), nil, repository.NewDeviceRepository(nil))
+	svc.StartPipeline(service.PipelineConfig{Workers: 2, QueueSize: 100})
+	defer svc.StopPipeline()
+
+	now := time.Now().UTC()
+	var readings []domain.SensorData
+	for id := uint(1); id <= 6; id++ {
+		readings = append(readings, domain.SensorData{DeviceID: id, TS: now})
+	}
+	results, err := svc.IngestBatch(readings)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 6) {
+		for i, r := range results {
+			assert.Equal(t, uint(i+1), r.ID)
+		}
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	assert.Equal(t, []int{3, 3}, repo.batches)
+}
+
+// Detener el pipeline con ingestas en curso no pierde lecturas: las que llegan
+// con la cola cerrada se guardan de forma síncrona.
+func TestIngestPipeline_StopWhileIngesting(t *testing.T) {
+	repo := &blockingSensorRepo{}
+	svc := service.NewSensorService(repo, newAlertRepo(t), nil, repository.NewDeviceRepository(nil))
+	svc.StartPipeline(service.PipelineConfig{Workers: 2, QueueSize: 1000})
+
+	now := time.Now().UTC()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := svc.IngestBatch([]domain.SensorData{
+				{DeviceID: uint(i), TS: now}, {DeviceID: uint(i), TS: now.Add(time.Second)},
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	svc.StopPipeline()
+	wg.Wait()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	assert.Len(t, repo.batches, 20)
+}
@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+// fullTrackRepo simula un rango con más lecturas de las que se piden.
+type fullTrackRepo struct {
+	FailingSensorRepo
+}
+
+func (r *fullTrackRepo) GetTrack(deviceID uint, from, to time.Time, limit int) ([]domain.SensorData, error) {
+	return make([]domain.SensorData, limit), nil
+}
+
+func TestLTTB_KeepsEndpointsAndPeaks(t *testing.T) {
+	// Recorrido recto hacia el este con un desvío en la lectura 5
+	data := make([]domain.SensorData, 11)
+	for i := range data {
+		data[i] = domain.SensorData{ID: uint(i), Lng: float64(i)}
+	}
+	data[5].Lat = 3
+
+	sampled := service.LTTB(data, 3)
+	if assert.Len(t, sampled, 3) {
+		assert.Equal(t, uint(0), sampled[0].ID)
+		assert.Equal(t, uint(5), sampled[1].ID)
+		assert.Equal(t, uint(10), sampled[2].ID)
+	}
+
+	sampled = service.LTTB(data, 6)
+	assert.Len(t, sampled, 6)
+	assert.Contains(t, sampled, data[5])
+}
+
+func TestLTTB_NoReduction(t *testing.T) {
+	data := make([]domain.SensorData, 4)
+	assert.Len(t, service.LTTB(data, 10), 4)
+	assert.Len(t, service.LTTB(data, 2), 4)
+	assert.Empty(t, service.LTTB(nil, 100))
+}
+
+// Un rango que supera el máximo de lecturas se rechaza en lugar de simplificar
+// solo su parte inicial.
+func TestTrack_RangeTooLarge(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	_ = db.AutoMigrate(&domain.Device{})
+	device := domain.Device{ExternalID: "DEV-TRACK-CAP", OwnerID: 1}
+	assert.NoError(t, db.Create(&device).Error)
+
+	svc := service.NewSensorService(&fullTrackRepo{}, repository.NewAlertRepository(db), nil, repository.NewDeviceRepository(db))
+	to := time.Now()
+	_, _, err = svc.Track(service.Principal{UserID: 1, Role: "admin"}, device.ID, to.Add(-time.Hour), to, 100)
+	assert.ErrorIs(t, err, service.ErrRangeTooLarge)
+}
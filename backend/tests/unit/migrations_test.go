@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/migrations"
+)
+
+func createTable(name string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec("CREATE TABLE " + name + " (id INTEGER PRIMARY KEY)").Error
+	}
+}
+
+func dropTable(name string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec("DROP TABLE " + name).Error
+	}
+}
+
+func testMigrations() []migrations.Migration {
+	// Desordenadas a propósito: New las ordena por versión
+	return []migrations.Migration{
+		{Version: 2, Name: "create_b", Up: createTable("b"), Down: dropTable("b")},
+		{Version: 1, Name: "create_a", Up: createTable("a"), Down: dropTable("a")},
+		{Version: 3, Name: "create_c", Up: createTable("c"), Down: dropTable("c")},
+	}
+}
+
+func newMigrationDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func TestMigrator_UpDownAndCheck(t *testing.T) {
+	db := newMigrationDB(t)
+	m, err := migrations.New(db, testMigrations())
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, m.Check(), migrations.ErrSchemaBehind)
+
+	applied, err := m.Up(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, applied)
+	assert.True(t, db.Migrator().HasTable("b"))
+	assert.False(t, db.Migrator().HasTable("c"))
+	assert.ErrorIs(t, m.Check(), migrations.ErrSchemaBehind)
+
+	applied, err = m.Up(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, applied)
+	assert.NoError(t, m.Check())
+
+	// Sin pendientes, Up no hace nada
+	applied, err = m.Up(0)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	status, err := m.Status()
+	assert.NoError(t, err)
+	if assert.Len(t, status, 3) {
+		assert.Equal(t, "create_a", status[0].Name)
+		assert.NotNil(t, status[2].AppliedAt)
+	}
+
+	reverted, err := m.Down(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, reverted)
+	assert.False(t, db.Migrator().HasTable("b"))
+	assert.True(t, db.Migrator().HasTable("a"))
+
+	pending, err := m.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
+
+// Una migración que falla no queda registrada y detiene las siguientes.
+func TestMigrator_FailedMigrationRollsBack(t *testing.T) {
+	db := newMigrationDB(t)
+	list := testMigrations()
+	list[0].Up = func(tx *gorm.DB) error {
+		if err := createTable("b")(tx); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}
+	m, err := migrations.New(db, list)
+	assert.NoError(t, err)
+
+	applied, err := m.Up(0)
+	assert.Error(t, err)
+	assert.Equal(t, []int{1}, applied)
+	assert.False(t, db.Migrator().HasTable("b"))
+	assert.False(t, db.Migrator().HasTable("c"))
+
+	pending, err := m.Pending()
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, 2, pending[0].Version)
+	}
+}
+
+func TestMigrator_RejectsDuplicateVersions(t *testing.T) {
+	list := append(testMigrations(), migrations.Migration{Version: 2, Name: "again", Up: createTable("d")})
+	_, err := migrations.New(newMigrationDB(t), list)
+	assert.Error(t, err)
+}
+
+func TestMigrator_DownWithoutRevert(t *testing.T) {
+	db := newMigrationDB(t)
+	m, err := migrations.New(db, []migrations.Migration{{Version: 1, Name: "one_way", Up: createTable("a")}})
+	assert.NoError(t, err)
+	_, err = m.Up(0)
+	assert.NoError(t, err)
+
+	_, err = m.Down(1)
+	assert.Error(t, err)
+	assert.NoError(t, m.Check())
+}
+
+// La migración inicial crea el esquema completo y Down lo elimina.
+func TestMigrations_InitialSchema(t *testing.T) {
+	db := newMigrationDB(t)
+	m := migrations.NewDefault(db)
+
+	_, err := m.Up(0)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Check())
+	assert.True(t, db.Migrator().HasTable("sensor_data"))
+	assert.True(t, db.Migrator().HasTable("export_jobs"))
+
+	_, err = m.Down(len(migrations.All))
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("sensor_data"))
+	assert.False(t, db.Migrator().HasTable("users"))
+}
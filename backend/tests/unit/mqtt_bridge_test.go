@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/mqttbridge"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+type recordingIngester struct {
+	deviceIDs []uint
+	fuel      []float64
+	ts        []time.Time
+	// Error devuelto en cada ingesta.
+	err error
+}
+
+func (r *recordingIngester) IngestData(deviceID uint, lat, lng, speed, fuel, temp float64, ts ...time.Time) error {
+	r.deviceIDs = append(r.deviceIDs, deviceID)
+	r.fuel = append(r.fuel, fuel)
+	r.ts = append(r.ts, ts[0])
+	return r.err
+}
+
+func newTestSubscriber(t *testing.T, ingester mqttbridge.Ingester) (*mqttbridge.Subscriber, *gorm.DB) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	_ = db.AutoMigrate(&domain.Device{})
+
+	sub, err := mqttbridge.New(mqttbridge.Config{
+		BrokerURL: "tcp://localhost:1883",
+		Topic:     "fleet/{external_id}/telemetry",
+		QoS:       1,
+	}, repository.NewDeviceRepository(db), ingester, utils.NewLogger("test"))
+	assert.NoError(t, err)
+	return sub, db
+}
+
+func TestMQTTSubscriber_TopicFilter(t *testing.T) {
+	sub, _ := newTestSubscriber(t, &recordingIngester{})
+	assert.Equal(t, "fleet/+/telemetry", sub.Filter())
+
+	id, ok := sub.ExternalIDFromTopic("fleet/DEV-0001/telemetry")
+	assert.True(t, ok)
+	assert.Equal(t, "DEV-0001", id)
+
+	_, ok = sub.ExternalIDFromTopic("fleet/DEV-0001/status")
+	assert.False(t, ok)
+	_, ok = sub.ExternalIDFromTopic("fleet/DEV-0001/telemetry/extra")
+	assert.False(t, ok)
+}
+
+func TestMQTTSubscriber_InvalidTopicPattern(t *testing.T) {
+	_, err := mqttbridge.New(mqttbridge.Config{
+		BrokerURL: "tcp://localhost:1883",
+		Topic:     "fleet/+/telemetry",
+	}, nil, &recordingIngester{}, utils.NewLogger("test"))
+	assert.Error(t, err)
+}
+
+func TestMQTTSubscriber_HandleMessage(t *testing.T) {
+	ingester := &recordingIngester{}
+	sub, db := newTestSubscriber(t, ingester)
+
+	device := domain.Device{ExternalID: "DEV-MQTT"}
+	db.Create(&device)
+
+	err := sub.HandleMessage("fleet/DEV-MQTT/telemetry",
+		[]byte(`{"lat":11.2,"lng":-74.1,"fuel_level":55.5,"ts":"2025-10-26T15:00:00Z"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{device.ID}, ingester.deviceIDs)
+	assert.Equal(t, []float64{55.5}, ingester.fuel)
+	assert.True(t, ingester.ts[0].Equal(time.Date(2025, 10, 26, 15, 0, 0, 0, time.UTC)))
+
+	// Dispositivo desconocido y JSON inválido se descartan
+	err = sub.HandleMessage("fleet/DEV-NOPE/telemetry", []byte(`{"lat":1}`))
+	assert.ErrorIs(t, err, mqttbridge.ErrInvalidMessage)
+	assert.False(t, mqttbridge.Retryable(err))
+	err = sub.HandleMessage("fleet/DEV-MQTT/telemetry", []byte(`no-json`))
+	assert.ErrorIs(t, err, mqttbridge.ErrInvalidMessage)
+	assert.False(t, mqttbridge.Retryable(err))
+	assert.Len(t, ingester.deviceIDs, 1)
+}
+
+// Las lecturas limitadas o con la BD caída quedan sin confirmar; las inválidas no
+func TestMQTTSubscriber_Retryable(t *testing.T) {
+	ingester := &recordingIngester{}
+	sub, db := newTestSubscriber(t, ingester)
+	db.Create(&domain.Device{ExternalID: "DEV-MQTT-RL"})
+	payload := []byte(`{"lat":11.2,"lng":-74.1}`)
+
+	ingester.err = &service.RateLimitError{DeviceID: 1}
+	assert.True(t, mqttbridge.Retryable(sub.HandleMessage("fleet/DEV-MQTT-RL/telemetry", payload)))
+
+	ingester.err = errors.New("db failure")
+	assert.True(t, mqttbridge.Retryable(sub.HandleMessage("fleet/DEV-MQTT-RL/telemetry", payload)))
+
+	ingester.err = &service.ValidationError{Errors: []service.FieldError{{Field: "lat", Code: service.CodeOutOfRange}}}
+	assert.False(t, mqttbridge.Retryable(sub.HandleMessage("fleet/DEV-MQTT-RL/telemetry", payload)))
+
+	ingester.err = nil
+	assert.False(t, mqttbridge.Retryable(sub.HandleMessage("fleet/DEV-MQTT-RL/telemetry", payload)))
+}
+
+// flakyIngester rechaza por límite de ingesta las primeras fails lecturas.
+type flakyIngester struct {
+	mu    sync.Mutex
+	fails int
+	fuel  map[float64]bool
+}
+
+func (f *flakyIngester) IngestData(deviceID uint, lat, lng, speed, fuel, temp float64, ts ...time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fails > 0 {
+		f.fails--
+		return &service.RateLimitError{DeviceID: deviceID}
+	}
+	f.fuel[fuel] = true
+	return nil
+}
+
+func (f *flakyIngester) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.fuel)
+}
+
+// Una ráfaga de rechazos mayor que la ventana de mensajes en vuelo del broker
+// no detiene la ingesta: cada mensaje se confirma y se reintenta desde la cola.
+func TestMQTTSubscriber_RetriesAfterBurst(t *testing.T) {
+	const burst = 100
+	ingester := &flakyIngester{fails: burst, fuel: make(map[float64]bool)}
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	_ = db.AutoMigrate(&domain.Device{})
+	db.Create(&domain.Device{ExternalID: "DEV-MQTT-BURST"})
+	sub, err := mqttbridge.New(mqttbridge.Config{
+		BrokerURL:    "tcp://localhost:1883",
+		Topic:        "fleet/{external_id}/telemetry",
+		QoS:          1,
+		RetryBackoff: time.Millisecond,
+	}, repository.NewDeviceRepository(db), ingester, utils.NewLogger("test"))
+	assert.NoError(t, err)
+	defer sub.Stop()
+
+	for i := 0; i < burst; i++ {
+		sub.Deliver("fleet/DEV-MQTT-BURST/telemetry", []byte(fmt.Sprintf(`{"fuel_level":%d}`, i)))
+	}
+	assert.Equal(t, 0, ingester.count())
+
+	// Las lecturas rechazadas se ingieren al reintentarse y las nuevas entran
+	sub.Deliver("fleet/DEV-MQTT-BURST/telemetry", []byte(`{"fuel_level":1000}`))
+	assert.Eventually(t, func() bool { return ingester.count() == burst+1 }, 2*time.Second, 10*time.Millisecond)
+}
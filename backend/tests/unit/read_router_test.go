@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/pkg/db"
+)
+
+// openReadTestDB abre una base SQLite en archivo con una lectura propia del
+// dispositivo 1, para distinguir de qué base leyó cada consulta.
+func openReadTestDB(t *testing.T, name string, speed float64) *gorm.DB {
+	conn, err := db.Connect("sqlite://"+filepath.Join(t.TempDir(), name+".db"), db.PoolConfig{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, conn.AutoMigrate(&domain.SensorData{}))
+	assert.NoError(t, conn.Create(&domain.SensorData{DeviceID: 1, TS: time.Now().UTC(), Speed: speed}).Error)
+	return conn
+}
+
+func TestReadRouter_RoutesReadsToReplicas(t *testing.T) {
+	primary := openReadTestDB(t, "primary", 1)
+	replicaA := openReadTestDB(t, "replica-a", 2)
+	replicaB := openReadTestDB(t, "replica-b", 3)
+
+	router := db.NewReadRouter(primary, replicaA, replicaB)
+	repo := repository.NewSensorRepositoryWithReplicas(router)
+
+	// El historial alterna entre réplicas y nunca toca la primaria
+	seen := map[float64]bool{}
+	for i := 0; i < 4; i++ {
+		rows, err := repo.GetRecentByDevice(1, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 1) {
+			seen[rows[0].Speed] = true
+		}
+	}
+	assert.Equal(t, map[float64]bool{2: true, 3: true}, seen)
+
+	// La evaluación de combustible lee de la primaria
+	rows, err := repo.GetRecentByDevicePrimary(1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 1.0, rows[0].Speed)
+	}
+}
+
+func TestReadRouter_FallsBackToPrimary(t *testing.T) {
+	primary := openReadTestDB(t, "primary", 1)
+	replica := openReadTestDB(t, "replica", 2)
+
+	router := db.NewReadRouter(primary, replica)
+	repo := repository.NewSensorRepositoryWithReplicas(router)
+
+	// Réplica caída: la consulta se repite en la primaria y la réplica sale de la ronda
+	sqlDB, _ := replica.DB()
+	sqlDB.Close()
+
+	rows, err := repo.GetRange(1, repository.RangeQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 1.0, rows[0].Speed)
+	}
+	healthy, total := router.Healthy()
+	assert.Equal(t, 0, healthy)
+	assert.Equal(t, 1, total)
+}
+
+func TestReadRouter_WithoutReplicasUsesPrimary(t *testing.T) {
+	primary := openReadTestDB(t, "primary", 1)
+	router := db.NewReadRouter(primary)
+
+	rows, err := repository.NewSensorRepositoryWithReplicas(router).GetRecentByDevice(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+
+	healthy, total := router.Healthy()
+	assert.Equal(t, 0, healthy)
+	assert.Equal(t, 0, total)
+}
+
+// Una réplica caída al arrancar queda en el router y se incorpora cuando
+// empieza a responder.
+func TestReadRouter_ReplicaDownAtStartup(t *testing.T) {
+	primary := openReadTestDB(t, "primary", 1)
+	dir := filepath.Join(t.TempDir(), "replica")
+	dsn := "sqlite://" + filepath.Join(dir, "replica.db")
+
+	router := db.ConnectReplicas(primary, []string{dsn}, db.PoolConfig{})
+	defer router.Stop()
+	healthy, total := router.Healthy()
+	assert.Equal(t, 0, healthy)
+	assert.Equal(t, 1, total)
+
+	repo := repository.NewSensorRepositoryWithReplicas(router)
+	rows, err := repo.GetRecentByDevice(1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 1.0, rows[0].Speed)
+	}
+
+	// La réplica aparece: la verificación periódica la conecta
+	assert.NoError(t, os.MkdirAll(dir, 0o755))
+	conn, err := db.Connect(dsn, db.PoolConfig{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, conn.AutoMigrate(&domain.SensorData{}))
+	assert.NoError(t, conn.Create(&domain.SensorData{DeviceID: 1, TS: time.Now().UTC(), Speed: 2}).Error)
+
+	router.StartHealthChecks(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		healthy, _ := router.Healthy()
+		return healthy == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	rows, err = repo.GetRecentByDevice(1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 2.0, rows[0].Speed)
+	}
+}
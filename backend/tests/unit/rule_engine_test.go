@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+func newRuleEngine(t *testing.T, rules ...domain.AlertRule) (*service.RuleEngine, repository.AlertRuleRepository) {
+	engine, repo, _ := newRuleEngineDB(t, rules...)
+	return engine, repo
+}
+
+func newRuleEngineDB(t *testing.T, rules ...domain.AlertRule) (*service.RuleEngine, repository.AlertRuleRepository, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	// Una sola conexión: cada conexión a :memory: abre una base distinta
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	assert.NoError(t, db.AutoMigrate(&domain.AlertRule{}, &domain.Alert{}))
+	repo := repository.NewAlertRuleRepository(db)
+	for i := range rules {
+		assert.NoError(t, repo.Create(&rules[i]))
+	}
+	return service.NewRuleEngine(repo), repo, db
+}
+
+func speedAt(deviceID uint, ts time.Time, speed float64) domain.SensorData {
+	return domain.SensorData{DeviceID: deviceID, TS: ts, Speed: speed}
+}
+
+// triggered resume los eventos como +id (dispara) o -id (se normaliza).
+func triggered(events []service.RuleEvent) []int {
+	out := []int{}
+	for _, ev := range events {
+		if ev.Triggered {
+			out = append(out, int(ev.Rule.ID))
+		} else {
+			out = append(out, -int(ev.Rule.ID))
+		}
+	}
+	return out
+}
+
+func TestRuleEngine_DurationAndHysteresis(t *testing.T) {
+	engine, _ := newRuleEngine(t, domain.AlertRule{
+		Name: "exceso", AlertType: "overspeed", Metric: "speed", Operator: service.OpGreater,
+		Threshold: 100, DurationSeconds: 60, Hysteresis: 10, Severity: domain.SeverityWarning, Enabled: true,
+	})
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Debe sostenerse 60 s: un pico aislado no dispara
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base, 120))))
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(30*time.Second), 90))))
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(40*time.Second), 110))))
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(80*time.Second), 115))))
+	assert.Equal(t, []int{1}, triggered(engine.Evaluate(speedAt(1, base.Add(100*time.Second), 115))))
+
+	// Ya activa: no repite, y dentro de la histéresis sigue activa
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(110*time.Second), 130))))
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(120*time.Second), 95))))
+	assert.Equal(t, []int{-1}, triggered(engine.Evaluate(speedAt(1, base.Add(130*time.Second), 89))))
+
+	// Una lectura atrasada no cambia el estado
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base, 200))))
+}
+
+func TestRuleEngine_ScopeAndAttributes(t *testing.T) {
+	engine, _ := newRuleEngine(t,
+		domain.AlertRule{
+			Name: "frío", AlertType: "cold", Metric: "temperature", Operator: service.OpLess,
+			Threshold: 0, DeviceIDs: []uint{2, 3}, Severity: domain.SeverityInfo, Enabled: true,
+		},
+		domain.AlertRule{
+			Name: "batería", AlertType: "low_battery", Metric: "attributes.battery", Operator: service.OpLessEqual,
+			Threshold: 11.5, Severity: domain.SeverityCritical, Enabled: true,
+		},
+		domain.AlertRule{
+			Name: "apagada", AlertType: "disabled", Metric: "speed", Operator: service.OpGreaterEqual,
+			Threshold: 0, Severity: domain.SeverityInfo, Enabled: false,
+		},
+	)
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// El dispositivo 1 no está en el grupo de la regla de frío
+	assert.Empty(t, triggered(engine.Evaluate(domain.SensorData{DeviceID: 1, TS: ts, Temperature: -5})))
+	assert.Equal(t, []int{1}, triggered(engine.Evaluate(domain.SensorData{DeviceID: 2, TS: ts, Temperature: -5})))
+
+	// Sin el atributo la regla no se evalúa
+	reading := domain.SensorData{DeviceID: 4, TS: ts, Temperature: 20, Attributes: domain.Attributes{"battery": 11.2}}
+	assert.Equal(t, []int{2}, triggered(engine.Evaluate(reading)))
+	assert.Empty(t, triggered(engine.Evaluate(domain.SensorData{DeviceID: 5, TS: ts, Temperature: 20})))
+}
+
+func TestRuleEngine_ReloadResetsChangedRules(t *testing.T) {
+	rule := domain.AlertRule{
+		Name: "exceso", AlertType: "overspeed", Metric: "speed", Operator: service.OpGreater,
+		Threshold: 100, Severity: domain.SeverityWarning, Enabled: true,
+	}
+	engine, repo := newRuleEngine(t, rule)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []int{1}, triggered(engine.Evaluate(speedAt(1, base, 120))))
+
+	// Sin cambios la regla conserva su estado
+	assert.NoError(t, engine.Reload())
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(time.Second), 120))))
+
+	stored, err := repo.GetByID(1)
+	assert.NoError(t, err)
+	stored.Threshold = 110
+	stored.UpdatedAt = stored.UpdatedAt.Add(time.Second)
+	assert.NoError(t, repo.Update(stored))
+	assert.NoError(t, engine.Reload())
+	assert.Equal(t, []int{1}, triggered(engine.Evaluate(speedAt(1, base.Add(2*time.Second), 120))))
+
+	stored.Enabled = false
+	assert.NoError(t, repo.Update(stored))
+	assert.NoError(t, engine.Reload())
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base.Add(3*time.Second), 50))))
+}
+
+func TestRuleEngine_StartReloads(t *testing.T) {
+	engine, repo := newRuleEngine(t)
+	assert.NoError(t, engine.Reload())
+	engine.StartReloads(10 * time.Millisecond)
+	defer engine.Stop()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, base, 120))))
+
+	// Una regla creada por otro proceso se aplica sin llamar a Reload
+	rule := domain.AlertRule{
+		Name: "exceso", AlertType: "overspeed", Metric: "speed", Operator: service.OpGreater,
+		Threshold: 100, Severity: domain.SeverityWarning, Enabled: true,
+	}
+	assert.NoError(t, repo.Create(&rule))
+	offset := time.Second
+	assert.Eventually(t, func() bool {
+		offset += time.Second
+		return len(triggered(engine.Evaluate(speedAt(1, base.Add(offset), 120)))) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// Y al eliminarla deja de evaluarse; cada intento usa un dispositivo nuevo
+	assert.NoError(t, repo.Delete(rule.ID))
+	deviceID := uint(1)
+	assert.Eventually(t, func() bool {
+		deviceID++
+		return len(triggered(engine.Evaluate(speedAt(deviceID, base, 120)))) == 0
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+// Tras un reinicio las alertas abiertas en la BD cuentan como activas: no se
+// repiten y se resuelven al normalizarse.
+func TestRuleEngine_OpenAlertsSurviveRestart(t *testing.T) {
+	engine, _, db := newRuleEngineDB(t, domain.AlertRule{
+		Name: "exceso", AlertType: "overspeed", Metric: "speed", Operator: service.OpGreater,
+		Threshold: 100, Severity: domain.SeverityWarning, Enabled: true, AutoResolve: true,
+	})
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ruleID := uint(1)
+	assert.NoError(t, db.Create(&[]domain.Alert{
+		{DeviceID: 1, TS: base, Type: "overspeed", RuleID: &ruleID, Status: domain.AlertOpen},
+		{DeviceID: 2, TS: base, Type: "overspeed", RuleID: &ruleID, Status: domain.AlertAcknowledged},
+		{DeviceID: 3, TS: base, Type: "overspeed", RuleID: &ruleID, Status: domain.AlertResolved},
+	}).Error)
+	assert.NoError(t, engine.Reload())
+
+	next := base.Add(time.Minute)
+	assert.Empty(t, triggered(engine.Evaluate(speedAt(1, next, 120))))
+	assert.Equal(t, []int{-1}, triggered(engine.Evaluate(speedAt(2, next, 80))))
+	assert.Equal(t, []int{1}, triggered(engine.Evaluate(speedAt(3, next, 120))))
+	assert.Equal(t, []int{-1}, triggered(engine.Evaluate(speedAt(1, next.Add(time.Second), 80))))
+}
@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+)
+
+func TestMonthlyPartition(t *testing.T) {
+	p := repository.MonthlyPartition(time.Date(2026, 12, 31, 23, 59, 0, 0, time.FixedZone("COT", -5*3600)))
+	// 23:59 en UTC-5 ya es enero en UTC
+	assert.Equal(t, "sensor_data_y2027m01", p.Name)
+	assert.True(t, p.From.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, p.To.Equal(time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+type fakePartitions struct {
+	ensured []time.Time
+	expired []repository.SensorPartition
+	dropped []string
+}
+
+func (f *fakePartitions) Enabled() (bool, error) { return true, nil }
+func (f *fakePartitions) MigrateFromUnpartitioned(now time.Time, ahead int) error {
+	return nil
+}
+func (f *fakePartitions) Ensure(now time.Time, ahead int) ([]string, error) {
+	f.ensured = append(f.ensured, now)
+	return nil, nil
+}
+func (f *fakePartitions) Expired(cutoff time.Time) ([]repository.SensorPartition, error) {
+	var out []repository.SensorPartition
+	for _, p := range f.expired {
+		if !p.To.After(cutoff) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+func (f *fakePartitions) RollupAndDrop(p repository.SensorPartition, batchSize int, rollup repository.RollupFunc) (int64, error) {
+	f.dropped = append(f.dropped, p.Name)
+	return 10, nil
+}
+
+type emptyRollupRepo struct{ purges int }
+
+func (r *emptyRollupRepo) PurgeBatch(cutoff time.Time, batchSize int, rollup repository.RollupFunc) (int64, error) {
+	r.purges++
+	return 0, nil
+}
+func (r *emptyRollupRepo) GetRange(deviceID uint, q repository.RangeQuery) ([]domain.SensorRollup, error) {
+	return nil, nil
+}
+
+func TestRetention_DropsExpiredPartitions(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	parts := &fakePartitions{expired: []repository.SensorPartition{
+		repository.MonthlyPartition(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)),
+		repository.MonthlyPartition(time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)),
+		repository.MonthlyPartition(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)),
+	}}
+	repo := &emptyRollupRepo{}
+
+	// 45 días: el corte cae a comienzos de septiembre
+	job := service.NewRetentionService(repo, service.RetentionConfig{Days: 45})
+	job.SetPartitions(parts, 3)
+	deleted, err := job.RunOnce(now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), deleted)
+	assert.Equal(t, []string{"sensor_data_y2026m07", "sensor_data_y2026m08"}, parts.dropped)
+	assert.Len(t, parts.ensured, 1)
+	assert.Equal(t, 1, repo.purges)
+
+	// Sin retención solo se crean particiones futuras
+	parts.dropped = nil
+	job = service.NewRetentionService(repo, service.RetentionConfig{})
+	job.SetPartitions(parts, 3)
+	deleted, err = job.RunOnce(now)
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.Empty(t, parts.dropped)
+	assert.Len(t, parts.ensured, 2)
+}
@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/telemetrypb"
+)
+
+// El código generado debe corresponder al telemetry.proto publicado: si se
+// edita el .proto sin volver a generar, esta prueba falla.
+func TestTelemetryPB_MatchesSchema(t *testing.T) {
+	compiler := protocompile.Compiler{
+		Resolver: &protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(map[string]string{
+			"telemetry.proto": string(telemetrypb.Schema),
+		})},
+	}
+	files, err := compiler.Compile(context.Background(), "telemetry.proto")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	compiled := protodesc.ToFileDescriptorProto(files[0])
+	generated := protodesc.ToFileDescriptorProto(telemetrypb.File_telemetry_proto)
+	compiled.SourceCodeInfo, generated.SourceCodeInfo = nil, nil
+	assert.True(t, proto.Equal(compiled, generated), "telemetry.pb.go no corresponde a telemetry.proto")
+}
+
+func TestTelemetryPB_ReadingRoundTrip(t *testing.T) {
+	in := &telemetrypb.Reading{
+		DeviceId:    42,
+		TsUnixMs:    1761490800123,
+		Lat:         11.2408,
+		Lng:         -74.1990,
+		Speed:       42.5,
+		FuelLevel:   70.3,
+		Temperature: -3.5,
+		Attributes: map[string]*telemetrypb.AttributeValue{
+			"odometer": {Value: &telemetrypb.AttributeValue_NumberValue{NumberValue: 1520.4}},
+			"rpm":      {Value: &telemetrypb.AttributeValue_IntegerValue{IntegerValue: 2100}},
+			"ignition": {Value: &telemetrypb.AttributeValue_BoolValue{BoolValue: true}},
+			"driver":   {Value: &telemetrypb.AttributeValue_StringValue{StringValue: "ana"}},
+		},
+	}
+	b, err := proto.Marshal(in)
+	assert.NoError(t, err)
+
+	out, err := telemetrypb.UnmarshalReading(b)
+	assert.NoError(t, err)
+	assert.Equal(t, in.DeviceId, out.DeviceId)
+	assert.Equal(t, in.TsUnixMs, out.TsUnixMs)
+	assert.Equal(t, in.Lat, out.Lat)
+	assert.Equal(t, 70.3, telemetrypb.Float64(out.FuelLevel))
+
+	// Los enteros se decodifican como float64, igual que desde JSON
+	attrs, err := telemetrypb.AttributeMap(out)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"odometer": 1520.4, "rpm": 2100.0, "ignition": true, "driver": "ana"}, attrs)
+}
+
+func TestTelemetryPB_SkipsUnknownFields(t *testing.T) {
+	b, err := proto.Marshal(&telemetrypb.Reading{DeviceId: 7})
+	assert.NoError(t, err)
+	b = protowire.AppendTag(b, 99, protowire.BytesType)
+	b = protowire.AppendString(b, "campo futuro")
+
+	out, err := telemetrypb.UnmarshalReading(b)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), out.DeviceId)
+}
+
+func TestTelemetryPB_Malformed(t *testing.T) {
+	_, err := telemetrypb.UnmarshalReading([]byte{0x0a, 0x05, 0x01})
+	assert.ErrorIs(t, err, telemetrypb.ErrMalformed)
+
+	b, err := proto.Marshal(&telemetrypb.ReadingBatch{Readings: []*telemetrypb.Reading{{DeviceId: 1}, {DeviceId: 2}}})
+	assert.NoError(t, err)
+	items, err := telemetrypb.SplitBatch(b)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		out, err := telemetrypb.UnmarshalReading(items[1])
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), out.DeviceId)
+	}
+}
@@ -0,0 +1,226 @@
+package unit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/gateway"
+	"github.com/nleea/fleet-monitoring/backend/internal/gateway/teltonika"
+	"github.com/nleea/fleet-monitoring/backend/internal/ratelimit"
+	"github.com/nleea/fleet-monitoring/backend/internal/repository"
+	"github.com/nleea/fleet-monitoring/backend/internal/service"
+	"github.com/nleea/fleet-monitoring/backend/internal/utils"
+)
+
+type trackerIngester struct {
+	deviceIDs []uint
+	readings  []service.TrackerReading
+}
+
+func (r *trackerIngester) AllowDevice(deviceID uint) (ratelimit.Decision, error) {
+	return ratelimit.Decision{Allowed: true}, nil
+}
+
+func (r *trackerIngester) IngestTracker(deviceID uint, reading service.TrackerReading) error {
+	r.deviceIDs = append(r.deviceIDs, deviceID)
+	r.readings = append(r.readings, reading)
+	return nil
+}
+
+// Ejemplo 1 de Codec 8 de la documentación de Teltonika
+const codec8Sample = "000000000000003608010000016B40D8EA30010000000000000000000000000000000105021503010101425E0F01F10000601A014E0000000000000000010000C7CF"
+
+// Paquete con un registro con posición, combustible (IO 89) y temperatura (IO 72)
+func buildCodec8Packet(ts time.Time, lat, lng float64, speed uint16, fuel byte, tempDeci int32) []byte {
+	return buildCodec8PacketN(1, ts, lat, lng, speed, fuel, tempDeci)
+}
+
+// Paquete con n registros iguales separados por un segundo
+func buildCodec8PacketN(n int, ts time.Time, lat, lng float64, speed uint16, fuel byte, tempDeci int32) []byte {
+	var data bytes.Buffer
+	data.WriteByte(teltonika.Codec8)
+	data.WriteByte(byte(n))
+	for i := 0; i < n; i++ {
+		binary.Write(&data, binary.BigEndian, uint64(ts.Add(time.Duration(i)*time.Second).UnixMilli()))
+		data.WriteByte(0)
+		binary.Write(&data, binary.BigEndian, int32(lng*1e7))
+		binary.Write(&data, binary.BigEndian, int32(lat*1e7))
+		binary.Write(&data, binary.BigEndian, uint16(20))
+		binary.Write(&data, binary.BigEndian, uint16(90))
+		data.WriteByte(9)
+		binary.Write(&data, binary.BigEndian, speed)
+		data.Write([]byte{0, 2})        // event IO, total IO
+		data.Write([]byte{1, 89, fuel}) // N1
+		data.WriteByte(0)               // N2
+		data.Write([]byte{1, 72})       // N4
+		binary.Write(&data, binary.BigEndian, tempDeci)
+		data.WriteByte(0) // N8
+	}
+	data.WriteByte(byte(n))
+
+	var pkt bytes.Buffer
+	binary.Write(&pkt, binary.BigEndian, uint32(0))
+	binary.Write(&pkt, binary.BigEndian, uint32(data.Len()))
+	pkt.Write(data.Bytes())
+	binary.Write(&pkt, binary.BigEndian, uint32(teltonika.CRC16(data.Bytes())))
+	return pkt.Bytes()
+}
+
+func TestTeltonikaCodec8_DecodeSample(t *testing.T) {
+	raw, _ := hex.DecodeString(codec8Sample)
+
+	records, err := teltonika.ReadPacket(bufio.NewReader(bytes.NewReader(raw)))
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		rec := records[0]
+		assert.Equal(t, int64(0x16B40D8EA30), rec.Timestamp.UnixMilli())
+		assert.Equal(t, byte(1), rec.Priority)
+		assert.Equal(t, uint64(3), rec.IO[0x15])
+		assert.Equal(t, uint64(0x5E0F), rec.IO[0x42])
+		assert.Equal(t, uint64(0x601A), rec.IO[0xF1])
+		assert.Len(t, rec.IO, 5)
+	}
+}
+
+// Registro sin combustible y con la temperatura Dallas en un elemento de 2 bytes
+func TestTeltonikaCodec8_IOWidths(t *testing.T) {
+	var data bytes.Buffer
+	data.WriteByte(teltonika.Codec8)
+	data.WriteByte(1)
+	binary.Write(&data, binary.BigEndian, uint64(time.Now().UnixMilli()))
+	data.Write(make([]byte, 1+4+4+2+2+1+2)) // prioridad, lng, lat, altitud, ángulo, satélites, velocidad
+	data.Write([]byte{0, 2})                // event IO, total IO
+	data.Write([]byte{1, 0x15, 3})          // N1
+	data.Write([]byte{1, 72})               // N2
+	binary.Write(&data, binary.BigEndian, int16(-35))
+	data.Write([]byte{0, 0}) // N4, N8
+	data.WriteByte(1)
+
+	records, err := teltonika.DecodeData(data.Bytes())
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		temp, ok := records[0].Temperature()
+		assert.True(t, ok)
+		assert.Equal(t, -3.5, temp)
+		_, ok = records[0].FuelLevel()
+		assert.False(t, ok)
+	}
+}
+
+func TestTeltonikaCodec8_BadCRC(t *testing.T) {
+	raw, _ := hex.DecodeString(codec8Sample)
+	raw[len(raw)-1] ^= 0xFF
+
+	_, err := teltonika.ReadPacket(bufio.NewReader(bytes.NewReader(raw)))
+	assert.ErrorIs(t, err, teltonika.ErrBadCRC)
+}
+
+func TestTeltonikaCodec8_ReadIMEI(t *testing.T) {
+	imei, err := teltonika.ReadIMEI(bytes.NewReader(append([]byte{0x00, 0x0F}, "356307042441013"...)))
+	assert.NoError(t, err)
+	assert.Equal(t, "356307042441013", imei)
+
+	_, err = teltonika.ReadIMEI(bytes.NewReader(append([]byte{0x00, 0x03}, "ABC"...)))
+	assert.ErrorIs(t, err, teltonika.ErrBadIMEI)
+}
+
+func TestGatewaySession_LoginAndAck(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	_ = db.AutoMigrate(&domain.Device{})
+	device := domain.Device{ExternalID: "356307042441013"}
+	db.Create(&device)
+
+	ingester := &trackerIngester{}
+	srv := gateway.NewServer(repository.NewDeviceRepository(db), ingester, utils.NewLogger("test"), time.Second)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go srv.HandleConn(server)
+
+	client.Write(append([]byte{0x00, 0x0F}, "356307042441013"...))
+	reply := make([]byte, 1)
+	_, err := client.Read(reply)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x01), reply[0])
+
+	ts := time.Date(2025, 10, 26, 15, 0, 0, 0, time.UTC)
+	client.Write(buildCodec8Packet(ts, 11.2404, -74.2110, 54, 63, -35))
+
+	ack := make([]byte, 4)
+	_, err = client.Read(ack)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(ack))
+
+	assert.Equal(t, []uint{device.ID}, ingester.deviceIDs)
+	if assert.Len(t, ingester.readings, 1) {
+		r := ingester.readings[0]
+		assert.True(t, r.TS.Equal(ts))
+		if assert.NotNil(t, r.FuelLevel) && assert.NotNil(t, r.Temperature) {
+			assert.Equal(t, 63.0, *r.FuelLevel)
+			assert.Equal(t, -3.5, *r.Temperature)
+		}
+	}
+}
+
+func TestGatewaySession_UnknownIMEI(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	_ = db.AutoMigrate(&domain.Device{})
+
+	srv := gateway.NewServer(repository.NewDeviceRepository(db), &trackerIngester{}, utils.NewLogger("test"), time.Second)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go srv.HandleConn(server)
+
+	client.Write(append([]byte{0x00, 0x0F}, "000000000000000"...))
+	reply := make([]byte, 1)
+	_, err := client.Read(reply)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x00), reply[0])
+}
+
+// El límite del dispositivo se cobra una vez por paquete: uno con más
+// registros que la ráfaga se acepta completo en lugar de rechazarse siempre.
+func TestGatewaySession_RateLimitPerPacket(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	_ = db.AutoMigrate(&domain.Device{}, &domain.SensorData{}, &domain.IdempotencyKey{}, &domain.Alert{})
+	device := domain.Device{ExternalID: "356307042441014"}
+	db.Create(&device)
+
+	devices := repository.NewDeviceRepository(db)
+	svc := service.NewSensorService(repository.NewSensorRepository(db), repository.NewAlertRepository(db), nil, devices)
+	svc.SetRateLimits(ratelimit.NewRegistry(ratelimit.Policy{Device: ratelimit.Limit{Rate: 0.001, Burst: 2}}))
+	srv := gateway.NewServer(devices, svc, utils.NewLogger("test"), time.Second)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go srv.HandleConn(server)
+
+	client.Write(append([]byte{0x00, 0x0F}, "356307042441014"...))
+	reply := make([]byte, 1)
+	_, err := client.Read(reply)
+	assert.NoError(t, err)
+
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	ack := make([]byte, 4)
+	for i, want := range []uint32{5, 5, 0} {
+		client.Write(buildCodec8PacketN(5, ts.Add(time.Duration(i)*time.Minute), 11.2, -74.2, 40, 60, 215))
+		_, err = client.Read(ack)
+		assert.NoError(t, err)
+		assert.Equal(t, want, binary.BigEndian.Uint32(ack), "paquete %d", i)
+	}
+
+	var stored int64
+	db.Model(&domain.SensorData{}).Where("device_id = ?", device.ID).Count(&stored)
+	assert.Equal(t, int64(10), stored)
+}
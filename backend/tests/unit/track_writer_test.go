@@ -0,0 +1,46 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nleea/fleet-monitoring/backend/internal/domain"
+	"github.com/nleea/fleet-monitoring/backend/internal/export"
+)
+
+// Cada bloque es un LineString que arranca en el último punto del anterior.
+func TestKMLWriter_ChunksStayContinuous(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	reading := func(i int) domain.SensorData {
+		return domain.SensorData{TS: base.Add(time.Duration(i) * time.Minute), Lat: float64(i), Lng: float64(10 + i)}
+	}
+
+	var buf bytes.Buffer
+	w, err := export.NewTrackWriter(export.FormatKML, &buf, "A&B")
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]domain.SensorData{reading(0), reading(1)}))
+	assert.NoError(t, w.Write([]domain.SensorData{reading(2)}))
+	assert.NoError(t, w.Close())
+
+	var doc struct {
+		Name       string `xml:"Document>name"`
+		Placemarks []struct {
+			Begin       string `xml:"TimeSpan>begin"`
+			End         string `xml:"TimeSpan>end"`
+			Coordinates string `xml:"LineString>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "A&B", doc.Name)
+	if assert.Len(t, doc.Placemarks, 2) {
+		assert.Equal(t, []string{"10,0,0", "11,1,0"}, strings.Fields(doc.Placemarks[0].Coordinates))
+		assert.Equal(t, []string{"11,1,0", "12,2,0"}, strings.Fields(doc.Placemarks[1].Coordinates))
+		assert.Equal(t, "2025-01-01T00:01:00Z", doc.Placemarks[1].Begin)
+		assert.Equal(t, "2025-01-01T00:02:00Z", doc.Placemarks[1].End)
+	}
+}
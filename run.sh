@@ -24,7 +24,7 @@ command -v python >/dev/null 2>&1 || { echo "❌ Python no está instalado."; ex
 # --- Iniciar backend ---
 echo "⚙️  Iniciando backend..."
 cd backend || exit
-nohup go mod tidy & go run scripts/seed.go & go run cmd/api/main.go > ../backend.log 2>&1 &
+nohup go mod tidy & (go run ./cmd/migrate up && go run scripts/seed.go && go run cmd/api/main.go) > ../backend.log 2>&1 &
 BACK_PID=$!
 cd ..
 